@@ -2,6 +2,7 @@ package app
 
 import (
 	"fmt"
+	"io"
 	"os"
 
 	"github.com/saharsh-samples/go-mux-sql-starter/http"
@@ -11,6 +12,7 @@ import (
 // App interface
 type App interface {
 	Run() Status
+	RunCommand(args []string) error
 }
 
 // Status of application
@@ -41,8 +43,11 @@ type app struct {
 	startupTimeoutInSeconds int
 	server                  http.Server
 	shutdownHooks           []ShutdownHook
+	workers                 []Worker
 	sigs                    <-chan os.Signal
 	status                  chan<- Status
+	commands                map[string]Command
+	stdout                  io.Writer
 }
 
 // Run the application
@@ -75,12 +80,16 @@ func (app *app) Run() Status {
 		return status
 	}
 
+	// run background workers
+	stopWorkers := startWorkers(app.workers)
+
 	// wait for termination signal
 	sig := <-app.sigs
 	fmt.Println("Recieved ", sig.String(), " signal. Terminating...")
 
-	// shutdown http server
+	// shutdown http server, then workers
 	err := app.server.Shutdown()
+	stopWorkers()
 
 	// exit communicating error (if any)
 	if err != nil {
@@ -0,0 +1,62 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/saharsh-samples/go-mux-sql-starter/http"
+)
+
+// Command can be run from the command line instead of the app
+// (e.g. 'myapp openapi openapi.json'). Args exclude the command name
+type Command func(args []string, stdout io.Writer) error
+
+// RunCommand named by first of args
+func (app *app) RunCommand(args []string) error {
+
+	if len(args) == 0 {
+		return fmt.Errorf("No command specified. Available commands: %v", app.commandNames())
+	}
+
+	command, found := app.commands[args[0]]
+	if !found {
+		return fmt.Errorf("Unknown command '%v'. Available commands: %v", args[0], app.commandNames())
+	}
+
+	return command(args[1:], app.stdout)
+}
+
+func (app *app) commandNames() string {
+	names := make([]string, 0, len(app.commands))
+	for name := range app.commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// OpenAPICommand exports OpenAPI document describing routes of server
+// bootstrapped with httpContext. Document is written to file named by
+// first argument, or stdout if there is none
+func OpenAPICommand(httpContext *http.ContextIn) Command {
+	return func(args []string, stdout io.Writer) error {
+
+		out := stdout
+		if len(args) > 0 {
+			file, err := os.Create(args[0])
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			out = file
+		}
+
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(http.GenerateOpenAPIDocument(httpContext))
+	}
+}
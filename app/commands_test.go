@@ -0,0 +1,62 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/saharsh-samples/go-mux-sql-starter/http"
+	"github.com/saharsh-samples/go-mux-sql-starter/test"
+)
+
+func TestRunCommand(t *testing.T) {
+
+	// arrange
+	var received []string
+	stdout := &bytes.Buffer{}
+	ctx := Bootstrap(&ContextIn{
+		HTTPServer: &happyServer{},
+		Commands: map[string]Command{
+			"echo": func(args []string, out io.Writer) error {
+				received = args
+				_, err := out.Write([]byte("echoed"))
+				return err
+			},
+		},
+	})
+	ctx.App.(*app).stdout = stdout
+
+	// act and assert
+	test.AssertTrue("", ctx.App.RunCommand([]string{"echo", "a", "b"}) == nil, t)
+	test.AssertEquals("", "a,b", strings.Join(received, ","), t)
+	test.AssertEquals("", "echoed", stdout.String(), t)
+
+	err := ctx.App.RunCommand([]string{"missing"})
+	test.AssertEquals("", "Unknown command 'missing'. Available commands: echo", err.Error(), t)
+
+	err = ctx.App.RunCommand(nil)
+	test.AssertEquals("", "No command specified. Available commands: echo", err.Error(), t)
+}
+
+func TestOpenAPICommand(t *testing.T) {
+
+	// arrange
+	command := OpenAPICommand(&http.ContextIn{OpenAPI: &http.OpenAPIConfiguration{Title: "Exported"}})
+	stdout := &bytes.Buffer{}
+	file := filepath.Join(t.TempDir(), "openapi.json")
+
+	// act and assert
+	test.AssertTrue("", command(nil, stdout) == nil, t)
+	document := &http.OpenAPIDocument{}
+	test.AssertTrue("", json.Unmarshal(stdout.Bytes(), document) == nil, t)
+	test.AssertEquals("", "Exported", document.Info.Title, t)
+
+	test.AssertTrue("", command([]string{file}, stdout) == nil, t)
+	written, _ := os.ReadFile(file)
+	test.AssertTrue("", json.Unmarshal(written, document) == nil, t)
+	test.AssertEquals("", "3.1.0", document.OpenAPI, t)
+}
@@ -11,6 +11,13 @@ type ContextIn struct {
 	StartupTimeoutInSeconds int
 	HTTPServer              http.Server
 	ShutdownHooks           []ShutdownHook
+
+	// Workers run in background while app is running. They are stopped
+	// after HTTP server shuts down and before shutdown hooks run
+	Workers []Worker
+
+	// Commands available via App.RunCommand, keyed by name
+	Commands map[string]Command
 }
 
 // ContextOut describes dependencies exported by this package
@@ -34,8 +41,11 @@ func Bootstrap(in *ContextIn) *ContextOut {
 		startupTimeoutInSeconds: in.StartupTimeoutInSeconds,
 		server:                  in.HTTPServer,
 		shutdownHooks:           in.ShutdownHooks,
+		workers:                 in.Workers,
 		sigs:                    signal,
 		status:                  status,
+		commands:                in.Commands,
+		stdout:                  os.Stdout,
 	}
 	out.Signal = signal
 	out.Status = status
@@ -0,0 +1,82 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/saharsh-samples/go-mux-sql-starter/db"
+)
+
+// Worker runs in background while app is running (e.g. a periodic purge
+// job). Run must return once ctx is done
+type Worker interface {
+	Run(ctx context.Context)
+}
+
+// WorkerFunc adapts a function to Worker
+type WorkerFunc func(ctx context.Context)
+
+// Run the function
+func (f WorkerFunc) Run(ctx context.Context) {
+	f(ctx)
+}
+
+// NewPeriodicWorker runs task every interval until app terminates. Errors
+// are logged, and the task runs again at the next interval
+func NewPeriodicWorker(name string, interval time.Duration, task func(ctx context.Context) error) Worker {
+	return WorkerFunc(func(ctx context.Context) {
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := task(ctx); err != nil {
+					fmt.Printf("Worker '%v' failed: %v\n", name, err)
+				}
+			}
+		}
+	})
+}
+
+// startWorkers in background. Returned function stops them and waits
+// till they return
+func startWorkers(workers []Worker) func() {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := &sync.WaitGroup{}
+	for _, worker := range workers {
+		wg.Add(1)
+		go func(worker Worker) {
+			defer wg.Done()
+			worker.Run(ctx)
+		}(worker)
+	}
+
+	return func() {
+		cancel()
+		wg.Wait()
+	}
+}
+
+// NewOutboxRelayWorker relays pending outbox events every interval until
+// app terminates
+func NewOutboxRelayWorker(relay db.OutboxRelay, interval time.Duration) Worker {
+	return NewPeriodicWorker("outbox-relay", interval, func(ctx context.Context) error {
+		return relay.RelayPending(ctx)
+	})
+}
+
+// NewSoftDeletePurgeWorker purges rows of softDelete deleted longer than
+// retention ago every interval until app terminates
+func NewSoftDeletePurgeWorker(database db.Database, softDelete *db.SoftDelete, retention time.Duration, interval time.Duration) Worker {
+	return NewPeriodicWorker("soft-delete-purge-"+softDelete.Table, interval, func(ctx context.Context) error {
+		_, err := softDelete.Purge(database.GetConnection(), retention)
+		return err
+	})
+}
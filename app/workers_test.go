@@ -0,0 +1,124 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/saharsh-samples/go-mux-sql-starter/db"
+	dbTest "github.com/saharsh-samples/go-mux-sql-starter/db/test"
+	"github.com/saharsh-samples/go-mux-sql-starter/test"
+	"github.com/saharsh-samples/go-mux-sql-starter/utils"
+)
+
+func TestWorkers_run_while_app_is_running(t *testing.T) {
+
+	// arrange
+	var started, stopped, stoppedBeforeHooks int32
+	worker := WorkerFunc(func(ctx context.Context) {
+		atomic.StoreInt32(&started, 1)
+		<-ctx.Done()
+		atomic.StoreInt32(&stopped, 1)
+	})
+	ctx := Bootstrap(&ContextIn{
+		StartupTimeoutInSeconds: 1,
+		HTTPServer:              &happyServer{},
+		ShutdownHooks:           []ShutdownHook{func() { stoppedBeforeHooks = atomic.LoadInt32(&stopped) }},
+		Workers:                 []Worker{worker},
+	})
+
+	// act
+	go ctx.App.Run()
+	<-ctx.Status
+	<-ctx.Status
+	test.AssertTrue("Expected worker to start", utils.WaitTill(func() bool { return atomic.LoadInt32(&started) == 1 }, 1) == nil, t)
+	ctx.Signal <- syscall.SIGTERM
+	appStatus := <-ctx.Status
+
+	// assert
+	test.AssertEquals("", TerminatedStatus, appStatus.Status, t)
+	test.AssertEquals("Expected worker to stop", int32(1), atomic.LoadInt32(&stopped), t)
+	_, open := <-ctx.Status
+	test.AssertFalse("Expected app status channel to close", open, t)
+	test.AssertEquals("Expected worker to stop before shutdown hooks", int32(1), stoppedBeforeHooks, t)
+}
+
+func TestNewPeriodicWorker(t *testing.T) {
+
+	// arrange
+	var runs int32
+	worker := NewPeriodicWorker("purge", 5*time.Millisecond, func(ctx context.Context) error {
+		if atomic.AddInt32(&runs, 1) == 1 {
+			return errors.New("Simulated error")
+		}
+		return nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan bool)
+
+	// act
+	go func() {
+		worker.Run(ctx)
+		close(done)
+	}()
+	ranAgain := utils.WaitTill(func() bool { return atomic.LoadInt32(&runs) >= 3 }, 1) == nil
+	cancel()
+
+	// assert
+	test.AssertTrue("Expected task to run again after failing", ranAgain, t)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("Expected worker to return once context is done")
+	}
+}
+
+type countingRelay struct {
+	db.OutboxRelay
+	relayed int32
+}
+
+func (relay *countingRelay) RelayPending(ctx context.Context) db.Error {
+	atomic.AddInt32(&relay.relayed, 1)
+	return nil
+}
+
+func TestNewOutboxRelayWorker(t *testing.T) {
+
+	// arrange
+	relay := &countingRelay{}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// act
+	go NewOutboxRelayWorker(relay, 5*time.Millisecond).Run(ctx)
+
+	// assert
+	test.AssertTrue("Expected pending events to be relayed periodically",
+		utils.WaitTill(func() bool { return atomic.LoadInt32(&relay.relayed) >= 2 }, 1) == nil, t)
+}
+
+func TestNewSoftDeletePurgeWorker(t *testing.T) {
+
+	// arrange
+	database, mock, closer := dbTest.NewDatabaseWithMockConnection(t)
+	defer closer()
+	mock.MatchExpectationsInOrder(false)
+	for i := 0; i < 2; i++ {
+		mock.ExpectExec("DELETE FROM things WHERE deleted_at IS NOT NULL AND deleted_at < ?").
+			WithArgs(sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// act
+	go NewSoftDeletePurgeWorker(database, db.NewSoftDelete("things"), 24*time.Hour, 5*time.Millisecond).Run(ctx)
+
+	// assert
+	test.AssertTrue("Expected deleted rows to be purged periodically",
+		utils.WaitTill(func() bool { return mock.ExpectationsWereMet() == nil }, 1) == nil, t)
+}
@@ -0,0 +1,261 @@
+package db
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// ---
+// Audit Trail
+//
+// Audit records who changed what. Changes made through it are written to
+// an audit table, with JSON snapshots of the record before and after the
+// change, within the same transaction as the change itself. Actor and
+// request ID are taken from the context (see NewAuditContext). Expects
+// the following table (name is configurable via ContextIn):
+//
+//  CREATE TABLE audit_log (
+//    id             BIGINT AUTO_INCREMENT PRIMARY KEY,
+//    entity         VARCHAR(64) NOT NULL,
+//    entity_id      VARCHAR(64) NOT NULL,
+//    action         VARCHAR(16) NOT NULL,
+//    actor          VARCHAR(255) NOT NULL,
+//    request_id     VARCHAR(64) NOT NULL,
+//    transaction_id VARCHAR(32) NOT NULL,
+//    before_json    TEXT NULL,
+//    after_json     TEXT NULL,
+//    created_at     DATETIME NOT NULL,
+//    INDEX audit_log_entity (entity, entity_id)
+//  );
+// ---
+
+// DefaultAuditTable value
+const DefaultAuditTable = "audit_log"
+
+// Audited actions
+const (
+	AuditCreate = "create"
+	AuditUpdate = "update"
+	AuditDelete = "delete"
+)
+
+// AuditEntry is a recorded change of an entity. Before is null for
+// creates and After is null for deletes
+type AuditEntry struct {
+	ID            int64
+	Entity        string
+	EntityID      string
+	Action        string
+	Actor         string
+	RequestID     string
+	TransactionID string
+	Before        json.RawMessage
+	After         json.RawMessage
+	CreatedAt     time.Time
+}
+
+// Audit wraps the basic CRUD helpers, recording changes of entities (e.g.
+// "things") in the audit table. Record is the struct the dest pointers
+// point into, and is snapshotted as JSON. Use inside WithTransaction so
+// that changes and their audit entries are committed together
+type Audit interface {
+	CreateOne(ctx context.Context, conn Connection, entity string, record interface{}, insertCommand string, insertArgs []interface{}, query string, dest []interface{}) Error
+	UpdateOne(ctx context.Context, conn Connection, entity string, record interface{}, id interface{}, updateCommand string, updateArgs []interface{}, query string, dest []interface{}) Error
+	DeleteOne(ctx context.Context, conn Connection, entity string, record interface{}, id interface{}, deleteCommand string, query string, dest []interface{}) Error
+
+	// WithTransaction runs wrapped in a transaction of database. Changes
+	// audited inside it share a transaction ID
+	WithTransaction(ctx context.Context, database Database, wrapped func(ctx context.Context, conn Connection) Error) Error
+
+	// List changes of an entity, most recent first
+	List(conn Connection, entity string, entityID interface{}, limit int) ([]*AuditEntry, Error)
+
+	// Count changes of an entity
+	Count(conn Connection, entity string, entityID interface{}) (int64, Error)
+}
+
+// NewAuditContext returns a copy of ctx carrying actor and request ID
+// recorded with changes (e.g. API key owner and X-Request-Id header)
+func NewAuditContext(ctx context.Context, actor string, requestID string) context.Context {
+	info := auditInfoFromContext(ctx)
+	if actor != "" {
+		info.actor = actor
+	}
+	if requestID != "" {
+		info.requestID = requestID
+	}
+	return context.WithValue(ctx, auditContextKey, info)
+}
+
+// AuditActorFromContext returns actor of ctx, if any
+func AuditActorFromContext(ctx context.Context) string {
+	return auditInfoFromContext(ctx).actor
+}
+
+// --------
+// Internal
+// --------
+
+type auditContextKeyType int
+
+const auditContextKey auditContextKeyType = iota
+
+type auditInfo struct {
+	actor         string
+	requestID     string
+	transactionID string
+}
+
+func auditInfoFromContext(ctx context.Context) auditInfo {
+	info, _ := ctx.Value(auditContextKey).(auditInfo)
+	return info
+}
+
+type audit struct {
+	table string
+	now   func() time.Time
+}
+
+// CreateOne new row in DB and record it
+func (audit *audit) CreateOne(ctx context.Context, conn Connection, entity string, record interface{}, insertCommand string, insertArgs []interface{}, query string, dest []interface{}) Error {
+
+	// insert
+	insert, insertError := conn.Exec(insertCommand, insertArgs...)
+	if insertError != nil {
+		return WrapError(insertError)
+	}
+
+	// grab last inserted id
+	id, idRetrievalError := insert.LastInsertId()
+	if idRetrievalError != nil {
+		return WrapError(idRetrievalError)
+	}
+
+	// look up inserted data
+	if lookupError := LookupOne(conn, query, []interface{}{id}, dest); lookupError != nil {
+		return lookupError
+	}
+
+	return audit.record(ctx, conn, entity, id, AuditCreate, nil, record)
+}
+
+// UpdateOne row in DB and record its state before and after
+func (audit *audit) UpdateOne(ctx context.Context, conn Connection, entity string, record interface{}, id interface{}, updateCommand string, updateArgs []interface{}, query string, dest []interface{}) Error {
+
+	// look up data before update
+	if lookupError := LookupOne(conn, query, []interface{}{id}, dest); lookupError != nil {
+		return lookupError
+	}
+	before, snapshotError := json.Marshal(record)
+	if snapshotError != nil {
+		return WrapError(snapshotError)
+	}
+
+	if updateError := UpdateOne(conn, id, updateCommand, updateArgs, query, dest); updateError != nil {
+		return updateError
+	}
+
+	return audit.record(ctx, conn, entity, id, AuditUpdate, before, record)
+}
+
+// DeleteOne row in DB and record its state before deletion
+func (audit *audit) DeleteOne(ctx context.Context, conn Connection, entity string, record interface{}, id interface{}, deleteCommand string, query string, dest []interface{}) Error {
+
+	if deleteError := DeleteOne(conn, id, deleteCommand, query, dest); deleteError != nil {
+		return deleteError
+	}
+
+	before, snapshotError := json.Marshal(record)
+	if snapshotError != nil {
+		return WrapError(snapshotError)
+	}
+	return audit.record(ctx, conn, entity, id, AuditDelete, before, nil)
+}
+
+// WithTransaction runs wrapped in a transaction with a new transaction ID
+func (audit *audit) WithTransaction(ctx context.Context, database Database, wrapped func(ctx context.Context, conn Connection) Error) Error {
+
+	random := make([]byte, 16)
+	if _, randErr := rand.Read(random); randErr != nil {
+		return WrapError(randErr)
+	}
+	info := auditInfoFromContext(ctx)
+	info.transactionID = hex.EncodeToString(random)
+	ctx = context.WithValue(ctx, auditContextKey, info)
+
+	return database.WithTransaction(func(conn Connection) Error {
+		return wrapped(ctx, conn)
+	})
+}
+
+// List changes of an entity, most recent first
+func (audit *audit) List(conn Connection, entity string, entityID interface{}, limit int) ([]*AuditEntry, Error) {
+
+	rows, queryError := conn.Query(
+		fmt.Sprintf("SELECT id, entity, entity_id, action, actor, request_id, transaction_id, before_json, after_json, created_at FROM %s WHERE entity = ? AND entity_id = ? ORDER BY id DESC LIMIT ?", audit.table),
+		entity, fmt.Sprint(entityID), limit,
+	)
+	if queryError != nil {
+		return nil, WrapError(queryError)
+	}
+	defer rows.Close()
+
+	entries := []*AuditEntry{}
+	for rows.Next() {
+		entry := &AuditEntry{}
+		var before, after sql.NullString
+		if scanError := rows.Scan(&entry.ID, &entry.Entity, &entry.EntityID, &entry.Action, &entry.Actor, &entry.RequestID, &entry.TransactionID, &before, &after, &entry.CreatedAt); scanError != nil {
+			return nil, WrapError(scanError)
+		}
+		entry.Before = rawJSON(before)
+		entry.After = rawJSON(after)
+		entries = append(entries, entry)
+	}
+
+	return entries, WrapError(rows.Err())
+}
+
+// Count changes of an entity
+func (audit *audit) Count(conn Connection, entity string, entityID interface{}) (int64, Error) {
+	var count int64
+	countError := LookupOne(conn,
+		fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE entity = ? AND entity_id = ?", audit.table),
+		[]interface{}{entity, fmt.Sprint(entityID)}, []interface{}{&count})
+	return count, countError
+}
+
+// record audit entry of change. Nil snapshots are stored as NULL, and a
+// non-nil after record is snapshotted
+func (audit *audit) record(ctx context.Context, conn Connection, entity string, id interface{}, action string, before []byte, after interface{}) Error {
+
+	var beforeJSON, afterJSON interface{}
+	if before != nil {
+		beforeJSON = string(before)
+	}
+	if after != nil {
+		snapshot, snapshotError := json.Marshal(after)
+		if snapshotError != nil {
+			return WrapError(snapshotError)
+		}
+		afterJSON = string(snapshot)
+	}
+
+	info := auditInfoFromContext(ctx)
+	_, insertError := conn.Exec(
+		fmt.Sprintf("INSERT INTO %s (entity, entity_id, action, actor, request_id, transaction_id, before_json, after_json, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)", audit.table),
+		entity, fmt.Sprint(id), action, info.actor, info.requestID, info.transactionID, beforeJSON, afterJSON, audit.now().UTC(),
+	)
+	return WrapError(insertError)
+}
+
+func rawJSON(value sql.NullString) json.RawMessage {
+	if !value.Valid {
+		return nil
+	}
+	return json.RawMessage(value.String)
+}
@@ -0,0 +1,124 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/saharsh-samples/go-mux-sql-starter/test"
+)
+
+const auditInsert = "INSERT INTO audit_log (entity, entity_id, action, actor, request_id, transaction_id, before_json, after_json, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)"
+
+type auditedThing struct {
+	Name    string
+	Version int64
+}
+
+func (thing *auditedThing) destinations() []interface{} {
+	return []interface{}{&thing.Name, &thing.Version}
+}
+
+var auditedAt = time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+func TestAudit_records_changes(t *testing.T) {
+
+	// arrange
+	conn, mock := newMockConnection(t)
+	audit := Bootstrap(&ContextIn{}).Audit.(*audit)
+	audit.now = func() time.Time { return auditedAt }
+	ctx := NewAuditContext(NewAuditContext(context.Background(), "", "req-1"), "alice", "")
+	thing := &auditedThing{}
+
+	mock.ExpectExec("INSERT INTO things (name) VALUES (?)").WithArgs("thing").WillReturnResult(sqlmock.NewResult(1, 1))
+	expectThing(mock, 1)
+	mock.ExpectExec(auditInsert).
+		WithArgs("things", "1", AuditCreate, "alice", "req-1", "", nil, `{"Name":"thing","Version":1}`, auditedAt).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	expectThing(mock, 1)
+	mock.ExpectExec("UPDATE things SET version = version + 1 WHERE id = ?").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	expectThing(mock, 2)
+	mock.ExpectExec(auditInsert).
+		WithArgs("things", "1", AuditUpdate, "alice", "req-1", "", `{"Name":"thing","Version":1}`, `{"Name":"thing","Version":2}`, auditedAt).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+
+	expectThing(mock, 2)
+	mock.ExpectExec("DELETE FROM things WHERE id = ?").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(auditInsert).
+		WithArgs("things", "1", AuditDelete, "alice", "req-1", "", `{"Name":"thing","Version":2}`, nil, auditedAt).
+		WillReturnResult(sqlmock.NewResult(3, 1))
+
+	// act
+	created := audit.CreateOne(ctx, conn, "things", thing, "INSERT INTO things (name) VALUES (?)", []interface{}{"thing"}, thingQuery, thing.destinations())
+	updated := audit.UpdateOne(ctx, conn, "things", thing, 1, "UPDATE things SET version = version + 1 WHERE id = ?", []interface{}{1}, thingQuery, thing.destinations())
+	deleted := audit.DeleteOne(ctx, conn, "things", thing, 1, "DELETE FROM things WHERE id = ?", thingQuery, thing.destinations())
+
+	// assert
+	test.AssertTrue("Expected no errors", created == nil && updated == nil && deleted == nil, t)
+	test.AssertTrue("Expected all expectations to be met", mock.ExpectationsWereMet() == nil, t)
+}
+
+func TestAudit_WithTransaction(t *testing.T) {
+
+	// arrange
+	handle, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	dbCtx := Bootstrap(&ContextIn{DatabaseHandle: handle})
+	database, audit := dbCtx.Database, dbCtx.Audit
+	var transactionID string
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	// act
+	err := audit.WithTransaction(NewAuditContext(context.Background(), "alice", ""), database, func(ctx context.Context, conn Connection) Error {
+		transactionID = auditInfoFromContext(ctx).transactionID
+		test.AssertEquals("", "alice", AuditActorFromContext(ctx), t)
+		return nil
+	})
+
+	// assert
+	test.AssertTrue("Expected no error", err == nil, t)
+	test.AssertEquals("", 32, len(transactionID), t)
+	test.AssertTrue("Expected all expectations to be met", mock.ExpectationsWereMet() == nil, t)
+}
+
+func TestAudit_List(t *testing.T) {
+
+	// arrange
+	conn, mock := newMockConnection(t)
+	columns := []string{"id", "entity", "entity_id", "action", "actor", "request_id", "transaction_id", "before_json", "after_json", "created_at"}
+	mock.ExpectQuery("SELECT id, entity, entity_id, action, actor, request_id, transaction_id, before_json, after_json, created_at FROM audit_log WHERE entity = ? AND entity_id = ? ORDER BY id DESC LIMIT ?").
+		WithArgs("things", "1", 10).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(2, "things", "1", AuditDelete, "alice", "req-2", "tx", `{"Name":"thing"}`, nil, auditedAt).
+			AddRow(1, "things", "1", AuditCreate, "alice", "req-1", "", nil, `{"Name":"thing"}`, auditedAt))
+
+	// act
+	entries, err := Bootstrap(&ContextIn{}).Audit.List(conn, "things", 1, 10)
+
+	// assert
+	test.AssertTrue("Expected no error", err == nil, t)
+	test.AssertEquals("", 2, len(entries), t)
+	test.AssertEquals("", AuditDelete, entries[0].Action, t)
+	test.AssertEquals("", `{"Name":"thing"}`, string(entries[0].Before), t)
+	test.AssertTrue("Expected null after snapshot", entries[0].After == nil, t)
+	test.AssertEquals("", `{"Name":"thing"}`, string(entries[1].After), t)
+	test.AssertEquals("", auditedAt, entries[1].CreatedAt, t)
+}
+
+func TestAudit_Count(t *testing.T) {
+
+	// arrange
+	conn, mock := newMockConnection(t)
+	mock.ExpectQuery("SELECT COUNT(*) FROM audit_log WHERE entity = ? AND entity_id = ?").
+		WithArgs("things", "1").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(12))
+
+	// act
+	count, err := Bootstrap(&ContextIn{}).Audit.Count(conn, "things", 1)
+
+	// assert
+	test.AssertTrue("Expected no error", err == nil, t)
+	test.AssertEquals("", int64(12), count, t)
+}
@@ -1,6 +1,10 @@
 package db
 
-import "database/sql"
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
 
 // Connection abstracts out the relevant operations common to sql.DB and
 // sql.Tx into a base interface
@@ -8,6 +12,7 @@ type Connection interface {
 	Exec(query string, args ...interface{}) (sql.Result, error)
 	Prepare(query string) (*sql.Stmt, error)
 	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
 	QueryRow(query string, args ...interface{}) *sql.Row
 }
 
@@ -48,7 +53,7 @@ func LookupOne(conn Connection, query string, args []interface{}, dest []interfa
 	}
 }
 
-// UpdateOne row in DB
+// UpdateOne row in DB. Fails with NotFound if no row has specified id
 func UpdateOne(conn Connection, id interface{}, updateCommand string, updateArgs []interface{}, query string, dest []interface{}) Error {
 
 	// update
@@ -57,11 +62,37 @@ func UpdateOne(conn Connection, id interface{}, updateCommand string, updateArgs
 		return WrapError(updateError)
 	}
 
+	// look up updated data using id. Affected rows aren't checked as zero
+	// can also mean update didn't change any values. Use UpdateOneVersioned
+	// to detect concurrent modifications
+	return LookupOne(conn, query, []interface{}{id}, dest)
+}
+
+// UpdateOneVersioned updates row in DB only if its version column still
+// has expected version. The update command must increment version and be
+// conditioned on it last, e.g.
+//
+//	UPDATE things SET name = ?, version = version + 1 WHERE id = ? AND version = ?
+//
+// Expected version is appended to update args. Fails with Conflict if row
+// was modified concurrently and with NotFound if row doesn't exist
+func UpdateOneVersioned(conn Connection, id interface{}, version int64, updateCommand string, updateArgs []interface{}, query string, dest []interface{}) Error {
+
+	// update
+	update, updateError := conn.Exec(updateCommand, append(append([]interface{}{}, updateArgs...), version)...)
+	if updateError != nil {
+		return WrapError(updateError)
+	}
+
+	if affectedError := staleVersionError(conn, update, id, version, query, dest); affectedError != nil {
+		return affectedError
+	}
+
 	// look up updated data using id
 	return LookupOne(conn, query, []interface{}{id}, dest)
 }
 
-// DeleteOne row in DB
+// DeleteOne row in DB. Fails with NotFound if no row has specified id
 func DeleteOne(conn Connection, id interface{}, deleteCommand string, query string, dest []interface{}) Error {
 
 	// look up data being deleted
@@ -70,11 +101,64 @@ func DeleteOne(conn Connection, id interface{}, deleteCommand string, query stri
 		return lookupError
 	}
 
-	// update
-	_, deleteError := conn.Exec(deleteCommand, id)
+	// delete
+	deletion, deleteError := conn.Exec(deleteCommand, id)
 	if deleteError != nil {
 		return WrapError(deleteError)
 	}
 
+	// row was deleted concurrently
+	affected, rowsError := deletion.RowsAffected()
+	if rowsError != nil {
+		return WrapError(rowsError)
+	}
+	if affected == 0 {
+		return NewNotFoundError("")
+	}
+
 	return nil
 }
+
+// DeleteOneVersioned deletes row in DB only if its version column still
+// has expected version. The delete command is run with id and version
+// args, e.g.
+//
+//	DELETE FROM things WHERE id = ? AND version = ?
+//
+// Fails with Conflict if row was modified concurrently and with NotFound
+// if row doesn't exist
+func DeleteOneVersioned(conn Connection, id interface{}, version int64, deleteCommand string, query string, dest []interface{}) Error {
+
+	// look up data being deleted
+	lookupError := LookupOne(conn, query, []interface{}{id}, dest)
+	if lookupError != nil {
+		return lookupError
+	}
+
+	// delete
+	deletion, deleteError := conn.Exec(deleteCommand, id, version)
+	if deleteError != nil {
+		return WrapError(deleteError)
+	}
+
+	return staleVersionError(conn, deletion, id, version, query, dest)
+}
+
+// staleVersionError returns Conflict, or NotFound, error if versioned
+// command didn't affect any rows
+func staleVersionError(conn Connection, result sql.Result, id interface{}, version int64, query string, dest []interface{}) Error {
+
+	affected, rowsError := result.RowsAffected()
+	if rowsError != nil {
+		return WrapError(rowsError)
+	}
+	if affected > 0 {
+		return nil
+	}
+
+	// distinguish missing rows from stale versions
+	if lookupError := LookupOne(conn, query, []interface{}{id}, dest); lookupError != nil {
+		return lookupError
+	}
+	return NewConflictError(fmt.Sprintf("Version %d is stale. Row was modified concurrently", version))
+}
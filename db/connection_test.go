@@ -0,0 +1,119 @@
+package db
+
+import (
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/saharsh-samples/go-mux-sql-starter/test"
+)
+
+const (
+	versionedUpdate = "UPDATE things SET name = ?, version = version + 1 WHERE id = ? AND version = ?"
+	versionedDelete = "DELETE FROM things WHERE id = ? AND version = ?"
+	thingQuery      = "SELECT name, version FROM things WHERE id = ?"
+)
+
+func newMockConnection(t *testing.T) (Connection, sqlmock.Sqlmock) {
+	handle, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	return handle, mock
+}
+
+func expectThing(mock sqlmock.Sqlmock, version int64) {
+	mock.ExpectQuery(thingQuery).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "version"}).AddRow("thing", version))
+}
+
+func TestUpdateOneVersioned(t *testing.T) {
+
+	// arrange
+	conn, mock := newMockConnection(t)
+	mock.ExpectExec(versionedUpdate).WithArgs("renamed", 1, 3).WillReturnResult(sqlmock.NewResult(0, 1))
+	expectThing(mock, 4)
+	var name string
+	var version int64
+
+	// act
+	err := UpdateOneVersioned(conn, 1, 3, versionedUpdate, []interface{}{"renamed", 1}, thingQuery, []interface{}{&name, &version})
+
+	// assert
+	test.AssertTrue("Expected no error", err == nil, t)
+	test.AssertEquals("", int64(4), version, t)
+	test.AssertTrue("Expected all expectations to be met", mock.ExpectationsWereMet() == nil, t)
+}
+
+func TestUpdateOneVersioned_with_stale_version(t *testing.T) {
+
+	// arrange
+	conn, mock := newMockConnection(t)
+	mock.ExpectExec(versionedUpdate).WithArgs("renamed", 1, 3).WillReturnResult(sqlmock.NewResult(0, 0))
+	expectThing(mock, 5)
+	var name string
+	var version int64
+
+	// act
+	err := UpdateOneVersioned(conn, 1, 3, versionedUpdate, []interface{}{"renamed", 1}, thingQuery, []interface{}{&name, &version})
+
+	// assert
+	test.AssertEquals("", Conflict, err.Type(), t)
+	test.AssertEquals("", "Version 3 is stale. Row was modified concurrently", err.Error(), t)
+	test.AssertTrue("Expected all expectations to be met", mock.ExpectationsWereMet() == nil, t)
+}
+
+func TestUpdateOneVersioned_with_missing_row(t *testing.T) {
+
+	// arrange
+	conn, mock := newMockConnection(t)
+	mock.ExpectExec(versionedUpdate).WithArgs("renamed", 1, 3).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(thingQuery).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"name", "version"}))
+	var name string
+	var version int64
+
+	// act
+	err := UpdateOneVersioned(conn, 1, 3, versionedUpdate, []interface{}{"renamed", 1}, thingQuery, []interface{}{&name, &version})
+
+	// assert
+	test.AssertEquals("", NotFound, err.Type(), t)
+	test.AssertTrue("Expected all expectations to be met", mock.ExpectationsWereMet() == nil, t)
+}
+
+func TestDeleteOneVersioned(t *testing.T) {
+
+	// arrange
+	conn, mock := newMockConnection(t)
+	expectThing(mock, 3)
+	mock.ExpectExec(versionedDelete).WithArgs(1, 3).WillReturnResult(sqlmock.NewResult(0, 1))
+	expectThing(mock, 4)
+	mock.ExpectExec(versionedDelete).WithArgs(1, 3).WillReturnResult(sqlmock.NewResult(0, 0))
+	expectThing(mock, 4)
+	var name string
+	var version int64
+
+	// act
+	deleted := DeleteOneVersioned(conn, 1, 3, versionedDelete, thingQuery, []interface{}{&name, &version})
+	stale := DeleteOneVersioned(conn, 1, 3, versionedDelete, thingQuery, []interface{}{&name, &version})
+
+	// assert
+	test.AssertTrue("Expected no error", deleted == nil, t)
+	test.AssertEquals("", Conflict, stale.Type(), t)
+	test.AssertTrue("Expected all expectations to be met", mock.ExpectationsWereMet() == nil, t)
+}
+
+func TestDeleteOne_deleted_concurrently(t *testing.T) {
+
+	// arrange
+	conn, mock := newMockConnection(t)
+	expectThing(mock, 3)
+	mock.ExpectExec("DELETE FROM things WHERE id = ?").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 0))
+	var name string
+	var version int64
+
+	// act
+	err := DeleteOne(conn, 1, "DELETE FROM things WHERE id = ?", thingQuery, []interface{}{&name, &version})
+
+	// assert
+	test.AssertEquals("", NotFound, err.Type(), t)
+	test.AssertTrue("Expected all expectations to be met", mock.ExpectationsWereMet() == nil, t)
+}
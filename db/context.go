@@ -1,15 +1,28 @@
 package db
 
-import "database/sql"
+import (
+	"database/sql"
+	"time"
+)
 
 // ContextIn describes dependecies needed by this package
 type ContextIn struct {
 	DatabaseHandle *sql.DB
+
+	// AuditTable records changes made through Audit (defaults to
+	// DefaultAuditTable)
+	AuditTable string
+
+	// OutboxTable stores events added to Outbox (defaults to
+	// DefaultOutboxTable)
+	OutboxTable string
 }
 
 // ContextOut describes dependencies exported by this package
 type ContextOut struct {
 	Database Database
+	Audit    Audit
+	Outbox   Outbox
 }
 
 // Bootstrap initializes this module with ContextIn and exports
@@ -20,5 +33,17 @@ func Bootstrap(in *ContextIn) *ContextOut {
 	out := &ContextOut{}
 	out.Database = &database{dbHandle: in.DatabaseHandle}
 
+	auditTable := in.AuditTable
+	if auditTable == "" {
+		auditTable = DefaultAuditTable
+	}
+	out.Audit = &audit{table: auditTable, now: time.Now}
+
+	outboxTable := in.OutboxTable
+	if outboxTable == "" {
+		outboxTable = DefaultOutboxTable
+	}
+	out.Outbox = &outbox{table: outboxTable, now: time.Now}
+
 	return out
 }
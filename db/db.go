@@ -13,6 +13,10 @@ type Database interface {
 	LookupOne(query string, args []interface{}, dest []interface{}) Error
 	UpdateOne(id interface{}, updateCommand string, updateArgs []interface{}, query string, dest []interface{}) Error
 	DeleteOne(id interface{}, deleteCommand string, query string, dest []interface{}) Error
+
+	// optimistic locking using a version column
+	UpdateOneVersioned(id interface{}, version int64, updateCommand string, updateArgs []interface{}, query string, dest []interface{}) Error
+	DeleteOneVersioned(id interface{}, version int64, deleteCommand string, query string, dest []interface{}) Error
 }
 
 type database struct {
@@ -91,3 +95,13 @@ func (database *database) UpdateOne(id interface{}, updateCommand string, update
 func (database *database) DeleteOne(id interface{}, deleteCommand string, query string, dest []interface{}) Error {
 	return DeleteOne(database.dbHandle, id, deleteCommand, query, dest)
 }
+
+// UpdateOneVersioned row in DB
+func (database *database) UpdateOneVersioned(id interface{}, version int64, updateCommand string, updateArgs []interface{}, query string, dest []interface{}) Error {
+	return UpdateOneVersioned(database.dbHandle, id, version, updateCommand, updateArgs, query, dest)
+}
+
+// DeleteOneVersioned row in DB
+func (database *database) DeleteOneVersioned(id interface{}, version int64, deleteCommand string, query string, dest []interface{}) Error {
+	return DeleteOneVersioned(database.dbHandle, id, version, deleteCommand, query, dest)
+}
@@ -63,6 +63,15 @@ func NewForbiddenError(detail string) Error {
 	return &databaseError{errorType: Forbidden, errorDetail: detail}
 }
 
+// Conflict - errors where row was modified concurrently, i.e. its
+// version is no longer the expected one
+var Conflict = "Conflict"
+
+// NewConflictError from detail
+func NewConflictError(detail string) Error {
+	return &databaseError{errorType: Conflict, errorDetail: detail}
+}
+
 // WrapError (raw nullable errors) into db.Error
 func WrapError(wrapped error) Error {
 	if wrapped == nil {
@@ -0,0 +1,104 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ---
+// Keyset Pagination
+//
+// Pages are continued from the position (sort column values) of the last
+// row of the previous page instead of skipping rows with OFFSET, so pages
+// are fast regardless of depth and need no COUNT(*). The last column must
+// be unique (e.g. 'id') so every row has a distinct position, and columns
+// must not be nullable since NULLs don't compare
+// ---
+
+// KeysetColumn is a column lists are sorted by
+type KeysetColumn struct {
+	Column     string
+	Descending bool
+}
+
+// Keyset is the sort order of a keyset paginated list
+type Keyset []KeysetColumn
+
+// Keyset of list query's sorts followed by unique tiebreaker column, in
+// ascending order, unless list query is already sorted by it
+func (query *ListQuery) Keyset(tiebreaker string) Keyset {
+	keyset := Keyset{}
+	for _, s := range query.Sorts {
+		keyset = append(keyset, KeysetColumn{Column: query.fields[s.Field], Descending: s.Descending})
+		if query.fields[s.Field] == tiebreaker {
+			return keyset
+		}
+	}
+	return append(keyset, KeysetColumn{Column: tiebreaker})
+}
+
+// After returns condition, with '?' placeholders, matching rows after
+// position, or before it if backward, and the matching args. Mixed sort
+// directions are supported, so the condition is expanded, e.g.
+// '(a > ?) OR (a = ? AND b < ?)'. Positions, which usually come from client
+// cursors, that don't match keyset are reported as BadRequest
+func (keyset Keyset) After(position []interface{}, backward bool) (string, []interface{}, Error) {
+
+	if len(position) != len(keyset) {
+		return "", nil, NewBadRequestError(fmt.Sprintf("Cursor position has %d values but list is sorted by %d columns", len(position), len(keyset)))
+	}
+
+	alternatives := make([]string, len(keyset))
+	args := []interface{}{}
+	for i, column := range keyset {
+		conditions := []string{}
+		for j := 0; j < i; j++ {
+			conditions = append(conditions, keyset[j].Column+" = ?")
+			args = append(args, position[j])
+		}
+		operator := ">"
+		if column.Descending != backward {
+			operator = "<"
+		}
+		conditions = append(conditions, fmt.Sprintf("%v %v ?", column.Column, operator))
+		args = append(args, position[i])
+		alternatives[i] = "(" + strings.Join(conditions, " AND ") + ")"
+	}
+
+	return "(" + strings.Join(alternatives, " OR ") + ")", args, nil
+}
+
+// OrderBy returns ORDER BY clause of keyset, reversed if backward
+func (keyset Keyset) OrderBy(backward bool) string {
+	orders := make([]string, len(keyset))
+	for i, column := range keyset {
+		direction := "ASC"
+		if column.Descending != backward {
+			direction = "DESC"
+		}
+		orders[i] = column.Column + " " + direction
+	}
+	return "ORDER BY " + strings.Join(orders, ", ")
+}
+
+// KeysetPage trims rows, queried with limit+1 using After and OrderBy,
+// to a page of at most limit rows in keyset order. Reports whether there
+// are rows after and before the page. Pages reached by a cursor always
+// have rows on the side they were reached from
+func KeysetPage[T any](rows []T, limit int, backward bool, fromCursor bool) (page []T, hasNext bool, hasPrev bool) {
+
+	more := len(rows) > limit
+	if more {
+		rows = rows[:limit]
+	}
+
+	if !backward {
+		return rows, more, fromCursor
+	}
+
+	page = make([]T, len(rows))
+	for i, row := range rows {
+		page[len(rows)-1-i] = row
+	}
+	return page, fromCursor, more
+}
@@ -0,0 +1,63 @@
+package db
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/saharsh-samples/go-mux-sql-starter/test"
+)
+
+func TestKeyset(t *testing.T) {
+
+	query, _ := ParseListQuery(testListFields, nil, "-created_at,name")
+	keyset := query.Keyset("id")
+
+	condition, args, err := keyset.After([]interface{}{"2024-01-01", "jo", 7}, false)
+	test.AssertTrue("Expected no error", err == nil, t)
+	test.AssertEquals("",
+		"((created_at < ?) OR (created_at = ? AND full_name > ?) OR (created_at = ? AND full_name = ? AND id > ?))",
+		condition, t)
+	test.AssertEquals("", "[2024-01-01 2024-01-01 jo 2024-01-01 jo 7]", fmt.Sprint(args), t)
+	test.AssertEquals("", "ORDER BY created_at DESC, full_name ASC, id ASC", keyset.OrderBy(false), t)
+
+	// backward
+	condition, _, _ = keyset.After([]interface{}{"2024-01-01", "jo", 7}, true)
+	test.AssertEquals("",
+		"((created_at > ?) OR (created_at = ? AND full_name < ?) OR (created_at = ? AND full_name = ? AND id < ?))",
+		condition, t)
+	test.AssertEquals("", "ORDER BY created_at ASC, full_name DESC, id DESC", keyset.OrderBy(true), t)
+}
+
+func TestKeyset_After_with_mismatched_position(t *testing.T) {
+
+	query, _ := ParseListQuery(testListFields, nil, "-created_at")
+	_, _, err := query.Keyset("id").After([]interface{}{"2024-01-01", "jo", 7}, false)
+	test.AssertTrue("Expected error", err != nil, t)
+	test.AssertEquals("", BadRequest, err.Type(), t)
+}
+
+func TestKeyset_sorted_by_tiebreaker(t *testing.T) {
+	query, _ := ParseListQuery(ListFields{"id": "id", "name": "name"}, nil, "-id,name")
+	test.AssertEquals("", "ORDER BY id DESC", query.Keyset("id").OrderBy(false), t)
+}
+
+func TestKeysetPage(t *testing.T) {
+
+	// first page
+	page, hasNext, hasPrev := KeysetPage([]int{1, 2, 3}, 2, false, false)
+	test.AssertEquals("", "[1 2]", fmt.Sprint(page), t)
+	test.AssertTrue("", hasNext, t)
+	test.AssertFalse("", hasPrev, t)
+
+	// last page
+	page, hasNext, hasPrev = KeysetPage([]int{5}, 2, false, true)
+	test.AssertEquals("", "[5]", fmt.Sprint(page), t)
+	test.AssertFalse("", hasNext, t)
+	test.AssertTrue("", hasPrev, t)
+
+	// backward, rows are in reverse order
+	page, hasNext, hasPrev = KeysetPage([]int{4, 3, 2}, 2, true, true)
+	test.AssertEquals("", "[3 4]", fmt.Sprint(page), t)
+	test.AssertTrue("", hasNext, t)
+	test.AssertTrue("", hasPrev, t)
+}
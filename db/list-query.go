@@ -0,0 +1,224 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ---
+// Filter Operators
+// ---
+
+// Equal - 'status:eq:active'
+var Equal = "eq"
+
+// NotEqual - 'status:ne:revoked'
+var NotEqual = "ne"
+
+// GreaterThan - 'age:gt:21'
+var GreaterThan = "gt"
+
+// GreaterThanOrEqual - 'created_at:gte:2024-01-01'
+var GreaterThanOrEqual = "gte"
+
+// LessThan - 'age:lt:65'
+var LessThan = "lt"
+
+// LessThanOrEqual - 'created_at:lte:2024-12-31'
+var LessThanOrEqual = "lte"
+
+// Like - 'name:like:jo%' (SQL LIKE pattern)
+var Like = "like"
+
+// In - 'status:in:active|pending' (values separated by '|')
+var In = "in"
+
+var sqlOperators = map[string]string{
+	Equal:              "=",
+	NotEqual:           "<>",
+	GreaterThan:        ">",
+	GreaterThanOrEqual: ">=",
+	LessThan:           "<",
+	LessThanOrEqual:    "<=",
+	Like:               "LIKE",
+	In:                 "IN",
+}
+
+// ---
+// List Queries
+// ---
+
+// ListFields is the allowlist of fields a resource can be filtered and
+// sorted by. Keys are field names used in requests, values are the SQL
+// expressions (usually column names) they translate to. Values are never
+// taken from requests, so they are safe to interpolate into queries
+type ListFields map[string]string
+
+// Filter on a field, e.g. 'status:eq:active'
+type Filter struct {
+	Field    string
+	Operator string
+	Value    string
+}
+
+// Sort by a field, e.g. '-created_at' for descending order
+type Sort struct {
+	Field      string
+	Descending bool
+}
+
+// ListQuery holds filters and sorts of a list request, checked against
+// the allowlist of a resource
+type ListQuery struct {
+	Filters []*Filter
+	Sorts   []*Sort
+
+	// IncludeDeleted rows of soft deleted tables (see ExcludeDeleted)
+	IncludeDeleted bool
+
+	fields        ListFields
+	deletedColumn string
+}
+
+// ParseListQuery parses filters of form 'field:operator:value' and a sort
+// of form 'field,-field'. Unknown fields and operators are BadRequest errors
+func ParseListQuery(fields ListFields, filters []string, sortBy string) (*ListQuery, Error) {
+
+	query := &ListQuery{fields: fields}
+
+	for _, expression := range filters {
+		filter, err := query.parseFilter(expression)
+		if err != nil {
+			return nil, err
+		}
+		query.Filters = append(query.Filters, filter)
+	}
+
+	if strings.TrimSpace(sortBy) != "" {
+		for _, expression := range strings.Split(sortBy, ",") {
+			s, err := query.parseSort(strings.TrimSpace(expression))
+			if err != nil {
+				return nil, err
+			}
+			query.Sorts = append(query.Sorts, s)
+		}
+	}
+
+	return query, nil
+}
+
+// ExcludeDeleted makes conditions exclude rows deleted by softDelete,
+// unless IncludeDeleted is set
+func (query *ListQuery) ExcludeDeleted(softDelete *SoftDelete) *ListQuery {
+	query.deletedColumn = softDelete.Column
+	return query
+}
+
+// Where returns WHERE clause with '?' placeholders for filters, and the
+// matching args. Both are empty if there are no filters
+func (query *ListQuery) Where() (string, []interface{}) {
+	conditions, args := query.Conditions()
+	if conditions == "" {
+		return "", nil
+	}
+	return "WHERE " + conditions, args
+}
+
+// Conditions are filters joined by AND, for queries with conditions of
+// their own. Both are empty if there are no filters and deleted rows
+// aren't excluded
+func (query *ListQuery) Conditions() (string, []interface{}) {
+
+	conditions := make([]string, len(query.Filters))
+	args := []interface{}{}
+	for i, filter := range query.Filters {
+		column := query.fields[filter.Field]
+		if filter.Operator == In {
+			values := strings.Split(filter.Value, "|")
+			placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(values)), ", ")
+			conditions[i] = fmt.Sprintf("%v IN (%v)", column, placeholders)
+			for _, value := range values {
+				args = append(args, value)
+			}
+			continue
+		}
+		conditions[i] = fmt.Sprintf("%v %v ?", column, sqlOperators[filter.Operator])
+		args = append(args, filter.Value)
+	}
+
+	if query.deletedColumn != "" && !query.IncludeDeleted {
+		conditions = append(conditions, query.deletedColumn+" IS NULL")
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+// OrderBy returns ORDER BY clause for sorts, or empty string if there are
+// no sorts
+func (query *ListQuery) OrderBy() string {
+
+	if len(query.Sorts) == 0 {
+		return ""
+	}
+
+	orders := make([]string, len(query.Sorts))
+	for i, s := range query.Sorts {
+		direction := "ASC"
+		if s.Descending {
+			direction = "DESC"
+		}
+		orders[i] = query.fields[s.Field] + " " + direction
+	}
+
+	return "ORDER BY " + strings.Join(orders, ", ")
+}
+
+// ---
+// Parsing
+// ---
+
+func (query *ListQuery) parseFilter(expression string) (*Filter, Error) {
+
+	parts := strings.SplitN(expression, ":", 3)
+	if len(parts) != 3 {
+		return nil, NewBadRequestError(fmt.Sprintf("Filter '%v' must be of form 'field:operator:value'", expression))
+	}
+
+	filter := &Filter{Field: parts[0], Operator: strings.ToLower(parts[1]), Value: parts[2]}
+	if err := query.checkField(filter.Field); err != nil {
+		return nil, err
+	}
+	if _, found := sqlOperators[filter.Operator]; !found {
+		return nil, NewBadRequestError(fmt.Sprintf("Unknown filter operator '%v'. Expected one of [%v]", parts[1], strings.Join(operatorNames(), ", ")))
+	}
+
+	return filter, nil
+}
+
+func (query *ListQuery) parseSort(expression string) (*Sort, Error) {
+	s := &Sort{Field: strings.TrimPrefix(expression, "-"), Descending: strings.HasPrefix(expression, "-")}
+	if err := query.checkField(s.Field); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (query *ListQuery) checkField(field string) Error {
+	if _, found := query.fields[field]; !found {
+		names := make([]string, 0, len(query.fields))
+		for name := range query.fields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return NewBadRequestError(fmt.Sprintf("Unknown field '%v'. Expected one of [%v]", field, strings.Join(names, ", ")))
+	}
+	return nil
+}
+
+func operatorNames() []string {
+	return []string{Equal, NotEqual, GreaterThan, GreaterThanOrEqual, LessThan, LessThanOrEqual, Like, In}
+}
@@ -0,0 +1,56 @@
+package db
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/saharsh-samples/go-mux-sql-starter/test"
+)
+
+var testListFields = ListFields{
+	"status":     "status",
+	"created_at": "created_at",
+	"name":       "full_name",
+}
+
+func TestParseListQuery(t *testing.T) {
+
+	query, err := ParseListQuery(testListFields,
+		[]string{"status:in:active|pending", "created_at:GTE:2024-01-01T10:00:00Z", "name:like:jo%"},
+		"-created_at, name")
+	test.AssertTrue("Expected list query to parse", err == nil, t)
+
+	where, args := query.Where()
+	test.AssertEquals("", "WHERE status IN (?, ?) AND created_at >= ? AND full_name LIKE ?", where, t)
+	test.AssertEquals("", "[active pending 2024-01-01T10:00:00Z jo%]", fmt.Sprint(args), t)
+	test.AssertEquals("", "ORDER BY created_at DESC, full_name ASC", query.OrderBy(), t)
+}
+
+func TestParseListQuery_without_filters_or_sorts(t *testing.T) {
+
+	query, err := ParseListQuery(testListFields, nil, "")
+	test.AssertTrue("Expected list query to parse", err == nil, t)
+
+	where, args := query.Where()
+	test.AssertEquals("", "", where, t)
+	test.AssertEquals("", 0, len(args), t)
+	test.AssertEquals("", "", query.OrderBy(), t)
+}
+
+func TestParseListQuery_with_invalid_input(t *testing.T) {
+
+	for _, tc := range []struct {
+		filters []string
+		sort    string
+	}{
+		{[]string{"password:eq:x"}, ""},
+		{[]string{"status:matches:x"}, ""},
+		{[]string{"status=active"}, ""},
+		{nil, "-password"},
+		{nil, "name,"},
+	} {
+		_, err := ParseListQuery(testListFields, tc.filters, tc.sort)
+		test.AssertTrue("Expected error", err != nil, t)
+		test.AssertEquals("", BadRequest, err.Type(), t)
+	}
+}
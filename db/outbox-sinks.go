@@ -0,0 +1,109 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ---
+// Outbox Sinks
+// ---
+
+// DefaultWebhookTimeout of requests of webhook sinks without own client
+const DefaultWebhookTimeout = 10 * time.Second
+
+// NewWebhookSink POSTs event payloads to url, with event ID and topic in
+// X-Outbox-Event-Id and X-Outbox-Topic headers. Non 2xx responses are
+// failures. Client defaults to one timing out after DefaultWebhookTimeout
+func NewWebhookSink(url string, client *http.Client) OutboxSink {
+	if client == nil {
+		client = &http.Client{Timeout: DefaultWebhookTimeout}
+	}
+	return &webhookSink{url: url, client: client}
+}
+
+// NewLogSink writes events to writer, one per line. Useful in development
+func NewLogSink(writer io.Writer) OutboxSink {
+	return &logSink{writer: writer}
+}
+
+// MemorySink keeps delivered events in memory. Intended for tests
+type MemorySink struct {
+	mutex  sync.Mutex
+	events []*OutboxEvent
+	err    error
+}
+
+// Deliver event, unless sink is failing
+func (sink *MemorySink) Deliver(ctx context.Context, event *OutboxEvent) error {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	if sink.err != nil {
+		return sink.err
+	}
+	sink.events = append(sink.events, event)
+	return nil
+}
+
+// Events delivered so far
+func (sink *MemorySink) Events() []*OutboxEvent {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	return append([]*OutboxEvent{}, sink.events...)
+}
+
+// FailWith makes deliveries fail with err, or succeed again if nil
+func (sink *MemorySink) FailWith(err error) {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	sink.err = err
+}
+
+// --------
+// Internal
+// --------
+
+type webhookSink struct {
+	url    string
+	client *http.Client
+}
+
+func (sink *webhookSink) Deliver(ctx context.Context, event *OutboxEvent) error {
+
+	request, requestErr := http.NewRequestWithContext(ctx, http.MethodPost, sink.url, bytes.NewReader(event.Payload))
+	if requestErr != nil {
+		return requestErr
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("X-Outbox-Event-Id", fmt.Sprint(event.ID))
+	request.Header.Set("X-Outbox-Topic", event.Topic)
+
+	response, callErr := sink.client.Do(request)
+	if callErr != nil {
+		return callErr
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, response.Body)
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("Webhook '%v' responded with status %d", sink.url, response.StatusCode)
+	}
+	return nil
+}
+
+type logSink struct {
+	mutex  sync.Mutex
+	writer io.Writer
+}
+
+func (sink *logSink) Deliver(ctx context.Context, event *OutboxEvent) error {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	_, err := fmt.Fprintf(sink.writer, "Outbox event %d '%v': %s\n", event.ID, event.Topic, event.Payload)
+	return err
+}
@@ -0,0 +1,304 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ---
+// Transactional Outbox
+//
+// Domain events are added to an outbox table within the same transaction
+// as the changes they describe, so they're never lost when the process
+// dies between commit and publish. A relay (usually run by an app worker)
+// claims pending events in a short transaction, selecting them with
+// SELECT ... FOR UPDATE SKIP LOCKED and leasing them by pushing their
+// available_at forward, so that multiple instances can relay concurrently.
+// Events are delivered to sinks after the claim is committed, so no locks
+// are held while sinks are called. Delivery is at-least-once: failed
+// events, and events whose lease expired before they were marked, are
+// retried with exponential backoff until MaxAttempts, after which they're
+// marked failed. Attempts are counted when events are claimed, so those of
+// relays dying before marking events count too. Expects
+// the following table (name is configurable via ContextIn):
+//
+//  CREATE TABLE outbox (
+//    id           BIGINT AUTO_INCREMENT PRIMARY KEY,
+//    topic        VARCHAR(255) NOT NULL,
+//    payload      TEXT NOT NULL,
+//    attempts     INT NOT NULL DEFAULT 0,
+//    last_error   VARCHAR(1024) NULL,
+//    created_at   DATETIME NOT NULL,
+//    available_at DATETIME NOT NULL,
+//    delivered_at DATETIME NULL,
+//    failed_at    DATETIME NULL,
+//    INDEX outbox_pending (delivered_at, failed_at, available_at)
+//  );
+// ---
+
+// DefaultOutboxTable value
+const DefaultOutboxTable = "outbox"
+
+// OutboxEvent is a domain event added to the outbox. Attempts to deliver
+// it include the current one
+type OutboxEvent struct {
+	ID        int64
+	Topic     string
+	Payload   json.RawMessage
+	Attempts  int
+	CreatedAt time.Time
+}
+
+// OutboxSink delivers events (e.g. to a webhook or message broker). An
+// error means event is retried later
+type OutboxSink interface {
+	Deliver(ctx context.Context, event *OutboxEvent) error
+}
+
+// OutboxRelayConfiguration of an OutboxRelay. Zero values are replaced
+// with defaults
+type OutboxRelayConfiguration struct {
+
+	// BatchSize of events claimed per transaction (defaults to 100)
+	BatchSize int
+
+	// LeaseDuration claimed events are hidden from other relays while
+	// being delivered (defaults to 5m). Must exceed time taken to deliver
+	// a batch, or events are delivered again
+	LeaseDuration time.Duration
+
+	// MaxAttempts before event is marked failed (defaults to 10)
+	MaxAttempts int
+
+	// RetryBackoff after first failed attempt, doubled after each further
+	// one (defaults to 1s) up to MaxRetryBackoff (defaults to 1h)
+	RetryBackoff    time.Duration
+	MaxRetryBackoff time.Duration
+}
+
+// Outbox of domain events
+type Outbox interface {
+
+	// Add event with JSON payload to outbox. Conn should be the
+	// transaction of the changes event describes
+	Add(conn Connection, topic string, payload interface{}) Error
+
+	// NewRelay delivering pending events to ALL sinks
+	NewRelay(database Database, sinks []OutboxSink, config *OutboxRelayConfiguration) OutboxRelay
+}
+
+// OutboxRelay delivers pending events of outbox to sinks
+type OutboxRelay interface {
+
+	// RelayBatch claims and delivers next batch of pending events.
+	// Returns number of claimed events
+	RelayBatch(ctx context.Context) (int, Error)
+
+	// RelayPending relays batches till no pending events are left or ctx
+	// is done
+	RelayPending(ctx context.Context) Error
+}
+
+// --------
+// Internal
+// --------
+
+type outbox struct {
+	table string
+	now   func() time.Time
+}
+
+// Add event with JSON payload to outbox
+func (outbox *outbox) Add(conn Connection, topic string, payload interface{}) Error {
+
+	payloadJSON, marshalError := json.Marshal(payload)
+	if marshalError != nil {
+		return WrapError(marshalError)
+	}
+
+	now := outbox.now().UTC()
+	_, insertError := conn.Exec(
+		fmt.Sprintf("INSERT INTO %s (topic, payload, created_at, available_at) VALUES (?, ?, ?, ?)", outbox.table),
+		topic, string(payloadJSON), now, now,
+	)
+	return WrapError(insertError)
+}
+
+// NewRelay delivering pending events to sinks
+func (outbox *outbox) NewRelay(database Database, sinks []OutboxSink, config *OutboxRelayConfiguration) OutboxRelay {
+
+	relay := &outboxRelay{outbox: outbox, database: database, sinks: sinks}
+	if config != nil {
+		relay.config = *config
+	}
+	if relay.config.BatchSize <= 0 {
+		relay.config.BatchSize = 100
+	}
+	if relay.config.LeaseDuration <= 0 {
+		relay.config.LeaseDuration = 5 * time.Minute
+	}
+	if relay.config.MaxAttempts <= 0 {
+		relay.config.MaxAttempts = 10
+	}
+	if relay.config.RetryBackoff <= 0 {
+		relay.config.RetryBackoff = time.Second
+	}
+	if relay.config.MaxRetryBackoff <= 0 {
+		relay.config.MaxRetryBackoff = time.Hour
+	}
+	return relay
+}
+
+type outboxRelay struct {
+	outbox   *outbox
+	database Database
+	sinks    []OutboxSink
+	config   OutboxRelayConfiguration
+}
+
+// RelayBatch claims and delivers next batch of pending events
+func (relay *outboxRelay) RelayBatch(ctx context.Context) (int, Error) {
+
+	var events []*OutboxEvent
+	txError := relay.database.WithTransaction(func(conn Connection) Error {
+		var claimError Error
+		events, claimError = relay.claim(conn)
+		return claimError
+	})
+	if txError != nil {
+		return 0, txError
+	}
+
+	// deliver outside of claiming transaction, so slow sinks hold no locks
+	conn := relay.database.GetConnection()
+	for _, event := range events {
+		if ctx.Err() != nil {
+			// unprocessed events are relayed again once their lease expires
+			break
+		}
+		if markError := relay.mark(conn, event, relay.deliver(ctx, event)); markError != nil {
+			return len(events), markError
+		}
+	}
+
+	return len(events), nil
+}
+
+// RelayPending relays batches till no pending events are left
+func (relay *outboxRelay) RelayPending(ctx context.Context) Error {
+	for ctx.Err() == nil {
+		claimed, err := relay.RelayBatch(ctx)
+		if err != nil || claimed < relay.config.BatchSize {
+			return err
+		}
+	}
+	return nil
+}
+
+// claim pending events by leasing them and counting the attempt. Rows
+// locked by other relays claiming concurrently are skipped
+func (relay *outboxRelay) claim(conn Connection) ([]*OutboxEvent, Error) {
+
+	now := relay.outbox.now().UTC()
+	events, selectError := relay.selectPending(conn, now)
+	if selectError != nil || len(events) == 0 {
+		return events, selectError
+	}
+
+	placeholders := make([]string, len(events))
+	args := []interface{}{now.Add(relay.config.LeaseDuration)}
+	for i, event := range events {
+		placeholders[i] = "?"
+		args = append(args, event.ID)
+	}
+	_, leaseError := conn.Exec(
+		fmt.Sprintf("UPDATE %s SET attempts = attempts + 1, available_at = ? WHERE id IN (%s)", relay.outbox.table, strings.Join(placeholders, ", ")),
+		args...,
+	)
+	if leaseError != nil {
+		return nil, WrapError(leaseError)
+	}
+
+	for _, event := range events {
+		event.Attempts++
+	}
+	return events, nil
+}
+
+// selectPending events, locking them till end of transaction
+func (relay *outboxRelay) selectPending(conn Connection, now time.Time) ([]*OutboxEvent, Error) {
+
+	rows, queryError := conn.Query(
+		fmt.Sprintf("SELECT id, topic, payload, attempts, created_at FROM %s WHERE delivered_at IS NULL AND failed_at IS NULL AND available_at <= ? ORDER BY id LIMIT ? FOR UPDATE SKIP LOCKED", relay.outbox.table),
+		now, relay.config.BatchSize,
+	)
+	if queryError != nil {
+		return nil, WrapError(queryError)
+	}
+	defer rows.Close()
+
+	events := []*OutboxEvent{}
+	for rows.Next() {
+		event := &OutboxEvent{}
+		var payload string
+		if scanError := rows.Scan(&event.ID, &event.Topic, &payload, &event.Attempts, &event.CreatedAt); scanError != nil {
+			return nil, WrapError(scanError)
+		}
+		event.Payload = json.RawMessage(payload)
+		events = append(events, event)
+	}
+
+	return events, WrapError(rows.Err())
+}
+
+// deliver event to all sinks, stopping at first failure
+func (relay *outboxRelay) deliver(ctx context.Context, event *OutboxEvent) error {
+	for _, sink := range relay.sinks {
+		if err := sink.Deliver(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mark event delivered, or schedule retry after failed delivery. Error of
+// failed delivery is kept in last_error. Attempts were counted by claim,
+// so events are failed by their stored count, which relays claiming them
+// after a lease expired may have raised
+func (relay *outboxRelay) mark(conn Connection, event *OutboxEvent, deliveryError error) Error {
+
+	now := relay.outbox.now().UTC()
+
+	if deliveryError == nil {
+		_, updateError := conn.Exec(
+			fmt.Sprintf("UPDATE %s SET delivered_at = ? WHERE id = ?", relay.outbox.table),
+			now, event.ID,
+		)
+		return WrapError(updateError)
+	}
+
+	lastError := deliveryError.Error()
+	if len(lastError) > 1024 {
+		lastError = lastError[:1024]
+	}
+	_, updateError := conn.Exec(
+		fmt.Sprintf("UPDATE %s SET last_error = ?, available_at = ?, failed_at = CASE WHEN attempts >= ? THEN ? END WHERE id = ?", relay.outbox.table),
+		lastError, now.Add(relay.backoff(event.Attempts)), relay.config.MaxAttempts, now, event.ID,
+	)
+	return WrapError(updateError)
+}
+
+// backoff before next attempt, after specified number of failed attempts
+func (relay *outboxRelay) backoff(attempts int) time.Duration {
+	backoff := relay.config.RetryBackoff
+	for i := 1; i < attempts && backoff < relay.config.MaxRetryBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > relay.config.MaxRetryBackoff {
+		return relay.config.MaxRetryBackoff
+	}
+	return backoff
+}
@@ -0,0 +1,179 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/saharsh-samples/go-mux-sql-starter/test"
+)
+
+const (
+	outboxClaim   = "SELECT id, topic, payload, attempts, created_at FROM outbox WHERE delivered_at IS NULL AND failed_at IS NULL AND available_at <= ? ORDER BY id LIMIT ? FOR UPDATE SKIP LOCKED"
+	outboxLease   = "UPDATE outbox SET attempts = attempts + 1, available_at = ? WHERE id IN (?, ?)"
+	outboxSuccess = "UPDATE outbox SET delivered_at = ? WHERE id = ?"
+	outboxFailure = "UPDATE outbox SET last_error = ?, available_at = ?, failed_at = CASE WHEN attempts >= ? THEN ? END WHERE id = ?"
+)
+
+var outboxColumns = []string{"id", "topic", "payload", "attempts", "created_at"}
+
+var outboxNow = time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+func TestOutbox_Add(t *testing.T) {
+
+	// arrange
+	handle, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	dbCtx := Bootstrap(&ContextIn{DatabaseHandle: handle})
+	database := dbCtx.Database
+	outbox := dbCtx.Outbox.(*outbox)
+	outbox.now = func() time.Time { return outboxNow }
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO outbox (topic, payload, created_at, available_at) VALUES (?, ?, ?, ?)").
+		WithArgs("thing.created", `{"Name":"thing","Version":1}`, outboxNow, outboxNow).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	// act
+	err := database.WithTransaction(func(conn Connection) Error {
+		return outbox.Add(conn, "thing.created", map[string]interface{}{"Name": "thing", "Version": 1})
+	})
+
+	// assert
+	test.AssertTrue("Expected no error", err == nil, t)
+	test.AssertTrue("Expected all expectations to be met", mock.ExpectationsWereMet() == nil, t)
+}
+
+func TestOutboxRelay_RelayBatch(t *testing.T) {
+
+	// arrange
+	handle, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	dbCtx := Bootstrap(&ContextIn{DatabaseHandle: handle})
+	database := dbCtx.Database
+	outbox := dbCtx.Outbox.(*outbox)
+	outbox.now = func() time.Time { return outboxNow }
+	sink := &MemorySink{}
+	relay := outbox.NewRelay(database, []OutboxSink{sink}, &OutboxRelayConfiguration{BatchSize: 2})
+	mock.ExpectBegin()
+	mock.ExpectQuery(outboxClaim).WithArgs(outboxNow, 2).WillReturnRows(sqlmock.NewRows(outboxColumns).
+		AddRow(1, "thing.created", `{"Name":"a"}`, 0, outboxNow).
+		AddRow(2, "thing.created", `{"Name":"b"}`, 3, outboxNow))
+	mock.ExpectExec(outboxLease).WithArgs(outboxNow.Add(5*time.Minute), 1, 2).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	// delivered once claim is committed
+	mock.ExpectExec(outboxSuccess).WithArgs(outboxNow, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(outboxSuccess).WithArgs(outboxNow, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	// act
+	claimed, err := relay.RelayBatch(context.Background())
+
+	// assert
+	test.AssertTrue("Expected no error", err == nil, t)
+	test.AssertEquals("", 2, claimed, t)
+	test.AssertEquals("", 2, len(sink.Events()), t)
+	test.AssertEquals("", `{"Name":"b"}`, string(sink.Events()[1].Payload), t)
+	test.AssertEquals("Expected claimed attempt to be counted", 4, sink.Events()[1].Attempts, t)
+	test.AssertTrue("Expected all expectations to be met", mock.ExpectationsWereMet() == nil, t)
+}
+
+func TestOutboxRelay_retries_failed_deliveries(t *testing.T) {
+
+	// arrange
+	handle, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	dbCtx := Bootstrap(&ContextIn{DatabaseHandle: handle})
+	database := dbCtx.Database
+	outbox := dbCtx.Outbox.(*outbox)
+	outbox.now = func() time.Time { return outboxNow }
+	sink := &MemorySink{}
+	sink.FailWith(errors.New("broker unavailable"))
+	relay := outbox.NewRelay(database, []OutboxSink{sink}, &OutboxRelayConfiguration{MaxAttempts: 3, RetryBackoff: time.Second, LeaseDuration: time.Minute})
+	mock.ExpectBegin()
+	mock.ExpectQuery(outboxClaim).WithArgs(outboxNow, 100).WillReturnRows(sqlmock.NewRows(outboxColumns).
+		AddRow(1, "thing.created", `{}`, 1, outboxNow).
+		AddRow(2, "thing.created", `{}`, 2, outboxNow))
+	mock.ExpectExec(outboxLease).WithArgs(outboxNow.Add(time.Minute), 1, 2).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+	mock.ExpectExec(outboxFailure).WithArgs("broker unavailable", outboxNow.Add(2*time.Second), 3, outboxNow, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(outboxFailure).WithArgs("broker unavailable", outboxNow.Add(4*time.Second), 3, outboxNow, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	// act
+	claimed, err := relay.RelayBatch(context.Background())
+
+	// assert
+	test.AssertTrue("Expected no error", err == nil, t)
+	test.AssertEquals("", 2, claimed, t)
+	test.AssertEquals("", 0, len(sink.Events()), t)
+	test.AssertTrue("Expected all expectations to be met", mock.ExpectationsWereMet() == nil, t)
+	test.AssertEquals("", time.Hour, relay.(*outboxRelay).backoff(100), t)
+}
+
+func TestOutboxRelay_RelayPending(t *testing.T) {
+
+	// arrange
+	handle, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	dbCtx := Bootstrap(&ContextIn{DatabaseHandle: handle})
+	database := dbCtx.Database
+	outbox := dbCtx.Outbox.(*outbox)
+	outbox.now = func() time.Time { return outboxNow }
+	relay := outbox.NewRelay(database, []OutboxSink{&MemorySink{}}, &OutboxRelayConfiguration{BatchSize: 1})
+	mock.ExpectBegin()
+	mock.ExpectQuery(outboxClaim).WithArgs(outboxNow, 1).WillReturnRows(sqlmock.NewRows(outboxColumns).AddRow(1, "thing.created", `{}`, 0, outboxNow))
+	mock.ExpectExec("UPDATE outbox SET attempts = attempts + 1, available_at = ? WHERE id IN (?)").WithArgs(outboxNow.Add(5*time.Minute), 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec(outboxSuccess).WithArgs(outboxNow, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectBegin()
+	mock.ExpectQuery(outboxClaim).WithArgs(outboxNow, 1).WillReturnRows(sqlmock.NewRows(outboxColumns))
+	mock.ExpectCommit()
+
+	// act
+	err := relay.RelayPending(context.Background())
+
+	// assert
+	test.AssertTrue("Expected no error", err == nil, t)
+	test.AssertTrue("Expected all expectations to be met", mock.ExpectationsWereMet() == nil, t)
+}
+
+func TestWebhookSink(t *testing.T) {
+
+	// arrange
+	var topic, eventID, body string
+	status := http.StatusAccepted
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		topic, eventID = r.Header.Get("X-Outbox-Topic"), r.Header.Get("X-Outbox-Event-Id")
+		buffer := &bytes.Buffer{}
+		buffer.ReadFrom(r.Body)
+		body = buffer.String()
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+	sink := NewWebhookSink(server.URL, nil)
+	event := &OutboxEvent{ID: 7, Topic: "thing.created", Payload: []byte(`{"Name":"thing"}`)}
+
+	// act
+	delivered := sink.Deliver(context.Background(), event)
+	status = http.StatusServiceUnavailable
+	failed := sink.Deliver(context.Background(), event)
+
+	// assert
+	test.AssertTrue("Expected delivery", delivered == nil, t)
+	test.AssertEquals("", "thing.created", topic, t)
+	test.AssertEquals("", "7", eventID, t)
+	test.AssertEquals("", `{"Name":"thing"}`, body, t)
+	test.AssertTrue("Expected failure for non 2xx status", failed != nil, t)
+	test.AssertEquals("", DefaultWebhookTimeout, sink.(*webhookSink).client.Timeout, t)
+}
+
+func TestLogSink(t *testing.T) {
+
+	buffer := &bytes.Buffer{}
+
+	err := NewLogSink(buffer).Deliver(context.Background(), &OutboxEvent{ID: 7, Topic: "thing.created", Payload: []byte(`{}`)})
+
+	test.AssertTrue("Expected no error", err == nil, t)
+	test.AssertEquals("", "Outbox event 7 'thing.created': {}\n", buffer.String(), t)
+}
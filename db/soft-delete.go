@@ -0,0 +1,188 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// DeletedAtColumn is the default column of soft deleted tables
+const DeletedAtColumn = "deleted_at"
+
+// ---
+// Soft Delete
+//
+// Opt-in mode of a table where deleting a row sets its deleted_at
+// timestamp instead of removing it. Deleted rows are excluded from
+// lookups and lists, can be restored, and are purged (hard deleted) once
+// older than a retention period. Expects a nullable column, e.g.
+//
+//  ALTER TABLE things ADD COLUMN deleted_at DATETIME NULL;
+//
+// Methods take a Connection so that they can run inside transactions
+// ---
+
+// SoftDelete of rows of a table
+type SoftDelete struct {
+	Table    string
+	Column   string
+	IDColumn string
+
+	now func() time.Time
+}
+
+// NewSoftDelete for table with 'deleted_at' and 'id' columns
+func NewSoftDelete(table string) *SoftDelete {
+	return &SoftDelete{Table: table, Column: DeletedAtColumn, IDColumn: "id", now: time.Now}
+}
+
+// NotDeleted returns condition excluding deleted rows, for hand written
+// queries
+func (softDelete *SoftDelete) NotDeleted() string {
+	return softDelete.Column + " IS NULL"
+}
+
+// Scope query to rows that aren't deleted, unless includeDeleted is set.
+// Condition, on column qualified with Table, is ANDed to the parenthesized
+// WHERE clause of query, or added as one. Trailing clauses like ORDER BY,
+// LIMIT or FOR UPDATE are kept after it. WHERE clauses of subqueries are
+// left alone, and queries must refer to Table by name, not by an alias
+func (softDelete *SoftDelete) Scope(query string, includeDeleted bool) string {
+	if includeDeleted {
+		return query
+	}
+
+	condition := softDelete.Table + "." + softDelete.NotDeleted()
+	where := topLevelKeyword(query, 0, "WHERE")
+	end := topLevelKeyword(query, where+1, trailingClauses...)
+	if end < 0 {
+		end = len(query)
+	}
+
+	var scoped string
+	if where >= 0 {
+		scoped = query[:where] + "WHERE (" + strings.TrimSpace(query[where+len("WHERE"):end]) + ") AND " + condition
+	} else {
+		scoped = strings.TrimRightFunc(query[:end], unicode.IsSpace) + " WHERE " + condition
+	}
+	if end < len(query) {
+		scoped += " " + query[end:]
+	}
+	return scoped
+}
+
+// trailingClauses start with these keywords, which can follow WHERE
+var trailingClauses = []string{"GROUP", "HAVING", "WINDOW", "ORDER", "LIMIT", "OFFSET", "FETCH", "FOR", "LOCK"}
+
+// topLevelKeyword returns index of first of keywords in query, from index
+// start on, outside of parentheses and quotes, or -1 if there is none
+func topLevelKeyword(query string, start int, keywords ...string) int {
+
+	depth := 0
+	var quote byte
+	for i := 0; i < len(query); i++ {
+		switch c := query[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case depth == 0 && i >= start:
+			for _, keyword := range keywords {
+				if isKeywordAt(query, i, keyword) {
+					return i
+				}
+			}
+		}
+	}
+	return -1
+}
+
+// isKeywordAt checks if keyword starts at index i of query and isn't part
+// of an identifier
+func isKeywordAt(query string, i int, keyword string) bool {
+	end := i + len(keyword)
+	if end > len(query) || !strings.EqualFold(query[i:end], keyword) {
+		return false
+	}
+	return (i == 0 || !isIdentifierChar(query[i-1])) && (end == len(query) || !isIdentifierChar(query[end]))
+}
+
+func isIdentifierChar(c byte) bool {
+	return c == '_' || c == '.' || c == '$' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// LookupOne row in DB, excluding deleted rows unless includeDeleted is set
+func (softDelete *SoftDelete) LookupOne(conn Connection, query string, args []interface{}, dest []interface{}, includeDeleted bool) Error {
+	return LookupOne(conn, softDelete.Scope(query, includeDeleted), args, dest)
+}
+
+// DeleteOne row in DB by setting its deleted_at timestamp. Fails with
+// NotFound if row doesn't exist or is already deleted
+func (softDelete *SoftDelete) DeleteOne(conn Connection, id interface{}, query string, dest []interface{}) Error {
+
+	// look up data being deleted
+	lookupError := softDelete.LookupOne(conn, query, []interface{}{id}, dest, false)
+	if lookupError != nil {
+		return lookupError
+	}
+
+	// delete
+	deletion, deleteError := conn.Exec(
+		fmt.Sprintf("UPDATE %s SET %s = ? WHERE %s = ? AND %s IS NULL", softDelete.Table, softDelete.Column, softDelete.IDColumn, softDelete.Column),
+		softDelete.now().UTC(), id,
+	)
+	if deleteError != nil {
+		return WrapError(deleteError)
+	}
+
+	// row was deleted concurrently
+	affected, rowsError := deletion.RowsAffected()
+	if rowsError != nil {
+		return WrapError(rowsError)
+	}
+	if affected == 0 {
+		return NewNotFoundError("")
+	}
+
+	return nil
+}
+
+// RestoreOne deleted row in DB and look up restored data. Restoring a row
+// that isn't deleted is a no-op. Fails with NotFound if row doesn't exist
+func (softDelete *SoftDelete) RestoreOne(conn Connection, id interface{}, query string, dest []interface{}) Error {
+
+	// restore
+	_, restoreError := conn.Exec(
+		fmt.Sprintf("UPDATE %s SET %s = NULL WHERE %s = ? AND %s IS NOT NULL", softDelete.Table, softDelete.Column, softDelete.IDColumn, softDelete.Column),
+		id,
+	)
+	if restoreError != nil {
+		return WrapError(restoreError)
+	}
+
+	// look up restored data using id
+	return softDelete.LookupOne(conn, query, []interface{}{id}, dest, false)
+}
+
+// Purge (hard delete) rows deleted longer than retention ago. Returns
+// number of purged rows. Usually run periodically by a background worker
+func (softDelete *SoftDelete) Purge(conn Connection, retention time.Duration) (int64, Error) {
+
+	purge, purgeError := conn.Exec(
+		fmt.Sprintf("DELETE FROM %s WHERE %s IS NOT NULL AND %s < ?", softDelete.Table, softDelete.Column, softDelete.Column),
+		softDelete.now().UTC().Add(-retention),
+	)
+	if purgeError != nil {
+		return 0, WrapError(purgeError)
+	}
+
+	purged, rowsError := purge.RowsAffected()
+	return purged, WrapError(rowsError)
+}
@@ -0,0 +1,129 @@
+package db
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/saharsh-samples/go-mux-sql-starter/test"
+)
+
+var deletedAt = time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+func TestSoftDelete_Scope(t *testing.T) {
+
+	softDelete := NewSoftDelete("things")
+
+	test.AssertEquals("", "SELECT name FROM things WHERE (id = ?) AND things.deleted_at IS NULL", softDelete.Scope("SELECT name FROM things WHERE id = ?", false), t)
+	test.AssertEquals("", "SELECT name FROM things WHERE things.deleted_at IS NULL", softDelete.Scope("SELECT name FROM things", false), t)
+	test.AssertEquals("", "SELECT name FROM things WHERE id = ?", softDelete.Scope("SELECT name FROM things WHERE id = ?", true), t)
+
+	// OR conditions don't escape the scope
+	test.AssertEquals("", "SELECT name FROM things WHERE (id = ? OR name = ?) AND things.deleted_at IS NULL",
+		softDelete.Scope("SELECT name FROM things WHERE id = ? OR name = ?", false), t)
+
+	// WHERE at start of line, after tab, or in lower case
+	test.AssertEquals("", "SELECT name FROM things\nWHERE (id = ?) AND things.deleted_at IS NULL", softDelete.Scope("SELECT name FROM things\nWHERE id = ?", false), t)
+	test.AssertEquals("", "SELECT name FROM things\tWHERE (id = ?) AND things.deleted_at IS NULL",
+		softDelete.Scope("SELECT name FROM things\twhere id = ?", false), t)
+
+	// subqueries, quoted text and identifiers containing 'where'
+	test.AssertEquals("", "SELECT name FROM things WHERE (id IN (SELECT thing_id FROM tags WHERE tag = 'a')) AND things.deleted_at IS NULL",
+		softDelete.Scope("SELECT name FROM things WHERE id IN (SELECT thing_id FROM tags WHERE tag = 'a')", false), t)
+	test.AssertEquals("", "SELECT somewhere FROM things WHERE (name = ' WHERE ') AND things.deleted_at IS NULL",
+		softDelete.Scope("SELECT somewhere FROM things WHERE name = ' WHERE '", false), t)
+
+	// trailing clauses stay last
+	test.AssertEquals("", "SELECT name FROM things WHERE (kind = ?) AND things.deleted_at IS NULL ORDER BY name LIMIT 10",
+		softDelete.Scope("SELECT name FROM things WHERE kind = ? ORDER BY name LIMIT 10", false), t)
+	test.AssertEquals("", "SELECT name FROM things WHERE (id = ?) AND things.deleted_at IS NULL FOR UPDATE",
+		softDelete.Scope("SELECT name FROM things WHERE id = ?\nFOR UPDATE", false), t)
+	test.AssertEquals("", "SELECT kind, COUNT(*) FROM things WHERE things.deleted_at IS NULL GROUP BY kind",
+		softDelete.Scope("SELECT kind, COUNT(*) FROM things GROUP BY kind", false), t)
+	test.AssertEquals("", "SELECT name, order_id FROM things WHERE things.deleted_at IS NULL",
+		softDelete.Scope("SELECT name, order_id FROM things", false), t)
+}
+
+func TestSoftDelete_DeleteOne(t *testing.T) {
+
+	// arrange
+	conn, mock := newMockConnection(t)
+	softDelete := NewSoftDelete("things")
+	softDelete.now = func() time.Time { return deletedAt }
+	expectScopedThing := func() *sqlmock.ExpectedQuery {
+		return mock.ExpectQuery(softDelete.Scope(thingQuery, false)).WithArgs(1)
+	}
+	expectScopedThing().WillReturnRows(sqlmock.NewRows([]string{"name", "version"}).AddRow("thing", 1))
+	mock.ExpectExec("UPDATE things SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL").WithArgs(deletedAt, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	expectScopedThing().WillReturnRows(sqlmock.NewRows([]string{"name", "version"}))
+	var name string
+	var version int64
+
+	// act
+	deleted := softDelete.DeleteOne(conn, 1, thingQuery, []interface{}{&name, &version})
+	again := softDelete.DeleteOne(conn, 1, thingQuery, []interface{}{&name, &version})
+
+	// assert
+	test.AssertTrue("Expected no error", deleted == nil, t)
+	test.AssertEquals("", "thing", name, t)
+	test.AssertEquals("Expected deleted row to be excluded", NotFound, again.Type(), t)
+	test.AssertTrue("Expected all expectations to be met", mock.ExpectationsWereMet() == nil, t)
+}
+
+func TestSoftDelete_RestoreOne(t *testing.T) {
+
+	// arrange
+	conn, mock := newMockConnection(t)
+	softDelete := NewSoftDelete("things")
+	softDelete.now = func() time.Time { return deletedAt }
+	mock.ExpectExec("UPDATE things SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(softDelete.Scope(thingQuery, false)).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"name", "version"}).AddRow("thing", 1))
+	var name string
+	var version int64
+
+	// act
+	err := softDelete.RestoreOne(conn, 1, thingQuery, []interface{}{&name, &version})
+
+	// assert
+	test.AssertTrue("Expected no error", err == nil, t)
+	test.AssertEquals("", "thing", name, t)
+	test.AssertTrue("Expected all expectations to be met", mock.ExpectationsWereMet() == nil, t)
+}
+
+func TestSoftDelete_Purge(t *testing.T) {
+
+	// arrange
+	conn, mock := newMockConnection(t)
+	softDelete := NewSoftDelete("things")
+	softDelete.now = func() time.Time { return deletedAt }
+	mock.ExpectExec("DELETE FROM things WHERE deleted_at IS NOT NULL AND deleted_at < ?").
+		WithArgs(deletedAt.Add(-30 * 24 * time.Hour)).WillReturnResult(sqlmock.NewResult(0, 3))
+
+	// act
+	purged, err := softDelete.Purge(conn, 30*24*time.Hour)
+
+	// assert
+	test.AssertTrue("Expected no error", err == nil, t)
+	test.AssertEquals("", int64(3), purged, t)
+	test.AssertTrue("Expected all expectations to be met", mock.ExpectationsWereMet() == nil, t)
+}
+
+func TestListQuery_ExcludeDeleted(t *testing.T) {
+
+	softDelete := NewSoftDelete("things")
+
+	query, _ := ParseListQuery(testListFields, []string{"status:eq:active"}, "")
+	where, args := query.ExcludeDeleted(softDelete).Where()
+	test.AssertEquals("", "WHERE status = ? AND deleted_at IS NULL", where, t)
+	test.AssertEquals("", "[active]", fmt.Sprint(args), t)
+
+	query.IncludeDeleted = true
+	where, _ = query.Where()
+	test.AssertEquals("", "WHERE status = ?", where, t)
+
+	query, _ = ParseListQuery(testListFields, nil, "")
+	where, args = query.ExcludeDeleted(softDelete).Where()
+	test.AssertEquals("", "WHERE deleted_at IS NULL", where, t)
+	test.AssertEquals("", 0, len(args), t)
+}
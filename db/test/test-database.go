@@ -63,6 +63,23 @@ type MockDatabase struct {
 	DeleteOneQuery      string
 	DeleteOneDestWriter func([]interface{})
 	DeleteOneError      db.Error
+
+	// UpdateOneVersioned
+	UpdateOneVersionedID         interface{}
+	UpdateOneVersionedVersion    int64
+	UpdateOneVersionedCommand    string
+	UpdateOneVersionedArgs       []interface{}
+	UpdateOneVersionedQuery      string
+	UpdateOneVersionedDestWriter func([]interface{})
+	UpdateOneVersionedError      db.Error
+
+	// DeleteOneVersioned
+	DeleteOneVersionedID         interface{}
+	DeleteOneVersionedVersion    int64
+	DeleteOneVersionedCommand    string
+	DeleteOneVersionedQuery      string
+	DeleteOneVersionedDestWriter func([]interface{})
+	DeleteOneVersionedError      db.Error
 }
 
 // GetConnection to run database commands directly
@@ -125,3 +142,28 @@ func (database *MockDatabase) DeleteOne(id interface{}, deleteCommand string, qu
 	}
 	return database.DeleteOneError
 }
+
+// UpdateOneVersioned row in DB
+func (database *MockDatabase) UpdateOneVersioned(id interface{}, version int64, updateCommand string, updateArgs []interface{}, query string, dest []interface{}) db.Error {
+	database.UpdateOneVersionedID = id
+	database.UpdateOneVersionedVersion = version
+	database.UpdateOneVersionedCommand = updateCommand
+	database.UpdateOneVersionedArgs = updateArgs
+	database.UpdateOneVersionedQuery = query
+	if database.UpdateOneVersionedDestWriter != nil {
+		database.UpdateOneVersionedDestWriter(dest)
+	}
+	return database.UpdateOneVersionedError
+}
+
+// DeleteOneVersioned row in DB
+func (database *MockDatabase) DeleteOneVersioned(id interface{}, version int64, deleteCommand string, query string, dest []interface{}) db.Error {
+	database.DeleteOneVersionedID = id
+	database.DeleteOneVersionedVersion = version
+	database.DeleteOneVersionedCommand = deleteCommand
+	database.DeleteOneVersionedQuery = query
+	if database.DeleteOneVersionedDestWriter != nil {
+		database.DeleteOneVersionedDestWriter(dest)
+	}
+	return database.DeleteOneVersionedError
+}
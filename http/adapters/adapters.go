@@ -0,0 +1,160 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+
+	"github.com/saharsh-samples/go-mux-sql-starter/db"
+	base "github.com/saharsh-samples/go-mux-sql-starter/http"
+	"github.com/saharsh-samples/go-mux-sql-starter/http/utils"
+)
+
+// NoRequest can be used as request type of handlers without input
+type NoRequest struct {
+	utils.AlwaysValidJSON
+}
+
+// NoContent can be used as response type of handlers without output.
+// Responses have status 204 No Content
+type NoContent struct{}
+
+// HandlerFunc is a typed handler. Req is bound from the request (see
+// utils.Binder) and Resp is written in the media type negotiated with the
+// request
+type HandlerFunc[Req utils.JSONBody, Resp any] func(ctx context.Context, request Req) (Resp, error)
+
+// Adapter does the untyped work for typed handlers
+type Adapter interface {
+
+	// Bind request into value, writing error response if that fails
+	Bind(r *http.Request, value interface{}, w http.ResponseWriter) error
+
+	// Respond with body in media type negotiated with the request
+	Respond(w http.ResponseWriter, r *http.Request, statusCode int, body interface{})
+
+	// Fail with error response for err. db.Error and utils.FieldErrors are
+	// mapped to matching responses, anything else is logged and reported
+	// as an internal error without details
+	Fail(w http.ResponseWriter, err error)
+}
+
+// Handle adapts typed handler to an http handler func responding with
+// statusCode on success. Panics if Req isn't a pointer to a struct
+func Handle[Req utils.JSONBody, Resp any](adapter Adapter, statusCode int, handler HandlerFunc[Req, Resp]) func(w http.ResponseWriter, r *http.Request) {
+
+	// binding would fail on every request otherwise
+	if t := reflect.TypeOf((*Req)(nil)).Elem(); t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
+		panic(fmt.Sprintf("Request type of typed handlers must be a pointer to a struct, not %v", t))
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		request := newValue[Req]()
+		if adapter.Bind(r, request, w) != nil {
+			return
+		}
+
+		response, err := handler(r.Context(), request)
+		if err != nil {
+			adapter.Fail(w, err)
+			return
+		}
+
+		if _, noContent := any(response).(NoContent); noContent {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		adapter.Respond(w, r, statusCode, response)
+	}
+}
+
+// Register typed handler with agent. Request and response types are added
+// to route metadata. Success status is the lowest 2xx status of responses
+// added with base.WithResponse, otherwise it depends on method: 201 for
+// POST, 204 for NoContent responses and 200 for anything else. Panics if
+// Req isn't a pointer to a struct
+func Register[Req utils.JSONBody, Resp any](agent base.RoutesAgent, adapter Adapter, method string, path string, handler HandlerFunc[Req, Resp], opts ...base.RouteOption) {
+
+	statusCode := successStatus[Resp](base.NewRouteMetadata(method, path, opts...))
+
+	documented := []base.RouteOption{}
+	if _, none := any(newValue[Req]()).(*NoRequest); !none {
+		documented = append(documented, base.WithRequestBody(newValue[Req]()))
+	}
+	var response Resp
+	if _, noContent := any(response).(NoContent); noContent {
+		documented = append(documented, base.WithResponse(statusCode, nil))
+	} else {
+		documented = append(documented, base.WithResponse(statusCode, response))
+	}
+
+	// explicitly specified options take precedence
+	agent.Register(method, path, Handle(adapter, statusCode, handler), append(documented, opts...)...)
+}
+
+// --------
+// Internal
+// --------
+
+type adapter struct {
+	binder    utils.Binder
+	jsonUtils utils.JSONUtils
+}
+
+func (a *adapter) Bind(r *http.Request, value interface{}, w http.ResponseWriter) error {
+	return a.binder.Bind(r, value, w)
+}
+
+func (a *adapter) Respond(w http.ResponseWriter, r *http.Request, statusCode int, body interface{}) {
+	a.jsonUtils.SetResponse(w, r, statusCode, body)
+}
+
+func (a *adapter) Fail(w http.ResponseWriter, err error) {
+
+	var dbErr db.Error
+	var fieldErrors utils.FieldErrors
+	switch {
+	case errors.As(err, &dbErr):
+		a.jsonUtils.HandleDatabaseError(w, dbErr)
+	case errors.As(err, &fieldErrors):
+		a.jsonUtils.ValidationFailed(w, fieldErrors)
+	default:
+		// details of unexpected errors are not for clients
+		fmt.Printf("Request failed: %v\n", err)
+		a.jsonUtils.InternalError(w, "Unexpected error")
+	}
+}
+
+// newValue of T, allocating what T points to if it's a pointer
+func newValue[T any]() T {
+	var value T
+	if t := reflect.TypeOf(value); t != nil && t.Kind() == reflect.Ptr {
+		return reflect.New(t.Elem()).Interface().(T)
+	}
+	return value
+}
+
+func successStatus[Resp any](metadata *base.RouteMetadata) int {
+
+	statusCode := 0
+	for code := range metadata.Responses {
+		if code >= 200 && code < 300 && (statusCode == 0 || code < statusCode) {
+			statusCode = code
+		}
+	}
+	if statusCode != 0 {
+		return statusCode
+	}
+
+	var response Resp
+	if _, noContent := any(response).(NoContent); noContent {
+		return http.StatusNoContent
+	}
+	if metadata.Method == http.MethodPost {
+		return http.StatusCreated
+	}
+	return http.StatusOK
+}
@@ -0,0 +1,167 @@
+package adapters
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/saharsh-samples/go-mux-sql-starter/db"
+	base "github.com/saharsh-samples/go-mux-sql-starter/http"
+	httpTest "github.com/saharsh-samples/go-mux-sql-starter/http/test"
+	"github.com/saharsh-samples/go-mux-sql-starter/http/utils"
+	"github.com/saharsh-samples/go-mux-sql-starter/test"
+)
+
+type widgetRequest struct {
+	utils.AlwaysValidJSON
+	ID   int64  `path:"id"`
+	Name string `validate:"required"`
+}
+
+type widget struct {
+	ID   int64
+	Name string
+}
+
+type widgetTags map[string]string
+
+func (tags widgetTags) Validate() error {
+	return nil
+}
+
+func invoke(agent httpTest.MockRoutesAgent, method string, path string, vars map[string]string, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, "/widgets", strings.NewReader(body))
+	r = mux.SetURLVars(r, vars)
+	w := httptest.NewRecorder()
+	agent.GetHandlerFunc(method, path)(w, r)
+	return w
+}
+
+func TestRegister(t *testing.T) {
+
+	// Arrange
+	agent := httpTest.NewMockRoutesAgent()
+	adapter := Bootstrap(&ContextIn{}).Adapter
+	var received *widgetRequest
+
+	// Act
+	Register(agent, adapter, http.MethodPost, "/widgets/{id}", func(ctx context.Context, request *widgetRequest) (*widget, error) {
+		received = request
+		return &widget{ID: request.ID, Name: request.Name}, nil
+	}, base.WithName("create-widget"))
+	w := invoke(agent, http.MethodPost, "/widgets/{id}", map[string]string{"id": "7"}, `{"Name": "gear"}`)
+
+	// Assert
+	agent.VerifyThatRoute(t, "/widgets/{id}").ForHTTPMethod(http.MethodPost).HasName("create-widget")
+	test.AssertEquals("Request is bound", int64(7), received.ID, t)
+	test.AssertEquals("POST responds with 201", http.StatusCreated, w.Code, t)
+	response := &widget{}
+	json.Unmarshal(w.Body.Bytes(), response)
+	test.AssertEquals("Response is written", widget{ID: 7, Name: "gear"}, *response, t)
+}
+
+func TestRegister_with_documented_status(t *testing.T) {
+
+	// Arrange
+	agent := httpTest.NewMockRoutesAgent()
+	adapter := Bootstrap(&ContextIn{}).Adapter
+
+	// Act
+	Register(agent, adapter, http.MethodPost, "/widgets/{id}", func(ctx context.Context, request *widgetRequest) (*widget, error) {
+		return &widget{}, nil
+	}, base.WithResponse(http.StatusAccepted, &widget{}), base.WithResponse(http.StatusOK, &widget{}))
+	w := invoke(agent, http.MethodPost, "/widgets/{id}", map[string]string{"id": "7"}, `{"Name": "gear"}`)
+
+	// Assert
+	test.AssertEquals("Lowest documented 2xx status is used", http.StatusOK, w.Code, t)
+}
+
+func TestRegister_without_request_or_response(t *testing.T) {
+
+	// Arrange
+	agent := httpTest.NewMockRoutesAgent()
+	adapter := Bootstrap(&ContextIn{}).Adapter
+	called := false
+
+	// Act
+	Register(agent, adapter, http.MethodDelete, "/widgets", func(ctx context.Context, request *NoRequest) (NoContent, error) {
+		called = true
+		return NoContent{}, nil
+	})
+	w := invoke(agent, http.MethodDelete, "/widgets", nil, "")
+
+	// Assert
+	test.AssertTrue("Handler is called", called, t)
+	test.AssertEquals("NoContent responds with 204", http.StatusNoContent, w.Code, t)
+	test.AssertEquals("NoContent has no body", 0, w.Body.Len(), t)
+}
+
+func TestRegister_with_unbindable_request_type(t *testing.T) {
+
+	// Arrange
+	agent := httpTest.NewMockRoutesAgent()
+	adapter := Bootstrap(&ContextIn{}).Adapter
+
+	// Act and Assert
+	defer test.AssertPanic("Expected request type that isn't a pointer to a struct to be rejected", t)
+	Register(agent, adapter, http.MethodPost, "/widgets", func(ctx context.Context, request widgetTags) (*widget, error) {
+		return &widget{}, nil
+	})
+}
+
+func TestRegister_with_invalid_request(t *testing.T) {
+
+	// Arrange
+	agent := httpTest.NewMockRoutesAgent()
+	adapter := Bootstrap(&ContextIn{}).Adapter
+	called := false
+
+	// Act
+	Register(agent, adapter, http.MethodPut, "/widgets/{id}", func(ctx context.Context, request *widgetRequest) (*widget, error) {
+		called = true
+		return &widget{}, nil
+	})
+	w := invoke(agent, http.MethodPut, "/widgets/{id}", map[string]string{"id": "x"}, `{}`)
+
+	// Assert
+	test.AssertFalse("Handler isn't called", called, t)
+	test.AssertEquals("Invalid request responds with 400", http.StatusBadRequest, w.Code, t)
+	errorMsg := &utils.ErrorMessage{}
+	json.Unmarshal(w.Body.Bytes(), errorMsg)
+	test.AssertEquals("Field errors are reported", 1, len(errorMsg.Errors), t)
+	test.AssertEquals("Path parameter is reported", "id", errorMsg.Errors[0].Field, t)
+}
+
+func TestRegister_with_errors(t *testing.T) {
+
+	for _, tc := range []struct {
+		err        error
+		statusCode int
+	}{
+		{db.NewNotFoundError("no widget"), http.StatusNotFound},
+		{db.NewForbiddenError("not yours"), http.StatusForbidden},
+		{utils.FieldErrors{{Field: "Name", Code: "taken", Message: "is taken"}}, http.StatusBadRequest},
+		{errors.New("boom"), http.StatusInternalServerError},
+	} {
+
+		// Arrange
+		agent := httpTest.NewMockRoutesAgent()
+		adapter := Bootstrap(&ContextIn{}).Adapter
+		returned := tc.err
+
+		// Act
+		Register(agent, adapter, http.MethodGet, "/widgets", func(ctx context.Context, request *NoRequest) (*widget, error) {
+			return nil, returned
+		})
+		w := invoke(agent, http.MethodGet, "/widgets", nil, "")
+
+		// Assert
+		test.AssertEquals("Error is mapped to status for "+tc.err.Error(), tc.statusCode, w.Code, t)
+		test.AssertFalse("Expected no internal details", strings.Contains(w.Body.String(), "boom"), t)
+	}
+}
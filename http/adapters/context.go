@@ -0,0 +1,38 @@
+package adapters
+
+import (
+	"github.com/saharsh-samples/go-mux-sql-starter/http/utils"
+)
+
+// ContextIn describes dependecies needed by this package
+type ContextIn struct {
+	JSONUtils utils.JSONUtils
+	Binder    utils.Binder
+}
+
+// ContextOut describes dependencies exported by this package
+type ContextOut struct {
+	Adapter Adapter
+}
+
+// Bootstrap initializes this module with ContextIn and exports
+// resulting ContextOut
+func Bootstrap(in *ContextIn) *ContextOut {
+
+	// default dependencies
+	jsonUtils, binder := in.JSONUtils, in.Binder
+	if jsonUtils == nil || binder == nil {
+		defaults := utils.Bootstrap(&utils.ContextIn{})
+		if jsonUtils == nil {
+			jsonUtils = defaults.JSONUtils
+		}
+		if binder == nil {
+			binder = defaults.Binder
+		}
+	}
+
+	out := &ContextOut{}
+	out.Adapter = &adapter{binder: binder, jsonUtils: jsonUtils}
+
+	return out
+}
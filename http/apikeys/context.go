@@ -0,0 +1,98 @@
+package apikeys
+
+import (
+	"time"
+
+	"github.com/saharsh-samples/go-mux-sql-starter/db"
+	base "github.com/saharsh-samples/go-mux-sql-starter/http"
+	"github.com/saharsh-samples/go-mux-sql-starter/http/utils"
+)
+
+// ContextIn describes dependecies needed by this package
+type ContextIn struct {
+	Database  db.Database
+	JSONUtils utils.JSONUtils
+	URLUtils  utils.URLUtils
+
+	// HashSecret keys the HMAC used to hash stored API keys. Required
+	HashSecret string
+
+	// KeyPrefix makes issued keys recognizable (defaults to DefaultKeyPrefix)
+	KeyPrefix string
+
+	// Table storing API keys (defaults to DefaultTable)
+	Table string
+
+	// AdminScope required by admin routes (defaults to DefaultAdminScope)
+	AdminScope string
+
+	// LastUsedInterval throttles writes of last use of a key (defaults to
+	// DefaultLastUsedInterval)
+	LastUsedInterval time.Duration
+
+	// ExemptPaths are served without API key verification (e.g. "/healthz")
+	ExemptPaths []string
+
+	// OnlyAuthRequiredRoutes limits verification to routes registered
+	// with http.RequiresAuth. Otherwise all routes are verified
+	OnlyAuthRequiredRoutes bool
+}
+
+// ContextOut describes dependencies exported by this package
+type ContextOut struct {
+	Manager               Manager
+	MiddlewaresToRegister base.Middlewares
+	RoutesToRegister      []base.Routes
+}
+
+// Bootstrap initializes this module with ContextIn and exports
+// resulting ContextOut
+func Bootstrap(in *ContextIn) *ContextOut {
+
+	if in.HashSecret == "" {
+		panic("apikeys: HashSecret is required")
+	}
+
+	// apply defaults
+	keyPrefix := in.KeyPrefix
+	if keyPrefix == "" {
+		keyPrefix = DefaultKeyPrefix
+	}
+	table := in.Table
+	if table == "" {
+		table = DefaultTable
+	}
+	adminScope := in.AdminScope
+	if adminScope == "" {
+		adminScope = DefaultAdminScope
+	}
+	lastUsedInterval := in.LastUsedInterval
+	if lastUsedInterval <= 0 {
+		lastUsedInterval = DefaultLastUsedInterval
+	}
+
+	manager := &manager{
+		database:         in.Database,
+		hashSecret:       []byte(in.HashSecret),
+		keyPrefix:        keyPrefix,
+		table:            table,
+		lastUsedInterval: lastUsedInterval,
+		now:              time.Now,
+	}
+
+	out := &ContextOut{}
+	out.Manager = manager
+	out.MiddlewaresToRegister = base.Middlewares{
+		(&middleware{
+			manager:                manager,
+			jsonUtils:              in.JSONUtils,
+			exemptPaths:            in.ExemptPaths,
+			onlyAuthRequiredRoutes: in.OnlyAuthRequiredRoutes,
+		}).Authenticate,
+	}
+	out.RoutesToRegister = []base.Routes{
+		&AdminRoutes{manager: manager, jsonUtils: in.JSONUtils, urlUtils: in.URLUtils, adminScope: adminScope},
+	}
+
+	return out
+}
@@ -0,0 +1,30 @@
+package apikeys
+
+import (
+	"testing"
+
+	"github.com/saharsh-samples/go-mux-sql-starter/test"
+)
+
+func TestBootstrap(t *testing.T) {
+
+	out := Bootstrap(&ContextIn{HashSecret: "s3cr3t"})
+
+	test.AssertFalse("", out.Manager == nil, t)
+	test.AssertEquals("", 1, len(out.MiddlewaresToRegister), t)
+	test.AssertEquals("", 1, len(out.RoutesToRegister), t)
+
+	manager := out.Manager.(*manager)
+	test.AssertEquals("", DefaultKeyPrefix, manager.keyPrefix, t)
+	test.AssertEquals("", DefaultTable, manager.table, t)
+	test.AssertEquals("", DefaultAdminScope, out.RoutesToRegister[0].(*AdminRoutes).adminScope, t)
+	test.AssertEquals("", DefaultLastUsedInterval, manager.lastUsedInterval, t)
+}
+
+func TestBootstrap_without_hash_secret(t *testing.T) {
+
+	// assert via defer
+	defer test.AssertPanic("Expected panic during Bootstrap", t)
+
+	Bootstrap(&ContextIn{})
+}
@@ -0,0 +1,378 @@
+package apikeys
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/saharsh-samples/go-mux-sql-starter/db"
+)
+
+// ---
+// API Key Management
+//
+// Keys have the format '<prefix>_<key id>_<secret>'. The prefix and key id
+// are stored in clear and make keys recognizable in logs and admin
+// listings. Only a keyed HMAC-SHA256 hash of the full key is stored, so
+// a leaked database can't be used to call the API. Expects the following
+// table (name is configurable via ContextIn):
+//
+//  CREATE TABLE api_keys (
+//    id           BIGINT AUTO_INCREMENT PRIMARY KEY,
+//    key_id       VARCHAR(16) NOT NULL UNIQUE,
+//    key_hash     CHAR(64) NOT NULL,
+//    prefix       VARCHAR(32) NOT NULL,
+//    owner        VARCHAR(255) NOT NULL,
+//    scopes       VARCHAR(1024) NOT NULL,
+//    created_at   DATETIME NOT NULL,
+//    expires_at   DATETIME NULL,
+//    last_used_at DATETIME NULL,
+//    revoked_at   DATETIME NULL
+//  );
+// ---
+
+// APIKey describes an issued key. The key itself is never stored
+type APIKey struct {
+	ID         int64
+	KeyID      string
+	Prefix     string
+	Owner      string
+	Scopes     []string
+	CreatedAt  time.Time
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+}
+
+// HasScope returns 'true' if key was granted specified scope
+func (key *APIKey) HasScope(scope string) bool {
+	for _, granted := range key.Scopes {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Manager issues, verifies and revokes API keys
+type Manager interface {
+	Issue(owner string, scopes []string, expiresAt *time.Time) (string, *APIKey, db.Error)
+	Verify(key string) (*APIKey, db.Error)
+	List(owner string, listQuery *db.ListQuery) ([]*APIKey, db.Error)
+	Lookup(id int64) (*APIKey, db.Error)
+	Rotate(id int64) (string, *APIKey, db.Error)
+	Revoke(id int64) db.Error
+}
+
+// ListFields API keys can be filtered and sorted by
+var ListFields = db.ListFields{
+	"ID":         "id",
+	"Prefix":     "prefix",
+	"Owner":      "owner",
+	"CreatedAt":  "created_at",
+	"ExpiresAt":  "expires_at",
+	"LastUsedAt": "last_used_at",
+	"RevokedAt":  "revoked_at",
+}
+
+// DefaultKeyPrefix value
+const DefaultKeyPrefix = "key"
+
+// DefaultTable value
+const DefaultTable = "api_keys"
+
+// DefaultLastUsedInterval value
+const DefaultLastUsedInterval = time.Minute
+
+const keyIDLength = 8
+const keySecretLength = 32
+
+var keyIDEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+type manager struct {
+	database   db.Database
+	hashSecret []byte
+	keyPrefix  string
+	table      string
+	now        func() time.Time
+
+	// last use is only written if older than this
+	lastUsedInterval time.Duration
+}
+
+func (manager *manager) columns() string {
+	return "id, key_id, prefix, owner, scopes, created_at, expires_at, last_used_at, revoked_at"
+}
+
+// Issue a new key. The returned key is only available at this point
+func (manager *manager) Issue(owner string, scopes []string, expiresAt *time.Time) (string, *APIKey, db.Error) {
+
+	if owner == "" {
+		return "", nil, db.NewBadRequestError("API key owner is required")
+	}
+
+	key, keyID, err := manager.generateKey()
+	if err != nil {
+		return "", nil, err
+	}
+
+	created := &apiKeyRow{}
+	createErr := manager.database.CreateOne(
+		fmt.Sprintf("INSERT INTO %s (key_id, key_hash, prefix, owner, scopes, created_at, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?)", manager.table),
+		[]interface{}{keyID, manager.hash(key), manager.keyPrefix, owner, strings.Join(scopes, " "), manager.now(), expiresAt},
+		fmt.Sprintf("SELECT %s FROM %s WHERE id = ?", manager.columns(), manager.table),
+		created.destinations(),
+	)
+	if createErr != nil {
+		return "", nil, createErr
+	}
+
+	return key, created.toAPIKey(), nil
+}
+
+// Verify key and record its use, at most once per lastUsedInterval.
+// Returns NotFound error for unknown, revoked or expired keys
+func (manager *manager) Verify(key string) (*APIKey, db.Error) {
+
+	keyID, ok := manager.parseKeyID(key)
+	if !ok {
+		return nil, db.NewNotFoundError("Invalid API key")
+	}
+
+	// look up by key id and compare hashes
+	row := &apiKeyRow{}
+	var keyHash string
+	lookupErr := manager.database.LookupOne(
+		fmt.Sprintf("SELECT %s, key_hash FROM %s WHERE key_id = ?", manager.columns(), manager.table),
+		[]interface{}{keyID},
+		append(row.destinations(), &keyHash),
+	)
+	if lookupErr != nil {
+		if lookupErr.Type() == db.NotFound {
+			return nil, db.NewNotFoundError("Invalid API key")
+		}
+		return nil, lookupErr
+	}
+	found := row.toAPIKey()
+
+	if !hmac.Equal([]byte(manager.hash(key)), []byte(keyHash)) {
+		return nil, db.NewNotFoundError("Invalid API key")
+	}
+	now := manager.now()
+	if found.RevokedAt != nil {
+		return nil, db.NewNotFoundError("API key has been revoked")
+	}
+	if found.ExpiresAt != nil && !now.Before(*found.ExpiresAt) {
+		return nil, db.NewNotFoundError("API key has expired")
+	}
+
+	// track last use, skipping the write if recorded recently
+	if found.LastUsedAt != nil && now.Sub(*found.LastUsedAt) < manager.lastUsedInterval {
+		return found, nil
+	}
+	_, updateErr := manager.database.GetConnection().Exec(
+		fmt.Sprintf("UPDATE %s SET last_used_at = ? WHERE id = ?", manager.table), now, found.ID)
+	if updateErr != nil {
+		return nil, db.WrapError(updateErr)
+	}
+	found.LastUsedAt = &now
+
+	return found, nil
+}
+
+// List keys of owner. All keys are listed if owner is empty. Keys can be
+// further filtered and sorted by listQuery (optional) using ListFields
+func (manager *manager) List(owner string, listQuery *db.ListQuery) ([]*APIKey, db.Error) {
+
+	conditions := []string{}
+	args := []interface{}{}
+	if owner != "" {
+		conditions = append(conditions, "owner = ?")
+		args = append(args, owner)
+	}
+	orderBy := "ORDER BY id"
+	if listQuery != nil {
+		if filters, filterArgs := listQuery.Conditions(); filters != "" {
+			conditions = append(conditions, filters)
+			args = append(args, filterArgs...)
+		}
+		if sorts := listQuery.OrderBy(); sorts != "" {
+			orderBy = sorts + ", id"
+		}
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM %s", manager.columns(), manager.table)
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " " + orderBy
+
+	rows, queryErr := manager.database.GetConnection().Query(query, args...)
+	if queryErr != nil {
+		return nil, db.WrapError(queryErr)
+	}
+	defer rows.Close()
+
+	keys := []*APIKey{}
+	for rows.Next() {
+		row := &apiKeyRow{}
+		if scanErr := rows.Scan(row.destinations()...); scanErr != nil {
+			return nil, db.WrapError(scanErr)
+		}
+		keys = append(keys, row.toAPIKey())
+	}
+
+	return keys, db.WrapError(rows.Err())
+}
+
+// Lookup key by id
+func (manager *manager) Lookup(id int64) (*APIKey, db.Error) {
+	row := &apiKeyRow{}
+	lookupErr := manager.database.LookupOne(
+		fmt.Sprintf("SELECT %s FROM %s WHERE id = ?", manager.columns(), manager.table),
+		[]interface{}{id},
+		row.destinations(),
+	)
+	if lookupErr != nil {
+		return nil, lookupErr
+	}
+	return row.toAPIKey(), nil
+}
+
+// Rotate revokes key with specified id and issues a replacement with the
+// same owner, scopes and expiry. Expired keys can't be rotated, as their
+// replacement would be expired too
+func (manager *manager) Rotate(id int64) (string, *APIKey, db.Error) {
+
+	var key string
+	rotated := &apiKeyRow{}
+
+	txErr := manager.database.WithTransaction(func(conn db.Connection) db.Error {
+
+		existingRow := &apiKeyRow{}
+		lookupErr := db.LookupOne(conn,
+			fmt.Sprintf("SELECT %s FROM %s WHERE id = ? FOR UPDATE", manager.columns(), manager.table),
+			[]interface{}{id},
+			existingRow.destinations(),
+		)
+		if lookupErr != nil {
+			return lookupErr
+		}
+		existing := existingRow.toAPIKey()
+		if existing.RevokedAt != nil {
+			return db.NewBadRequestError("API key has been revoked")
+		}
+		if existing.ExpiresAt != nil && !manager.now().Before(*existing.ExpiresAt) {
+			return db.NewBadRequestError("API key has expired")
+		}
+
+		_, revokeErr := conn.Exec(fmt.Sprintf("UPDATE %s SET revoked_at = ? WHERE id = ?", manager.table), manager.now(), id)
+		if revokeErr != nil {
+			return db.WrapError(revokeErr)
+		}
+
+		var keyID string
+		var keyErr db.Error
+		key, keyID, keyErr = manager.generateKey()
+		if keyErr != nil {
+			return keyErr
+		}
+
+		return db.CreateOne(conn,
+			fmt.Sprintf("INSERT INTO %s (key_id, key_hash, prefix, owner, scopes, created_at, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?)", manager.table),
+			[]interface{}{keyID, manager.hash(key), manager.keyPrefix, existing.Owner, strings.Join(existing.Scopes, " "), manager.now(), existing.ExpiresAt},
+			fmt.Sprintf("SELECT %s FROM %s WHERE id = ?", manager.columns(), manager.table),
+			rotated.destinations(),
+		)
+	})
+	if txErr != nil {
+		return "", nil, txErr
+	}
+
+	return key, rotated.toAPIKey(), nil
+}
+
+// Revoke key with specified id
+func (manager *manager) Revoke(id int64) db.Error {
+	revoked := &apiKeyRow{}
+	return manager.database.UpdateOne(
+		id,
+		fmt.Sprintf("UPDATE %s SET revoked_at = COALESCE(revoked_at, ?) WHERE id = ?", manager.table),
+		[]interface{}{manager.now(), id},
+		fmt.Sprintf("SELECT %s FROM %s WHERE id = ?", manager.columns(), manager.table),
+		revoked.destinations(),
+	)
+}
+
+// ---
+// Helpers
+// ---
+
+func (manager *manager) generateKey() (key string, keyID string, err db.Error) {
+
+	random := make([]byte, keyIDLength*5/8+keySecretLength)
+	if _, randErr := rand.Read(random); randErr != nil {
+		return "", "", db.WrapError(randErr)
+	}
+
+	keyID = strings.ToLower(keyIDEncoding.EncodeToString(random[:keyIDLength*5/8]))
+	secret := base64.RawURLEncoding.EncodeToString(random[keyIDLength*5/8:])
+	return manager.keyPrefix + "_" + keyID + "_" + secret, keyID, nil
+}
+
+func (manager *manager) parseKeyID(key string) (string, bool) {
+	if !strings.HasPrefix(key, manager.keyPrefix+"_") {
+		return "", false
+	}
+	parts := strings.SplitN(strings.TrimPrefix(key, manager.keyPrefix+"_"), "_", 2)
+	if len(parts) != 2 || len(parts[0]) != keyIDLength || parts[1] == "" {
+		return "", false
+	}
+	return parts[0], true
+}
+
+func (manager *manager) hash(key string) string {
+	mac := hmac.New(sha256.New, manager.hashSecret)
+	mac.Write([]byte(key))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// apiKeyRow holds scan targets for APIKey columns
+type apiKeyRow struct {
+	key        APIKey
+	scopes     string
+	expiresAt  sql.NullTime
+	lastUsedAt sql.NullTime
+	revokedAt  sql.NullTime
+}
+
+func (row *apiKeyRow) destinations() []interface{} {
+	return []interface{}{
+		&row.key.ID, &row.key.KeyID, &row.key.Prefix, &row.key.Owner, &row.scopes, &row.key.CreatedAt,
+		&row.expiresAt, &row.lastUsedAt, &row.revokedAt,
+	}
+}
+
+func (row *apiKeyRow) toAPIKey() *APIKey {
+	key := row.key
+	key.Scopes = strings.Fields(row.scopes)
+	key.ExpiresAt = nullTimeToPointer(row.expiresAt)
+	key.LastUsedAt = nullTimeToPointer(row.lastUsedAt)
+	key.RevokedAt = nullTimeToPointer(row.revokedAt)
+	return &key
+}
+
+func nullTimeToPointer(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	value := t.Time
+	return &value
+}
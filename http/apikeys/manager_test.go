@@ -0,0 +1,241 @@
+package apikeys
+
+import (
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/saharsh-samples/go-mux-sql-starter/db"
+	dbTest "github.com/saharsh-samples/go-mux-sql-starter/db/test"
+	"github.com/saharsh-samples/go-mux-sql-starter/test"
+)
+
+var testNow = time.Date(2020, 4, 1, 12, 0, 0, 0, time.UTC)
+
+var apiKeyColumns = []string{"id", "key_id", "prefix", "owner", "scopes", "created_at", "expires_at", "last_used_at", "revoked_at"}
+
+func TestIssue(t *testing.T) {
+
+	database, mock, closer := dbTest.NewDatabaseWithMockConnection(t)
+	defer closer()
+	manager := Bootstrap(&ContextIn{Database: database, HashSecret: "s3cr3t", KeyPrefix: "test", LastUsedInterval: time.Minute}).Manager.(*manager)
+	manager.now = func() time.Time { return testNow }
+
+	var keyID, keyHash string
+	mock.ExpectExec("INSERT INTO api_keys (key_id, key_hash, prefix, owner, scopes, created_at, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?)").
+		WithArgs(capture{&keyID}, capture{&keyHash}, "test", "billing-service", "invoices:read invoices:write", testNow, nil).
+		WillReturnResult(sqlmock.NewResult(7, 1))
+	mock.ExpectQuery("SELECT id, key_id, prefix, owner, scopes, created_at, expires_at, last_used_at, revoked_at FROM api_keys WHERE id = ?").
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows(apiKeyColumns).AddRow(7, "abcdefgh", "test", "billing-service", "invoices:read invoices:write", testNow, nil, nil, nil))
+
+	key, issued, err := manager.Issue("billing-service", []string{"invoices:read", "invoices:write"}, nil)
+
+	test.AssertTrue("Expected issue to succeed", err == nil, t)
+	test.AssertTrue("Expected key to have visible prefix", strings.HasPrefix(key, "test_"+keyID+"_"), t)
+	test.AssertEquals("", 8, len(keyID), t)
+	test.AssertEquals("", manager.hash(key), keyHash, t)
+	test.AssertFalse("Expected key itself to not be stored", strings.Contains(keyHash, key), t)
+	test.AssertEquals("", int64(7), issued.ID, t)
+	test.AssertTrue("", issued.HasScope("invoices:write"), t)
+	test.AssertFalse("", issued.HasScope("admin"), t)
+	test.AssertTrue("Expected no expiry", issued.ExpiresAt == nil, t)
+	test.AssertTrue("Expected all db expectations to be met", mock.ExpectationsWereMet() == nil, t)
+}
+
+func TestIssue_without_owner(t *testing.T) {
+	_, _, err := Bootstrap(&ContextIn{HashSecret: "s3cr3t"}).Manager.Issue("", nil, nil)
+	test.AssertEquals("", db.BadRequest, err.Type(), t)
+}
+
+func TestVerify(t *testing.T) {
+
+	database, mock, closer := dbTest.NewDatabaseWithMockConnection(t)
+	defer closer()
+	manager := Bootstrap(&ContextIn{Database: database, HashSecret: "s3cr3t", KeyPrefix: "test", LastUsedInterval: time.Minute}).Manager.(*manager)
+	manager.now = func() time.Time { return testNow }
+
+	key := "test_abcdefgh_c2VjcmV0LXBhcnQtb2YtdGhlLWtleQ"
+	verifyQuery := "SELECT id, key_id, prefix, owner, scopes, created_at, expires_at, last_used_at, revoked_at, key_hash FROM api_keys WHERE key_id = ?"
+	row := func(expiresAt interface{}, revokedAt interface{}, hash string) *sqlmock.Rows {
+		return sqlmock.NewRows(append(apiKeyColumns, "key_hash")).
+			AddRow(7, "abcdefgh", "test", "billing-service", "invoices:read", testNow, expiresAt, nil, revokedAt, hash)
+	}
+	usedAt := func(lastUsedAt time.Time) *sqlmock.Rows {
+		return sqlmock.NewRows(append(apiKeyColumns, "key_hash")).
+			AddRow(7, "abcdefgh", "test", "billing-service", "invoices:read", testNow, nil, lastUsedAt, nil, manager.hash(key))
+	}
+
+	// valid
+	mock.ExpectQuery(verifyQuery).WithArgs("abcdefgh").WillReturnRows(row(nil, nil, manager.hash(key)))
+	mock.ExpectExec("UPDATE api_keys SET last_used_at = ? WHERE id = ?").
+		WithArgs(testNow, 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	verified, err := manager.Verify(key)
+	test.AssertTrue("Expected verification to succeed", err == nil, t)
+	test.AssertEquals("", "billing-service", verified.Owner, t)
+	test.AssertEquals("", testNow, *verified.LastUsedAt, t)
+
+	// recently used (no write)
+	mock.ExpectQuery(verifyQuery).WithArgs("abcdefgh").WillReturnRows(usedAt(testNow.Add(-30 * time.Second)))
+	verified, err = manager.Verify(key)
+	test.AssertTrue("Expected verification to succeed", err == nil, t)
+	test.AssertEquals("", testNow.Add(-30*time.Second), *verified.LastUsedAt, t)
+
+	// used a while ago
+	mock.ExpectQuery(verifyQuery).WithArgs("abcdefgh").WillReturnRows(usedAt(testNow.Add(-time.Minute)))
+	mock.ExpectExec("UPDATE api_keys SET last_used_at = ? WHERE id = ?").
+		WithArgs(testNow, 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	verified, err = manager.Verify(key)
+	test.AssertTrue("Expected verification to succeed", err == nil, t)
+	test.AssertEquals("", testNow, *verified.LastUsedAt, t)
+
+	// hash mismatch
+	mock.ExpectQuery(verifyQuery).WithArgs("abcdefgh").WillReturnRows(row(nil, nil, manager.hash("something-else")))
+	_, err = manager.Verify(key)
+	test.AssertEquals("", "Invalid API key", err.Error(), t)
+
+	// revoked
+	mock.ExpectQuery(verifyQuery).WithArgs("abcdefgh").WillReturnRows(row(nil, testNow, manager.hash(key)))
+	_, err = manager.Verify(key)
+	test.AssertEquals("", "API key has been revoked", err.Error(), t)
+
+	// expired
+	mock.ExpectQuery(verifyQuery).WithArgs("abcdefgh").WillReturnRows(row(testNow, nil, manager.hash(key)))
+	_, err = manager.Verify(key)
+	test.AssertEquals("", "API key has expired", err.Error(), t)
+	test.AssertEquals("", db.NotFound, err.Type(), t)
+
+	// unknown
+	mock.ExpectQuery(verifyQuery).WithArgs("abcdefgh").WillReturnRows(sqlmock.NewRows(apiKeyColumns))
+	_, err = manager.Verify(key)
+	test.AssertEquals("", "Invalid API key", err.Error(), t)
+
+	// malformed (no db access)
+	for _, malformed := range []string{"", "other_abcdefgh_secret", "test_abc_secret", "test_abcdefgh_"} {
+		_, err = manager.Verify(malformed)
+		test.AssertEquals("", db.NotFound, err.Type(), t)
+	}
+
+	test.AssertTrue("Expected all db expectations to be met", mock.ExpectationsWereMet() == nil, t)
+}
+
+func TestList(t *testing.T) {
+
+	database, mock, closer := dbTest.NewDatabaseWithMockConnection(t)
+	defer closer()
+	manager := Bootstrap(&ContextIn{Database: database, HashSecret: "s3cr3t", KeyPrefix: "test", LastUsedInterval: time.Minute}).Manager.(*manager)
+	manager.now = func() time.Time { return testNow }
+
+	mock.ExpectQuery("SELECT id, key_id, prefix, owner, scopes, created_at, expires_at, last_used_at, revoked_at FROM api_keys WHERE owner = ? ORDER BY id").
+		WithArgs("billing-service").
+		WillReturnRows(sqlmock.NewRows(apiKeyColumns).
+			AddRow(1, "aaaaaaaa", "test", "billing-service", "", testNow, nil, testNow, nil).
+			AddRow(2, "bbbbbbbb", "test", "billing-service", "a b", testNow, nil, nil, testNow))
+
+	keys, err := manager.List("billing-service", nil)
+	test.AssertTrue("Expected list to succeed", err == nil, t)
+	test.AssertEquals("", 2, len(keys), t)
+	test.AssertEquals("", 0, len(keys[0].Scopes), t)
+	test.AssertEquals("", testNow, *keys[0].LastUsedAt, t)
+	test.AssertEquals("", 2, len(keys[1].Scopes), t)
+	test.AssertEquals("", testNow, *keys[1].RevokedAt, t)
+	test.AssertTrue("Expected all db expectations to be met", mock.ExpectationsWereMet() == nil, t)
+}
+
+func TestList_with_list_query(t *testing.T) {
+
+	database, mock, closer := dbTest.NewDatabaseWithMockConnection(t)
+	defer closer()
+	manager := Bootstrap(&ContextIn{Database: database, HashSecret: "s3cr3t", KeyPrefix: "test", LastUsedInterval: time.Minute}).Manager.(*manager)
+	manager.now = func() time.Time { return testNow }
+	listQuery, _ := db.ParseListQuery(ListFields, []string{"CreatedAt:gte:2024-01-01"}, "-LastUsedAt")
+
+	mock.ExpectQuery("SELECT id, key_id, prefix, owner, scopes, created_at, expires_at, last_used_at, revoked_at FROM api_keys WHERE owner = ? AND created_at >= ? ORDER BY last_used_at DESC, id").
+		WithArgs("billing-service", "2024-01-01").
+		WillReturnRows(sqlmock.NewRows(apiKeyColumns))
+
+	keys, err := manager.List("billing-service", listQuery)
+	test.AssertTrue("Expected list to succeed", err == nil, t)
+	test.AssertEquals("", 0, len(keys), t)
+	test.AssertTrue("Expected all db expectations to be met", mock.ExpectationsWereMet() == nil, t)
+}
+
+func TestRotate(t *testing.T) {
+
+	database, mock, closer := dbTest.NewDatabaseWithMockConnection(t)
+	defer closer()
+	manager := Bootstrap(&ContextIn{Database: database, HashSecret: "s3cr3t", KeyPrefix: "test", LastUsedInterval: time.Minute}).Manager.(*manager)
+	manager.now = func() time.Time { return testNow }
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, key_id, prefix, owner, scopes, created_at, expires_at, last_used_at, revoked_at FROM api_keys WHERE id = ? FOR UPDATE").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(apiKeyColumns).AddRow(1, "aaaaaaaa", "test", "billing-service", "a b", testNow, nil, nil, nil))
+	mock.ExpectExec("UPDATE api_keys SET revoked_at = ? WHERE id = ?").
+		WithArgs(testNow, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO api_keys (key_id, key_hash, prefix, owner, scopes, created_at, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?)").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "test", "billing-service", "a b", testNow, nil).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectQuery("SELECT id, key_id, prefix, owner, scopes, created_at, expires_at, last_used_at, revoked_at FROM api_keys WHERE id = ?").
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows(apiKeyColumns).AddRow(2, "bbbbbbbb", "test", "billing-service", "a b", testNow, nil, nil, nil))
+	mock.ExpectCommit()
+
+	key, rotated, err := manager.Rotate(1)
+	test.AssertTrue("Expected rotate to succeed", err == nil, t)
+	test.AssertTrue("Expected new key", strings.HasPrefix(key, "test_"), t)
+	test.AssertEquals("", int64(2), rotated.ID, t)
+	test.AssertTrue("Expected all db expectations to be met", mock.ExpectationsWereMet() == nil, t)
+}
+
+func TestRotate_expired_key(t *testing.T) {
+
+	database, mock, closer := dbTest.NewDatabaseWithMockConnection(t)
+	defer closer()
+	manager := Bootstrap(&ContextIn{Database: database, HashSecret: "s3cr3t", KeyPrefix: "test", LastUsedInterval: time.Minute}).Manager.(*manager)
+	manager.now = func() time.Time { return testNow }
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, key_id, prefix, owner, scopes, created_at, expires_at, last_used_at, revoked_at FROM api_keys WHERE id = ? FOR UPDATE").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(apiKeyColumns).AddRow(1, "aaaaaaaa", "test", "billing-service", "a b", testNow, testNow.Add(-time.Hour), nil, nil))
+	mock.ExpectRollback()
+
+	_, _, err := manager.Rotate(1)
+	test.AssertEquals("", db.BadRequest, err.Type(), t)
+	test.AssertEquals("", "API key has expired", err.Error(), t)
+	test.AssertTrue("Expected all db expectations to be met", mock.ExpectationsWereMet() == nil, t)
+}
+
+func TestRevoke(t *testing.T) {
+
+	database, mock, closer := dbTest.NewDatabaseWithMockConnection(t)
+	defer closer()
+	manager := Bootstrap(&ContextIn{Database: database, HashSecret: "s3cr3t", KeyPrefix: "test", LastUsedInterval: time.Minute}).Manager.(*manager)
+	manager.now = func() time.Time { return testNow }
+
+	mock.ExpectExec("UPDATE api_keys SET revoked_at = COALESCE(revoked_at, ?) WHERE id = ?").
+		WithArgs(testNow, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT id, key_id, prefix, owner, scopes, created_at, expires_at, last_used_at, revoked_at FROM api_keys WHERE id = ?").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(apiKeyColumns).AddRow(1, "aaaaaaaa", "test", "billing-service", "a b", testNow, nil, nil, testNow))
+
+	test.AssertTrue("Expected revoke to succeed", manager.Revoke(1) == nil, t)
+	test.AssertTrue("Expected all db expectations to be met", mock.ExpectationsWereMet() == nil, t)
+}
+
+// capture is a sqlmock.Argument that records the actual value
+type capture struct{ value *string }
+
+func (c capture) Match(value driver.Value) bool {
+	str, ok := value.(string)
+	*c.value = str
+	return ok
+}
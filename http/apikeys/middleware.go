@@ -0,0 +1,123 @@
+package apikeys
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/saharsh-samples/go-mux-sql-starter/db"
+	base "github.com/saharsh-samples/go-mux-sql-starter/http"
+	"github.com/saharsh-samples/go-mux-sql-starter/http/utils"
+	commonUtils "github.com/saharsh-samples/go-mux-sql-starter/utils"
+)
+
+// APIKeyHeader is the header checked for API keys when Authorization
+// header is absent or uses another scheme (e.g. Basic)
+const APIKeyHeader = "X-API-Key"
+
+type contextKey int
+
+const principalContextKey contextKey = iota
+
+// FromContext returns the verified API key of current request (if any)
+func FromContext(ctx context.Context) (*APIKey, bool) {
+	key, ok := ctx.Value(principalContextKey).(*APIKey)
+	return key, ok
+}
+
+// NewContext returns a copy of ctx carrying specified API key
+func NewContext(ctx context.Context, key *APIKey) context.Context {
+	return context.WithValue(ctx, principalContextKey, key)
+}
+
+type middleware struct {
+	manager                Manager
+	jsonUtils              utils.JSONUtils
+	exemptPaths            []string
+	onlyAuthRequiredRoutes bool
+}
+
+// Authenticate verifies API key of every request not matching an exempt
+// path and makes it available to handlers via FromContext
+func (middleware *middleware) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if !commonUtils.IsStringMissingInSlice(r.URL.Path, middleware.exemptPaths) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if middleware.onlyAuthRequiredRoutes {
+			if metadata, found := base.GetRouteMetadata(r); !found || !metadata.AuthRequired {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		key := extractKey(r)
+		if key == "" {
+			middleware.jsonUtils.Unauthorized(w, "API key is required")
+			return
+		}
+
+		verified, err := middleware.manager.Verify(key)
+		if err != nil {
+			if err.Type() == db.NotFound {
+				middleware.jsonUtils.Unauthorized(w, err.Error())
+			} else {
+				middleware.jsonUtils.HandleDatabaseError(w, err)
+			}
+			return
+		}
+
+		// owner of key is the actor of audited changes
+		ctx := db.NewAuditContext(NewContext(r.Context(), verified), verified.Owner, "")
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RequireScopes returns a middleware rejecting requests whose API key
+// wasn't granted ALL of the specified scopes
+func RequireScopes(jsonUtils utils.JSONUtils, scopes ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !hasScopes(w, r, jsonUtils, scopes...) {
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// hasScopes writes an error response and returns 'false' if API key of
+// request is missing any of the specified scopes
+func hasScopes(w http.ResponseWriter, r *http.Request, jsonUtils utils.JSONUtils, scopes ...string) bool {
+
+	key, ok := FromContext(r.Context())
+	if !ok {
+		jsonUtils.Unauthorized(w, "API key is required")
+		return false
+	}
+
+	for _, scope := range scopes {
+		if !key.HasScope(scope) {
+			jsonUtils.Forbidden(w, "API key is missing required scope '"+scope+"'")
+			return false
+		}
+	}
+
+	return true
+}
+
+func extractKey(r *http.Request) string {
+
+	authorization := strings.TrimSpace(r.Header.Get("Authorization"))
+	if authorization != "" {
+		parts := strings.SplitN(authorization, " ", 2)
+		if len(parts) == 2 && (strings.EqualFold(parts[0], "Bearer") || strings.EqualFold(parts[0], "ApiKey")) {
+			return strings.TrimSpace(parts[1])
+		}
+	}
+
+	return strings.TrimSpace(r.Header.Get(APIKeyHeader))
+}
@@ -0,0 +1,157 @@
+package apikeys
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/saharsh-samples/go-mux-sql-starter/db"
+	base "github.com/saharsh-samples/go-mux-sql-starter/http"
+	"github.com/saharsh-samples/go-mux-sql-starter/http/utils"
+	"github.com/saharsh-samples/go-mux-sql-starter/test"
+	commonUtils "github.com/saharsh-samples/go-mux-sql-starter/utils"
+)
+
+// fakeManager is a test friendly implementation of Manager
+type fakeManager struct {
+	keys     map[string]*APIKey
+	verified []string
+
+	issuedOwner  string
+	issuedScopes []string
+	rotatedID    int64
+	revokedID    int64
+	listQuery    *db.ListQuery
+	err          db.Error
+}
+
+func (m *fakeManager) Issue(owner string, scopes []string, expiresAt *time.Time) (string, *APIKey, db.Error) {
+	m.issuedOwner, m.issuedScopes = owner, scopes
+	return "test_new", &APIKey{ID: 9, Owner: owner, Scopes: scopes}, m.err
+}
+
+func (m *fakeManager) Verify(key string) (*APIKey, db.Error) {
+	m.verified = append(m.verified, key)
+	if found, ok := m.keys[key]; ok {
+		return found, nil
+	}
+	return nil, db.NewNotFoundError("Invalid API key")
+}
+
+func (m *fakeManager) List(owner string, listQuery *db.ListQuery) ([]*APIKey, db.Error) {
+	m.listQuery = listQuery
+	return []*APIKey{{ID: 1, Owner: owner}}, m.err
+}
+
+func (m *fakeManager) Lookup(id int64) (*APIKey, db.Error) {
+	return &APIKey{ID: id}, m.err
+}
+
+func (m *fakeManager) Rotate(id int64) (string, *APIKey, db.Error) {
+	m.rotatedID = id
+	return "test_rotated", &APIKey{ID: id + 1}, m.err
+}
+
+func (m *fakeManager) Revoke(id int64) db.Error {
+	m.revokedID = id
+	return m.err
+}
+
+func TestAuthenticate(t *testing.T) {
+
+	manager := &fakeManager{keys: map[string]*APIKey{"good": {ID: 1, Owner: "billing", Scopes: []string{"read"}}}}
+	var principal *APIKey
+	var actor string
+	authenticate := &middleware{manager: manager, jsonUtils: utils.Bootstrap(&utils.ContextIn{}).JSONUtils, exemptPaths: []string{"/healthz"}}
+	handler := authenticate.Authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, _ = FromContext(r.Context())
+		actor = db.AuditActorFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	serve := func(path string, headers ...string) int {
+		principal = nil
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		for i := 0; i+1 < len(headers); i += 2 {
+			r.Header.Set(headers[i], headers[i+1])
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	test.AssertEquals("", 200, serve("/things", "Authorization", "Bearer good"), t)
+	test.AssertEquals("", int64(1), principal.ID, t)
+	test.AssertEquals("", "billing", actor, t)
+	test.AssertEquals("", 200, serve("/things", "Authorization", "ApiKey good"), t)
+	test.AssertEquals("", 200, serve("/things", "X-API-Key", "good"), t)
+
+	test.AssertEquals("", 200, serve("/things", "Authorization", "Basic dXNlcjpwYXNz", "X-API-Key", "good"), t)
+
+	test.AssertEquals("", 401, serve("/things"), t)
+	test.AssertEquals("", 401, serve("/things", "Authorization", "Basic good"), t)
+	test.AssertEquals("", 401, serve("/things", "X-API-Key", "bad"), t)
+	test.AssertTrue("Expected no principal", principal == nil, t)
+
+	// exempt path
+	test.AssertEquals("", 200, serve("/healthz"), t)
+	test.AssertEquals("", 5, len(manager.verified), t)
+}
+
+func TestRequireScopes(t *testing.T) {
+
+	handler := RequireScopes(utils.Bootstrap(&utils.ContextIn{}).JSONUtils, "read", "write")(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }))
+
+	serve := func(key *APIKey) int {
+		r := httptest.NewRequest(http.MethodGet, "/things", nil)
+		if key != nil {
+			r = r.WithContext(NewContext(r.Context(), key))
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	test.AssertEquals("", 200, serve(&APIKey{Scopes: []string{"read", "write"}}), t)
+	test.AssertEquals("", 403, serve(&APIKey{Scopes: []string{"read"}}), t)
+	test.AssertEquals("", 401, serve(nil), t)
+}
+
+func TestAuthenticate_only_auth_required_routes(t *testing.T) {
+
+	manager := &fakeManager{keys: map[string]*APIKey{}}
+	authenticate := &middleware{manager: manager, jsonUtils: utils.Bootstrap(&utils.ContextIn{}).JSONUtils, exemptPaths: []string{"/healthz"}}
+	authenticate.onlyAuthRequiredRoutes = true
+
+	// route metadata is attached by the server after routing
+	server := base.Bootstrap(&base.ContextIn{
+		Port:                  0,
+		RoutesToRegister:      []base.Routes{&authTestRoutes{}},
+		MiddlewaresToRegister: base.Middlewares{authenticate.Authenticate},
+	}).Server
+	go server.Run()
+	commonUtils.WaitTill(func() bool { return server.IsReady() }, 10)
+	defer server.Shutdown()
+	port, _ := server.Port()
+
+	get := func(path string) int {
+		resp, err := http.Get(fmt.Sprintf("http://localhost:%d%s", port, path))
+		test.AssertTrue("Expected no errors doing GET "+path, err == nil, t)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	test.AssertEquals("", 200, get("/public"), t)
+	test.AssertEquals("", 401, get("/private"), t)
+}
+
+type authTestRoutes struct{}
+
+func (resource *authTestRoutes) Register(agent base.RoutesAgent) {
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+	agent.RegisterGet("/public", ok)
+	agent.Register(http.MethodGet, "/private", ok, base.RequiresAuth())
+}
@@ -0,0 +1,206 @@
+package apikeys
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	base "github.com/saharsh-samples/go-mux-sql-starter/http"
+	"github.com/saharsh-samples/go-mux-sql-starter/http/utils"
+	commonUtils "github.com/saharsh-samples/go-mux-sql-starter/utils"
+)
+
+// DefaultAdminScope value
+const DefaultAdminScope = "admin:api-keys"
+
+// IssueRequest is the body of POST /admin/api-keys
+type IssueRequest struct {
+	Owner     string
+	Scopes    []string
+	ExpiresAt *time.Time
+}
+
+// Validate IssueRequest
+func (body *IssueRequest) Validate() error {
+	if commonUtils.IsEmptyString(body.Owner) {
+		return errors.New("Owner is required")
+	}
+	if body.ExpiresAt != nil && !body.ExpiresAt.After(time.Now()) {
+		return errors.New("ExpiresAt must be in the future")
+	}
+	return nil
+}
+
+// IssuedKey is returned when a key is issued or rotated. This is the only
+// time the key itself is available
+type IssuedKey struct {
+	Key    string
+	APIKey *APIKey
+
+	// response only struct, so validation is unnecessary
+	utils.AlwaysValidJSON
+}
+
+// AdminRoutes exposes endpoints to manage API keys. All endpoints require
+// an API key with the admin scope
+type AdminRoutes struct {
+	manager    Manager
+	jsonUtils  utils.JSONUtils
+	urlUtils   utils.URLUtils
+	adminScope string
+}
+
+// Register endpoint+method handlers
+func (resource *AdminRoutes) Register(agent base.RoutesAgent) {
+	agent.Register(http.MethodGet, "/admin/api-keys", resource.List,
+		base.WithSummary("List API keys"), base.WithTags("api-keys"), base.RequiresAuth(),
+		base.WithQueryParameter("owner", "", false),
+		base.WithQueryParameter(utils.FilterQueryParameter, []string{"Owner:eq:billing-service"}, false),
+		base.WithQueryParameter(utils.SortQueryParameter, "-CreatedAt", false),
+		base.WithPagedResponse(http.StatusOK, &APIKey{}))
+	agent.Register(http.MethodPost, "/admin/api-keys", resource.Issue,
+		base.WithSummary("Issue an API key"), base.WithTags("api-keys"), base.RequiresAuth(),
+		base.WithRequestBody(&IssueRequest{}), base.WithResponse(http.StatusCreated, &IssuedKey{}))
+	agent.Register(http.MethodGet, "/admin/api-keys/{id}", resource.Get,
+		base.WithSummary("Get an API key"), base.WithTags("api-keys"), base.RequiresAuth(),
+		base.WithResponse(http.StatusOK, &APIKey{}))
+	agent.Register(http.MethodPost, "/admin/api-keys/{id}/rotate", resource.Rotate,
+		base.WithSummary("Rotate an API key"), base.WithTags("api-keys"), base.RequiresAuth(),
+		base.WithResponse(http.StatusOK, &IssuedKey{}))
+	agent.Register(http.MethodDelete, "/admin/api-keys/{id}", resource.Revoke,
+		base.WithSummary("Revoke an API key"), base.WithTags("api-keys"), base.RequiresAuth(),
+		base.WithResponse(http.StatusNoContent, nil))
+}
+
+// List API keys, optionally filtered by 'owner' query parameter, and
+// filtered and sorted by 'filter' and 'sort' query parameters
+func (resource *AdminRoutes) List(w http.ResponseWriter, r *http.Request) {
+
+	if !hasScopes(w, r, resource.jsonUtils, resource.adminScope) {
+		return
+	}
+
+	defaultOwner := ""
+	owner, paramErr := resource.urlUtils.GetQueryParameterAsString(r, "owner", &defaultOwner)
+	if paramErr != nil {
+		resource.jsonUtils.BadRequest(w, paramErr.Error())
+		return
+	}
+
+	listQuery, paramErr := resource.urlUtils.GetListQuery(r, ListFields)
+	if paramErr != nil {
+		resource.jsonUtils.BadRequest(w, paramErr.Error())
+		return
+	}
+
+	keys, err := resource.manager.List(owner, listQuery)
+	if err != nil {
+		resource.jsonUtils.HandleDatabaseError(w, err)
+		return
+	}
+
+	payload := make([]interface{}, len(keys))
+	for i, key := range keys {
+		payload[i] = key
+	}
+	resource.jsonUtils.SetResponse(w, r, http.StatusOK, &utils.PagedResponse{
+		Limit:   len(payload),
+		Offset:  0,
+		Total:   int64(len(payload)),
+		Payload: payload,
+	})
+}
+
+// Issue a new API key
+func (resource *AdminRoutes) Issue(w http.ResponseWriter, r *http.Request) {
+
+	if !hasScopes(w, r, resource.jsonUtils, resource.adminScope) {
+		return
+	}
+
+	body := &IssueRequest{}
+	if resource.jsonUtils.ParseJSONRequest(r, body, w) != nil {
+		return
+	}
+
+	key, issued, err := resource.manager.Issue(body.Owner, body.Scopes, body.ExpiresAt)
+	if err != nil {
+		resource.jsonUtils.HandleDatabaseError(w, err)
+		return
+	}
+
+	resource.jsonUtils.SetJSONResponse(w, http.StatusCreated, &IssuedKey{Key: key, APIKey: issued})
+}
+
+// Get API key by id
+func (resource *AdminRoutes) Get(w http.ResponseWriter, r *http.Request) {
+
+	if !hasScopes(w, r, resource.jsonUtils, resource.adminScope) {
+		return
+	}
+
+	id, ok := resource.getID(w, r)
+	if !ok {
+		return
+	}
+
+	key, err := resource.manager.Lookup(id)
+	if err != nil {
+		resource.jsonUtils.HandleDatabaseError(w, err)
+		return
+	}
+
+	resource.jsonUtils.SetJSONResponse(w, http.StatusOK, key)
+}
+
+// Rotate API key, revoking the old key and issuing a replacement
+func (resource *AdminRoutes) Rotate(w http.ResponseWriter, r *http.Request) {
+
+	if !hasScopes(w, r, resource.jsonUtils, resource.adminScope) {
+		return
+	}
+
+	id, ok := resource.getID(w, r)
+	if !ok {
+		return
+	}
+
+	key, rotated, err := resource.manager.Rotate(id)
+	if err != nil {
+		resource.jsonUtils.HandleDatabaseError(w, err)
+		return
+	}
+
+	resource.jsonUtils.SetJSONResponse(w, http.StatusOK, &IssuedKey{Key: key, APIKey: rotated})
+}
+
+// Revoke API key
+func (resource *AdminRoutes) Revoke(w http.ResponseWriter, r *http.Request) {
+
+	if !hasScopes(w, r, resource.jsonUtils, resource.adminScope) {
+		return
+	}
+
+	id, ok := resource.getID(w, r)
+	if !ok {
+		return
+	}
+
+	err := resource.manager.Revoke(id)
+	if err != nil {
+		resource.jsonUtils.HandleDatabaseError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (resource *AdminRoutes) getID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, parseErr := strconv.ParseInt(resource.urlUtils.GetPathParams(r)["id"], 10, 64)
+	if parseErr != nil {
+		resource.jsonUtils.BadRequest(w, "API key id must be an integer")
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,154 @@
+package apikeys
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/saharsh-samples/go-mux-sql-starter/db"
+	httpTest "github.com/saharsh-samples/go-mux-sql-starter/http/test"
+	"github.com/saharsh-samples/go-mux-sql-starter/http/utils"
+	"github.com/saharsh-samples/go-mux-sql-starter/test"
+)
+
+func adminRequest(method string, url string, body string, vars map[string]string) *http.Request {
+	r := httptest.NewRequest(method, url, strings.NewReader(body))
+	r = r.WithContext(NewContext(r.Context(), &APIKey{Scopes: []string{DefaultAdminScope}}))
+	if vars != nil {
+		r = mux.SetURLVars(r, vars)
+	}
+	return r
+}
+
+func TestAdminRoutes_Register(t *testing.T) {
+
+	agent := httpTest.NewMockRoutesAgent()
+	utilsCtx := utils.Bootstrap(&utils.ContextIn{})
+	routes := &AdminRoutes{manager: &fakeManager{}, jsonUtils: utilsCtx.JSONUtils, urlUtils: utilsCtx.URLUtils, adminScope: DefaultAdminScope}
+
+	routes.Register(agent)
+
+	agent.VerifyThatRoute(t, "/admin/api-keys").ForHTTPMethod(http.MethodGet).UsesHandler(routes.List).RequiresAuth(true)
+	agent.VerifyThatRoute(t, "/admin/api-keys").ForHTTPMethod(http.MethodPost).UsesHandler(routes.Issue).RequiresAuth(true)
+	agent.VerifyThatRoute(t, "/admin/api-keys/{id}").ForHTTPMethod(http.MethodGet).UsesHandler(routes.Get).RequiresAuth(true)
+	agent.VerifyThatRoute(t, "/admin/api-keys/{id}/rotate").ForHTTPMethod(http.MethodPost).UsesHandler(routes.Rotate).RequiresAuth(true)
+	agent.VerifyThatRoute(t, "/admin/api-keys/{id}").ForHTTPMethod(http.MethodDelete).UsesHandler(routes.Revoke).RequiresAuth(true)
+}
+
+func TestAdminRoutes_require_admin_scope(t *testing.T) {
+
+	utilsCtx := utils.Bootstrap(&utils.ContextIn{})
+	routes := &AdminRoutes{manager: &fakeManager{}, jsonUtils: utilsCtx.JSONUtils, urlUtils: utilsCtx.URLUtils, adminScope: DefaultAdminScope}
+
+	r := httptest.NewRequest(http.MethodGet, "/admin/api-keys", nil)
+	r = r.WithContext(NewContext(r.Context(), &APIKey{Scopes: []string{"read"}}))
+	w := httptest.NewRecorder()
+	routes.List(w, r)
+
+	test.AssertEquals("", 403, w.Code, t)
+}
+
+func TestAdminRoutes_List(t *testing.T) {
+
+	utilsCtx := utils.Bootstrap(&utils.ContextIn{})
+	routes := &AdminRoutes{manager: &fakeManager{}, jsonUtils: utilsCtx.JSONUtils, urlUtils: utilsCtx.URLUtils, adminScope: DefaultAdminScope}
+
+	w := httptest.NewRecorder()
+	routes.List(w, adminRequest(http.MethodGet, "/admin/api-keys?owner=billing", "", nil))
+
+	response := &utils.PagedResponse{}
+	json.Unmarshal(w.Body.Bytes(), response)
+	test.AssertEquals("", 200, w.Code, t)
+	test.AssertEquals("", int64(1), response.Total, t)
+	test.AssertEquals("", "billing", response.Payload[0].(map[string]interface{})["Owner"], t)
+}
+
+func TestAdminRoutes_List_with_list_query(t *testing.T) {
+
+	manager := &fakeManager{}
+	utilsCtx := utils.Bootstrap(&utils.ContextIn{})
+	routes := &AdminRoutes{manager: manager, jsonUtils: utilsCtx.JSONUtils, urlUtils: utilsCtx.URLUtils, adminScope: DefaultAdminScope}
+
+	w := httptest.NewRecorder()
+	routes.List(w, adminRequest(http.MethodGet, "/admin/api-keys?filter=Owner:eq:billing&sort=-CreatedAt", "", nil))
+	test.AssertEquals("", 200, w.Code, t)
+	test.AssertEquals("", "Owner", manager.listQuery.Filters[0].Field, t)
+	test.AssertTrue("Expected descending sort", manager.listQuery.Sorts[0].Descending, t)
+
+	// unknown field
+	w = httptest.NewRecorder()
+	routes.List(w, adminRequest(http.MethodGet, "/admin/api-keys?filter=KeyHash:eq:x", "", nil))
+	test.AssertEquals("", 400, w.Code, t)
+}
+
+func TestAdminRoutes_Issue(t *testing.T) {
+
+	manager := &fakeManager{}
+	utilsCtx := utils.Bootstrap(&utils.ContextIn{})
+	routes := &AdminRoutes{manager: manager, jsonUtils: utilsCtx.JSONUtils, urlUtils: utilsCtx.URLUtils, adminScope: DefaultAdminScope}
+
+	w := httptest.NewRecorder()
+	routes.Issue(w, adminRequest(http.MethodPost, "/admin/api-keys", `{"Owner":"billing","Scopes":["read"]}`, nil))
+
+	response := &IssuedKey{}
+	json.Unmarshal(w.Body.Bytes(), response)
+	test.AssertEquals("", 201, w.Code, t)
+	test.AssertEquals("", "test_new", response.Key, t)
+	test.AssertEquals("", "billing", manager.issuedOwner, t)
+	test.AssertEquals("", "read", manager.issuedScopes[0], t)
+
+	// invalid body
+	w = httptest.NewRecorder()
+	routes.Issue(w, adminRequest(http.MethodPost, "/admin/api-keys", `{"Scopes":["read"]}`, nil))
+	test.AssertEquals("", 400, w.Code, t)
+}
+
+func TestAdminRoutes_Get(t *testing.T) {
+
+	utilsCtx := utils.Bootstrap(&utils.ContextIn{})
+	routes := &AdminRoutes{manager: &fakeManager{}, jsonUtils: utilsCtx.JSONUtils, urlUtils: utilsCtx.URLUtils, adminScope: DefaultAdminScope}
+
+	w := httptest.NewRecorder()
+	routes.Get(w, adminRequest(http.MethodGet, "/admin/api-keys/3", "", map[string]string{"id": "3"}))
+	test.AssertEquals("", 200, w.Code, t)
+
+	w = httptest.NewRecorder()
+	routes.Get(w, adminRequest(http.MethodGet, "/admin/api-keys/abc", "", map[string]string{"id": "abc"}))
+	test.AssertEquals("", 400, w.Code, t)
+}
+
+func TestAdminRoutes_Rotate(t *testing.T) {
+
+	manager := &fakeManager{}
+	utilsCtx := utils.Bootstrap(&utils.ContextIn{})
+	routes := &AdminRoutes{manager: manager, jsonUtils: utilsCtx.JSONUtils, urlUtils: utilsCtx.URLUtils, adminScope: DefaultAdminScope}
+
+	w := httptest.NewRecorder()
+	routes.Rotate(w, adminRequest(http.MethodPost, "/admin/api-keys/3/rotate", "", map[string]string{"id": "3"}))
+
+	response := &IssuedKey{}
+	json.Unmarshal(w.Body.Bytes(), response)
+	test.AssertEquals("", 200, w.Code, t)
+	test.AssertEquals("", "test_rotated", response.Key, t)
+	test.AssertEquals("", int64(3), manager.rotatedID, t)
+}
+
+func TestAdminRoutes_Revoke(t *testing.T) {
+
+	manager := &fakeManager{}
+	utilsCtx := utils.Bootstrap(&utils.ContextIn{})
+	routes := &AdminRoutes{manager: manager, jsonUtils: utilsCtx.JSONUtils, urlUtils: utilsCtx.URLUtils, adminScope: DefaultAdminScope}
+
+	w := httptest.NewRecorder()
+	routes.Revoke(w, adminRequest(http.MethodDelete, "/admin/api-keys/3", "", map[string]string{"id": "3"}))
+	test.AssertEquals("", 204, w.Code, t)
+	test.AssertEquals("", int64(3), manager.revokedID, t)
+
+	manager.err = db.NewNotFoundError("")
+	w = httptest.NewRecorder()
+	routes.Revoke(w, adminRequest(http.MethodDelete, "/admin/api-keys/4", "", map[string]string{"id": "4"}))
+	test.AssertEquals("", 404, w.Code, t)
+}
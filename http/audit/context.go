@@ -0,0 +1,47 @@
+package audit
+
+import (
+	"github.com/saharsh-samples/go-mux-sql-starter/db"
+	base "github.com/saharsh-samples/go-mux-sql-starter/http"
+	"github.com/saharsh-samples/go-mux-sql-starter/http/utils"
+)
+
+// ContextIn describes dependecies needed by this package
+type ContextIn struct {
+	Database  db.Database
+	Audit     db.Audit
+	JSONUtils utils.JSONUtils
+	URLUtils  utils.URLUtils
+
+	// Middlewares guarding the audit log routes (required), e.g.
+	// apikeys.RequireScopes(jsonUtils, "admin:audit")
+	Middlewares base.Middlewares
+}
+
+// ContextOut describes dependencies exported by this package
+type ContextOut struct {
+	RoutesToRegister []base.Routes
+}
+
+// Bootstrap initializes this module with ContextIn and exports
+// resulting ContextOut
+func Bootstrap(in *ContextIn) *ContextOut {
+
+	// audit log exposes snapshots of any entity
+	if len(in.Middlewares) == 0 {
+		panic("audit: Middlewares guarding audit routes are required")
+	}
+
+	out := &ContextOut{}
+	out.RoutesToRegister = []base.Routes{
+		&Routes{
+			database:    in.Database,
+			audit:       in.Audit,
+			jsonUtils:   in.JSONUtils,
+			urlUtils:    in.URLUtils,
+			middlewares: in.Middlewares,
+		},
+	}
+
+	return out
+}
@@ -0,0 +1,74 @@
+package audit
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/saharsh-samples/go-mux-sql-starter/db"
+	base "github.com/saharsh-samples/go-mux-sql-starter/http"
+	"github.com/saharsh-samples/go-mux-sql-starter/http/utils"
+)
+
+// DefaultLimit of audit entries returned
+const DefaultLimit = 50
+
+// MaxLimit of audit entries returned
+const MaxLimit = 500
+
+// Routes exposes the audit log of entities
+type Routes struct {
+	database    db.Database
+	audit       db.Audit
+	jsonUtils   utils.JSONUtils
+	urlUtils    utils.URLUtils
+	middlewares base.Middlewares
+}
+
+// Register endpoint+method handlers
+func (resource *Routes) Register(agent base.RoutesAgent) {
+	agent.Group("/admin/audit", base.WithGroupMiddlewares(resource.middlewares...)).
+		Register(http.MethodGet, "/{entity}/{id}", resource.List,
+			base.WithSummary("List changes of an entity"), base.WithTags("audit"), base.RequiresAuth(),
+			base.WithQueryParameter("limit", DefaultLimit, false),
+			base.WithPagedResponse(http.StatusOK, &db.AuditEntry{}))
+}
+
+// List changes of entity with id, most recent first, limited by 'limit'
+// query parameter
+func (resource *Routes) List(w http.ResponseWriter, r *http.Request) {
+
+	defaultLimit := DefaultLimit
+	limit, paramErr := resource.urlUtils.GetQueryParameterAsInteger(r, "limit", &defaultLimit)
+	if paramErr != nil {
+		resource.jsonUtils.BadRequest(w, paramErr.Error())
+		return
+	}
+	if limit < 1 || limit > MaxLimit {
+		resource.jsonUtils.BadRequest(w, fmt.Sprintf("'limit' Query Parameter must be between 1 and %d", MaxLimit))
+		return
+	}
+
+	pathParams := resource.urlUtils.GetPathParams(r)
+	conn := resource.database.GetConnection()
+	entries, err := resource.audit.List(conn, pathParams["entity"], pathParams["id"], limit)
+	if err != nil {
+		resource.jsonUtils.HandleDatabaseError(w, err)
+		return
+	}
+	total, err := resource.audit.Count(conn, pathParams["entity"], pathParams["id"])
+	if err != nil {
+		resource.jsonUtils.HandleDatabaseError(w, err)
+		return
+	}
+
+	payload := make([]interface{}, len(entries))
+	for i, entry := range entries {
+		payload[i] = entry
+	}
+	resource.jsonUtils.SetResponse(w, r, http.StatusOK, &utils.PagedResponse{
+		Limit:   limit,
+		Offset:  0,
+		Total:   total,
+		Payload: payload,
+	})
+}
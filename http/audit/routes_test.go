@@ -0,0 +1,122 @@
+package audit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/saharsh-samples/go-mux-sql-starter/db"
+	dbTest "github.com/saharsh-samples/go-mux-sql-starter/db/test"
+	base "github.com/saharsh-samples/go-mux-sql-starter/http"
+	httpTest "github.com/saharsh-samples/go-mux-sql-starter/http/test"
+	"github.com/saharsh-samples/go-mux-sql-starter/http/utils"
+	"github.com/saharsh-samples/go-mux-sql-starter/test"
+)
+
+type fakeAudit struct {
+	db.Audit
+	entity   string
+	entityID interface{}
+	limit    int
+	entries  []*db.AuditEntry
+	total    int64
+	err      db.Error
+}
+
+func (audit *fakeAudit) List(conn db.Connection, entity string, entityID interface{}, limit int) ([]*db.AuditEntry, db.Error) {
+	audit.entity, audit.entityID, audit.limit = entity, entityID, limit
+	return audit.entries, audit.err
+}
+
+func (audit *fakeAudit) Count(conn db.Connection, entity string, entityID interface{}) (int64, db.Error) {
+	return audit.total, audit.err
+}
+
+func passThrough(next http.Handler) http.Handler {
+	return next
+}
+
+func listRequest(url string) *http.Request {
+	return mux.SetURLVars(httptest.NewRequest(http.MethodGet, url, nil), map[string]string{"entity": "things", "id": "7"})
+}
+
+func TestRoutes_Register(t *testing.T) {
+
+	agent := httpTest.NewMockRoutesAgent()
+	utilsCtx := utils.Bootstrap(nil)
+	routes := Bootstrap(&ContextIn{
+		Database:    &dbTest.MockDatabase{},
+		Audit:       &fakeAudit{},
+		JSONUtils:   utilsCtx.JSONUtils,
+		URLUtils:    utilsCtx.URLUtils,
+		Middlewares: base.Middlewares{passThrough},
+	}).RoutesToRegister[0].(*Routes)
+
+	routes.Register(agent)
+
+	agent.VerifyThatGroup(t, "/admin/audit").Exists().HasMiddlewareCount(1)
+	agent.VerifyThatRoute(t, "/admin/audit/{entity}/{id}").ForHTTPMethod(http.MethodGet).UsesHandler(routes.List).RequiresAuth(true)
+}
+
+func TestRoutes_List(t *testing.T) {
+
+	// arrange
+	audit := &fakeAudit{entries: []*db.AuditEntry{{ID: 2, Entity: "things", EntityID: "7", Action: db.AuditUpdate, Actor: "alice"}}, total: 12}
+	utilsCtx := utils.Bootstrap(nil)
+	routes := Bootstrap(&ContextIn{
+		Database:    &dbTest.MockDatabase{},
+		Audit:       audit,
+		JSONUtils:   utilsCtx.JSONUtils,
+		URLUtils:    utilsCtx.URLUtils,
+		Middlewares: base.Middlewares{passThrough},
+	}).RoutesToRegister[0].(*Routes)
+	w := httptest.NewRecorder()
+
+	// act
+	routes.List(w, listRequest("/admin/audit/things/7?limit=10"))
+
+	// assert
+	test.AssertEquals("", http.StatusOK, w.Code, t)
+	test.AssertEquals("", "things", audit.entity, t)
+	test.AssertEquals("", "7", audit.entityID, t)
+	test.AssertEquals("", 10, audit.limit, t)
+	response := &struct {
+		Limit   int
+		Total   int64
+		Payload []*db.AuditEntry
+	}{}
+	json.Unmarshal(w.Body.Bytes(), response)
+	test.AssertEquals("", int64(12), response.Total, t)
+	test.AssertEquals("", 1, len(response.Payload), t)
+	test.AssertEquals("", "alice", response.Payload[0].Actor, t)
+}
+
+func TestRoutes_List_with_errors(t *testing.T) {
+
+	utilsCtx := utils.Bootstrap(nil)
+	routes := Bootstrap(&ContextIn{
+		Database:    &dbTest.MockDatabase{},
+		Audit:       &fakeAudit{err: db.NewGenericError("connection")},
+		JSONUtils:   utilsCtx.JSONUtils,
+		URLUtils:    utilsCtx.URLUtils,
+		Middlewares: base.Middlewares{passThrough},
+	}).RoutesToRegister[0].(*Routes)
+
+	for url, expected := range map[string]int{
+		"/admin/audit/things/7?limit=0":   http.StatusBadRequest,
+		"/admin/audit/things/7?limit=501": http.StatusBadRequest,
+		"/admin/audit/things/7?limit=x":   http.StatusBadRequest,
+		"/admin/audit/things/7":           http.StatusInternalServerError,
+	} {
+		w := httptest.NewRecorder()
+		routes.List(w, listRequest(url))
+		test.AssertEquals(url, expected, w.Code, t)
+	}
+}
+
+func TestBootstrap_without_middlewares(t *testing.T) {
+	defer test.AssertPanic("Expected unguarded audit routes to be rejected", t)
+	Bootstrap(&ContextIn{Audit: &fakeAudit{}})
+}
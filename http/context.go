@@ -2,8 +2,10 @@ package http
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/saharsh-samples/go-mux-sql-starter/http/utils"
 )
 
 // Middlewares runs before every route
@@ -17,12 +19,74 @@ type TLSConfiguration struct {
 	KeyFile string
 }
 
+// ServerTimeouts for server. Zero values are replaced with defaults
+// where a default exists, otherwise the timeout is disabled
+type ServerTimeouts struct {
+	// ReadTimeout for entire request, including body (default 5s)
+	ReadTimeout time.Duration
+	// ReadHeaderTimeout for request headers (defaults to ReadTimeout)
+	ReadHeaderTimeout time.Duration
+	// WriteTimeout for response (default 10s)
+	WriteTimeout time.Duration
+	// IdleTimeout for keep-alive connections (defaults to ReadTimeout)
+	IdleTimeout time.Duration
+	// ShutdownGracePeriod to wait for in-flight requests (default 5s)
+	ShutdownGracePeriod time.Duration
+}
+
+// DefaultReadTimeout value
+const DefaultReadTimeout = 5 * time.Second
+
+// DefaultWriteTimeout value
+const DefaultWriteTimeout = 10 * time.Second
+
+// DefaultShutdownGracePeriod value
+const DefaultShutdownGracePeriod = 5 * time.Second
+
+func (timeouts ServerTimeouts) withDefaults() ServerTimeouts {
+	if timeouts.ReadTimeout == 0 {
+		timeouts.ReadTimeout = DefaultReadTimeout
+	}
+	if timeouts.WriteTimeout == 0 {
+		timeouts.WriteTimeout = DefaultWriteTimeout
+	}
+	if timeouts.ShutdownGracePeriod == 0 {
+		timeouts.ShutdownGracePeriod = DefaultShutdownGracePeriod
+	}
+	return timeouts
+}
+
 // ContextIn describes dependecies needed by this package
 type ContextIn struct {
 	Port                  int
 	RoutesToRegister      []Routes
 	MiddlewaresToRegister Middlewares
 	TLSConfiguration      *TLSConfiguration
+	CORSConfiguration     *CORSConfiguration
+	Versioning            *VersioningConfiguration
+	OpenAPI               *OpenAPIConfiguration
+	RequestValidation     *RequestValidationConfiguration
+	Timeouts              ServerTimeouts
+
+	// MaxHeaderBytes of request headers (defaults to http.DefaultMaxHeaderBytes)
+	MaxHeaderBytes int
+
+	// MaxRequestBodyBytes for all routes. Zero means no limit. Individual
+	// routes can override it at registration using WithBodyLimit
+	MaxRequestBodyBytes int64
+
+	// EventBrokers whose streams are closed when Server.Shutdown runs
+	EventBrokers []EventBroker
+
+	// WebSocketHubs whose connections are closed when Server.Shutdown runs
+	WebSocketHubs []WebSocketHub
+
+	// JSONUtils used to write error responses (optional)
+	JSONUtils utils.JSONUtils
+
+	// AuditContext middleware from utils.ContextOut (optional). Defaults
+	// to reading request IDs from DefaultRequestIDHeader
+	AuditContext func(http.Handler) http.Handler
 }
 
 // ContextOut describes dependencies exported by this package
@@ -40,12 +104,50 @@ func Bootstrap(in *ContextIn) *ContextOut {
 		middlewares[i] = middleware
 	}
 
+	// default dependencies
+	jsonUtils := in.JSONUtils
+	if jsonUtils == nil {
+		jsonUtils = utils.Bootstrap(&utils.ContextIn{}).JSONUtils
+	}
+	auditContext := in.AuditContext
+	if auditContext == nil {
+		auditContext = utils.Bootstrap(&utils.ContextIn{}).AuditContext
+	}
+
+	// OpenAPI document is only served if configured
+	var openAPIDocument *OpenAPIDocument
+	openAPIPath := ""
+	if in.OpenAPI != nil {
+		openAPIDocument = GenerateOpenAPIDocument(in)
+		openAPIPath = in.OpenAPI.Path
+		if openAPIPath == "" {
+			openAPIPath = DefaultOpenAPIPath
+		}
+	}
+
+	var validator *requestValidator
+	if in.RequestValidation != nil {
+		validator = newRequestValidator(in.RequestValidation, in, jsonUtils)
+	}
+
 	out := &ContextOut{}
 	out.Server = &server{
-		port:        in.Port,
-		routes:      in.RoutesToRegister,
-		middlewares: middlewares,
-		tlsConfig:   in.TLSConfiguration,
+		port:                in.Port,
+		routes:              in.RoutesToRegister,
+		middlewares:         middlewares,
+		tlsConfig:           in.TLSConfiguration,
+		cors:                newCORSMiddleware(in.CORSConfiguration),
+		versioning:          newVersioning(in.Versioning, jsonUtils),
+		timeouts:            in.Timeouts.withDefaults(),
+		maxHeaderBytes:      in.MaxHeaderBytes,
+		maxRequestBodyBytes: in.MaxRequestBodyBytes,
+		jsonUtils:           jsonUtils,
+		auditContext:        auditContext,
+		openAPIDocument:     openAPIDocument,
+		openAPIPath:         openAPIPath,
+		requestValidator:    validator,
+		eventBrokers:        in.EventBrokers,
+		webSocketHubs:       in.WebSocketHubs,
 	}
 
 	return out
@@ -0,0 +1,225 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/handlers"
+)
+
+// CORSConfiguration for server
+type CORSConfiguration struct {
+
+	// Disabled turns off CORS handling entirely (e.g. for internal services)
+	Disabled bool
+
+	// AllowedOrigins may contain exact origins ("https://example.com"),
+	// wildcard subdomain patterns ("https://*.example.com") or "*" to
+	// allow any origin
+	AllowedOrigins []string
+
+	// AllowedMethods for cross origin requests. Defaults to those of
+	// DefaultCORSConfiguration when empty
+	AllowedMethods []string
+
+	// AllowedHeaders for cross origin requests. Defaults to those of
+	// DefaultCORSConfiguration when empty
+	AllowedHeaders []string
+
+	// ExposedHeaders are made readable to cross origin callers
+	ExposedHeaders []string
+
+	// AllowCredentials lets cross origin requests include cookies and
+	// Authorization headers. Matching origin is echoed back instead of "*".
+	// Can't be combined with "*" in AllowedOrigins
+	AllowCredentials bool
+
+	// MaxAgeInSeconds preflight responses can be cached for
+	MaxAgeInSeconds int
+
+	// RouteOverrides replace this configuration for requests whose path
+	// starts with the map key. Longest matching prefix wins
+	RouteOverrides map[string]*CORSConfiguration
+}
+
+// DefaultCORSConfiguration is used when ContextIn.CORSConfiguration is nil
+var DefaultCORSConfiguration = CORSConfiguration{
+	AllowedOrigins: []string{"*"},
+	AllowedMethods: []string{"POST", "GET", "OPTIONS", "PUT", "PATCH", "DELETE", "HEAD"},
+	AllowedHeaders: []string{
+		"Accept",
+		"Accept-Encoding",
+		"Access-Control-Request-Headers",
+		"Access-Control-Request-Method",
+		"Authorization",
+		"Cache-Control",
+		"Client-Version",
+		"Connection",
+		"Content-Length",
+		"Content-Type",
+		"Host",
+		"Origin",
+		"Referer",
+		"User-Agent",
+		"X-CSRF-Token",
+		"X-header",
+	},
+}
+
+// newCORSMiddleware creates middleware applying configured CORS policy.
+// Panics if config is invalid
+func newCORSMiddleware(config *CORSConfiguration) func(http.Handler) http.Handler {
+
+	if config == nil {
+		config = &DefaultCORSConfiguration
+	}
+	if err := config.validate(); err != nil {
+		panic(err)
+	}
+
+	return func(h http.Handler) http.Handler {
+
+		cors := corsHandlerWithOverrides(config, h)
+
+		// gorilla answers every OPTIONS request itself, so only preflights
+		// go through it. Other OPTIONS requests reach registered handlers
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodOptions && !isPreflight(r) {
+				h.ServeHTTP(w, r)
+				return
+			}
+			cors.ServeHTTP(w, r)
+		})
+	}
+}
+
+// validate config and its route overrides
+func (config *CORSConfiguration) validate() error {
+
+	// echoing back any origin with credentials would let every site make
+	// credentialed requests
+	if config.AllowCredentials && !config.Disabled {
+		for _, origin := range config.AllowedOrigins {
+			if origin == "*" {
+				return fmt.Errorf("CORS: AllowedOrigins '*' can't be combined with AllowCredentials")
+			}
+		}
+	}
+
+	for prefix, override := range config.RouteOverrides {
+		if err := override.validate(); err != nil {
+			return fmt.Errorf("%v (route override '%v')", err, prefix)
+		}
+	}
+	return nil
+}
+
+// corsHandlerWithOverrides applies RouteOverrides by longest matching path
+// prefix, and config to all other requests
+func corsHandlerWithOverrides(config *CORSConfiguration, h http.Handler) http.Handler {
+
+	defaultHandler := corsHandler(config, h)
+	if len(config.RouteOverrides) == 0 {
+		return defaultHandler
+	}
+
+	// longest prefixes first
+	prefixes := make([]string, 0, len(config.RouteOverrides))
+	overrideHandlers := make(map[string]http.Handler, len(config.RouteOverrides))
+	for prefix, override := range config.RouteOverrides {
+		prefixes = append(prefixes, prefix)
+		overrideHandlers[prefix] = corsHandler(override, h)
+	}
+	sort.Slice(prefixes, func(i, j int) bool { return len(prefixes[i]) > len(prefixes[j]) })
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(r.URL.Path, prefix) {
+				overrideHandlers[prefix].ServeHTTP(w, r)
+				return
+			}
+		}
+		defaultHandler.ServeHTTP(w, r)
+	})
+}
+
+// isPreflight returns 'true' for CORS preflight requests
+func isPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions &&
+		r.Header.Get("Origin") != "" &&
+		r.Header.Get("Access-Control-Request-Method") != ""
+}
+
+func corsHandler(config *CORSConfiguration, h http.Handler) http.Handler {
+
+	if config.Disabled {
+		return h
+	}
+
+	// gorilla replaces its own defaults with whatever it's given, so empty
+	// lists would reject every preflight
+	allowedMethods := config.AllowedMethods
+	if len(allowedMethods) == 0 {
+		allowedMethods = DefaultCORSConfiguration.AllowedMethods
+	}
+	allowedHeaders := config.AllowedHeaders
+	if len(allowedHeaders) == 0 {
+		allowedHeaders = DefaultCORSConfiguration.AllowedHeaders
+	}
+
+	options := []handlers.CORSOption{
+		handlers.AllowedMethods(allowedMethods),
+		handlers.AllowedHeaders(allowedHeaders),
+	}
+	if len(config.ExposedHeaders) > 0 {
+		options = append(options, handlers.ExposedHeaders(config.ExposedHeaders))
+	}
+	if config.AllowCredentials {
+		options = append(options, handlers.AllowCredentials())
+	}
+	if config.MaxAgeInSeconds > 0 {
+		options = append(options, handlers.MaxAge(config.MaxAgeInSeconds))
+	}
+
+	// a lone "*" without credentials can be served as is. Anything else
+	// requires matching and echoing back the request origin
+	if len(config.AllowedOrigins) == 1 && config.AllowedOrigins[0] == "*" && !config.AllowCredentials {
+		options = append(options, handlers.AllowedOrigins(config.AllowedOrigins))
+		return handlers.CORS(options...)(h)
+	}
+
+	options = append(options, handlers.AllowedOriginValidator(newOriginMatcher(config.AllowedOrigins)))
+	cors := handlers.CORS(options...)(h)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Origin")
+		cors.ServeHTTP(w, r)
+	})
+}
+
+// newOriginMatcher supports exact origins, "*" and wildcard subdomain
+// patterns like "https://*.example.com"
+func newOriginMatcher(allowedOrigins []string) handlers.OriginValidator {
+	return func(origin string) bool {
+		origin = strings.ToLower(origin)
+		for _, allowed := range allowedOrigins {
+			allowed = strings.ToLower(allowed)
+			if allowed == "*" || allowed == origin {
+				return true
+			}
+			wildcard := strings.Index(allowed, "*.")
+			if wildcard < 0 {
+				continue
+			}
+			scheme, domain := allowed[:wildcard], allowed[wildcard+1:]
+			if strings.HasPrefix(origin, scheme) &&
+				strings.HasSuffix(origin, domain) &&
+				len(origin) > len(scheme)+len(domain) &&
+				!strings.Contains(origin[len(scheme):len(origin)-len(domain)], "/") {
+				return true
+			}
+		}
+		return false
+	}
+}
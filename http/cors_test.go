@@ -0,0 +1,148 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/saharsh-samples/go-mux-sql-starter/test"
+)
+
+func serveCORS(config *CORSConfiguration, method string, path string, origin string) *httptest.ResponseRecorder {
+	handler := newCORSMiddleware(config)(http.HandlerFunc(SuccessHandler))
+	r := httptest.NewRequest(method, path, nil)
+	if origin != "" {
+		r.Header.Set("Origin", origin)
+	}
+	if method == http.MethodOptions {
+		r.Header.Set("Access-Control-Request-Method", http.MethodGet)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+func TestCORS_default_configuration(t *testing.T) {
+	w := serveCORS(nil, http.MethodGet, "/route1", "https://anywhere.com")
+	test.AssertEquals("", 200, w.Code, t)
+	test.AssertEquals("", "*", w.Header().Get("Access-Control-Allow-Origin"), t)
+}
+
+func TestCORS_disabled(t *testing.T) {
+	w := serveCORS(&CORSConfiguration{Disabled: true}, http.MethodGet, "/route1", "https://anywhere.com")
+	test.AssertEquals("", 200, w.Code, t)
+	test.AssertEquals("", "", w.Header().Get("Access-Control-Allow-Origin"), t)
+}
+
+func TestCORS_origin_patterns_and_credentials(t *testing.T) {
+
+	config := &CORSConfiguration{
+		AllowedOrigins:   []string{"https://example.com", "https://*.example.org"},
+		AllowedMethods:   []string{"GET"},
+		ExposedHeaders:   []string{"X-Request-ID"},
+		AllowCredentials: true,
+		MaxAgeInSeconds:  600,
+	}
+
+	w := serveCORS(config, http.MethodGet, "/route1", "https://example.com")
+	test.AssertEquals("", "https://example.com", w.Header().Get("Access-Control-Allow-Origin"), t)
+	test.AssertEquals("", "true", w.Header().Get("Access-Control-Allow-Credentials"), t)
+	test.AssertEquals("", "X-Request-Id", w.Header().Get("Access-Control-Expose-Headers"), t)
+	test.AssertEquals("", "Origin", w.Header().Get("Vary"), t)
+
+	w = serveCORS(config, http.MethodGet, "/route1", "https://api.example.org")
+	test.AssertEquals("", "https://api.example.org", w.Header().Get("Access-Control-Allow-Origin"), t)
+
+	w = serveCORS(config, http.MethodOptions, "/route1", "https://api.example.org")
+	test.AssertEquals("", "600", w.Header().Get("Access-Control-Max-Age"), t)
+
+	for _, disallowed := range []string{"https://example.org", "http://api.example.org", "https://evil.com/.example.org", "https://notexample.com"} {
+		w = serveCORS(config, http.MethodGet, "/route1", disallowed)
+		test.AssertEquals("Expected no CORS headers for "+disallowed, "", w.Header().Get("Access-Control-Allow-Origin"), t)
+	}
+}
+
+func TestCORS_route_overrides(t *testing.T) {
+
+	config := &CORSConfiguration{
+		AllowedOrigins: []string{"*"},
+		AllowedMethods: []string{"GET"},
+		RouteOverrides: map[string]*CORSConfiguration{
+			"/admin":        {AllowedOrigins: []string{"https://admin.example.com"}, AllowedMethods: []string{"GET"}},
+			"/admin/public": {AllowedOrigins: []string{"*"}, AllowedMethods: []string{"GET"}},
+			"/internal":     {Disabled: true},
+		},
+	}
+
+	w := serveCORS(config, http.MethodGet, "/things", "https://anywhere.com")
+	test.AssertEquals("", "*", w.Header().Get("Access-Control-Allow-Origin"), t)
+
+	w = serveCORS(config, http.MethodGet, "/admin/users", "https://anywhere.com")
+	test.AssertEquals("", "", w.Header().Get("Access-Control-Allow-Origin"), t)
+
+	w = serveCORS(config, http.MethodGet, "/admin/users", "https://admin.example.com")
+	test.AssertEquals("", "https://admin.example.com", w.Header().Get("Access-Control-Allow-Origin"), t)
+
+	w = serveCORS(config, http.MethodGet, "/admin/public/info", "https://anywhere.com")
+	test.AssertEquals("", "*", w.Header().Get("Access-Control-Allow-Origin"), t)
+
+	w = serveCORS(config, http.MethodGet, "/internal/metrics", "https://anywhere.com")
+	test.AssertEquals("", 200, w.Code, t)
+	test.AssertEquals("", "", w.Header().Get("Access-Control-Allow-Origin"), t)
+}
+
+func TestCORS_default_methods_and_headers(t *testing.T) {
+
+	// override only restricting origins
+	config := &CORSConfiguration{AllowedOrigins: []string{"https://example.com"}}
+	handler := newCORSMiddleware(config)(http.HandlerFunc(SuccessHandler))
+	r := httptest.NewRequest(http.MethodOptions, "/route1", nil)
+	r.Header.Set("Origin", "https://example.com")
+	r.Header.Set("Access-Control-Request-Method", http.MethodPut)
+	r.Header.Set("Access-Control-Request-Headers", "Content-Type, Authorization")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, r)
+
+	test.AssertEquals("", 200, w.Code, t)
+	test.AssertEquals("", "https://example.com", w.Header().Get("Access-Control-Allow-Origin"), t)
+	test.AssertEquals("", "PUT", w.Header().Get("Access-Control-Allow-Methods"), t)
+	test.AssertEquals("", "Content-Type,Authorization", w.Header().Get("Access-Control-Allow-Headers"), t)
+}
+
+func TestCORS_options_requests_that_are_not_preflights(t *testing.T) {
+
+	called := false
+	handler := newCORSMiddleware(nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		w.Header().Set("Allow", "GET, OPTIONS")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodOptions, "/route1", nil))
+
+	test.AssertTrue("Expected registered OPTIONS handler to run", called, t)
+	test.AssertEquals("", 204, w.Code, t)
+	test.AssertEquals("", "GET, OPTIONS", w.Header().Get("Allow"), t)
+
+	// preflights are still answered by CORS handler
+	w = serveCORS(nil, http.MethodOptions, "/route1", "https://anywhere.com")
+	test.AssertEquals("", 200, w.Code, t)
+	test.AssertEquals("", "*", w.Header().Get("Access-Control-Allow-Origin"), t)
+}
+
+func TestCORS_rejects_any_origin_with_credentials(t *testing.T) {
+
+	for _, config := range []*CORSConfiguration{
+		{AllowedOrigins: []string{"https://example.com", "*"}, AllowCredentials: true},
+		{AllowedOrigins: []string{"https://example.com"}, RouteOverrides: map[string]*CORSConfiguration{
+			"/public": {AllowedOrigins: []string{"*"}, AllowCredentials: true},
+		}},
+	} {
+		func() {
+			defer test.AssertPanic("Expected '*' with credentials to be rejected", t)
+			newCORSMiddleware(config)
+		}()
+	}
+}
@@ -0,0 +1,358 @@
+package http
+
+import (
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/saharsh-samples/go-mux-sql-starter/http/utils"
+)
+
+// ---
+// Server-Sent Events
+//
+// An EventBroker fans events published to a topic out to all clients
+// subscribed to it, e.g. from a RoutesAgent handler:
+//
+//	agent.RegisterGet("/orders/{id}/events", broker.Handler(func(r *http.Request) string {
+//		return "orders/" + mux.Vars(r)["id"]
+//	}))
+//
+// Recent events are kept in a ReplayBuffer, so reconnecting clients
+// resume after the event in their Last-Event-ID header. Brokers passed in
+// ContextIn.EventBrokers close their streams when Server.Shutdown runs
+// ---
+
+// DefaultHeartbeatInterval value
+const DefaultHeartbeatInterval = 15 * time.Second
+
+// DefaultReplayBufferSize value (events per topic)
+const DefaultReplayBufferSize = 100
+
+// DefaultReplayBufferTopics value
+const DefaultReplayBufferTopics = 1000
+
+// DefaultSubscriberBufferSize value
+const DefaultSubscriberBufferSize = 16
+
+// Event sent to subscribers
+type Event struct {
+	// ID is assigned when published, unless set. Line breaks are removed
+	ID string
+	// Event name. Clients dispatch unnamed events as 'message'. Line
+	// breaks are removed
+	Event string
+	// Data can span multiple lines
+	Data string
+	// Retry tells clients how long to wait before reconnecting
+	Retry time.Duration
+}
+
+// NewJSONEvent with value encoded as JSON data
+func NewJSONEvent(event string, value interface{}) (*Event, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return &Event{Event: event, Data: string(data)}, nil
+}
+
+// ReplayBuffer keeps recent events of topics for clients resuming streams
+type ReplayBuffer interface {
+	Add(topic string, event *Event)
+
+	// Since returns events of topic after event with lastEventID, or all
+	// buffered events if that one isn't buffered (anymore)
+	Since(topic string, lastEventID string) []*Event
+}
+
+// EventsConfiguration of an EventBroker. Zero values are replaced with
+// defaults
+type EventsConfiguration struct {
+	// HeartbeatInterval between comments keeping idle streams open
+	HeartbeatInterval time.Duration
+	// Retry sent to clients when streams open. Not sent if zero
+	Retry time.Duration
+	// ReplayBuffer (defaults to in memory buffer of DefaultReplayBufferSize
+	// events for DefaultReplayBufferTopics topics)
+	ReplayBuffer ReplayBuffer
+	// SubscriberBufferSize is the number of events a subscriber can lag
+	// behind before it's disconnected, to resume once it reconnects
+	SubscriberBufferSize int
+	// JSONUtils used to write error responses (optional)
+	JSONUtils utils.JSONUtils
+}
+
+// EventBroker publishes events to subscribers of topics
+type EventBroker interface {
+
+	// Publish event to topic. Returns event with its ID
+	Publish(topic string, event *Event) *Event
+
+	// Subscribe request to topic. Streams events till the client
+	// disconnects or the broker closes
+	Subscribe(w http.ResponseWriter, r *http.Request, topic string)
+
+	// Handler subscribing requests to topic returned for them
+	Handler(topic func(r *http.Request) string) func(w http.ResponseWriter, r *http.Request)
+
+	// Subscribers currently subscribed to topic
+	Subscribers(topic string) int
+
+	// Close all streams. Requests subscribing afterwards are rejected
+	Close()
+}
+
+// NewEventBroker with config (optional)
+func NewEventBroker(config *EventsConfiguration) EventBroker {
+
+	if config == nil {
+		config = &EventsConfiguration{}
+	}
+	broker := &eventBroker{
+		heartbeatInterval:    config.HeartbeatInterval,
+		retry:                config.Retry,
+		replayBuffer:         config.ReplayBuffer,
+		subscriberBufferSize: config.SubscriberBufferSize,
+		jsonUtils:            config.JSONUtils,
+		topics:               map[string]map[*subscriber]bool{},
+		closed:               make(chan struct{}),
+	}
+	if broker.heartbeatInterval == 0 {
+		broker.heartbeatInterval = DefaultHeartbeatInterval
+	}
+	if broker.replayBuffer == nil {
+		broker.replayBuffer = NewMemoryReplayBuffer(DefaultReplayBufferSize, DefaultReplayBufferTopics)
+	}
+	if broker.subscriberBufferSize == 0 {
+		broker.subscriberBufferSize = DefaultSubscriberBufferSize
+	}
+	if broker.jsonUtils == nil {
+		broker.jsonUtils = utils.Bootstrap(&utils.ContextIn{}).JSONUtils
+	}
+	return broker
+}
+
+// NewMemoryReplayBuffer keeping last size events of each topic. Events of
+// the topic published to least recently are dropped once more than
+// maxTopics topics are buffered
+func NewMemoryReplayBuffer(size int, maxTopics int) ReplayBuffer {
+	return &memoryReplayBuffer{size: size, maxTopics: maxTopics, topics: map[string]*list.Element{}, recent: list.New()}
+}
+
+// --------
+// Internal
+// --------
+
+type eventBroker struct {
+	heartbeatInterval    time.Duration
+	retry                time.Duration
+	replayBuffer         ReplayBuffer
+	subscriberBufferSize int
+	jsonUtils            utils.JSONUtils
+
+	lastID    int64
+	lock      sync.Mutex
+	topics    map[string]map[*subscriber]bool
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+type subscriber struct {
+	events chan *Event
+	// lagging is closed when subscriber fell too far behind
+	lagging chan struct{}
+}
+
+func (broker *eventBroker) Publish(topic string, event *Event) *Event {
+
+	// line breaks would let values inject fields or events into streams
+	published := *event
+	published.ID = stripLineBreaks(published.ID)
+	published.Event = stripLineBreaks(published.Event)
+	if published.ID == "" {
+		published.ID = strconv.FormatInt(atomic.AddInt64(&broker.lastID, 1), 10)
+	}
+
+	// buffering under lock, so subscribers see events in replay order
+	broker.lock.Lock()
+	defer broker.lock.Unlock()
+	broker.replayBuffer.Add(topic, &published)
+	for s := range broker.topics[topic] {
+		select {
+		case s.events <- &published:
+		default:
+			close(s.lagging)
+			delete(broker.topics[topic], s)
+		}
+	}
+	return &published
+}
+
+func (broker *eventBroker) Handler(topic func(r *http.Request) string) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		broker.Subscribe(w, r, topic(r))
+	}
+}
+
+func (broker *eventBroker) Subscribers(topic string) int {
+	broker.lock.Lock()
+	defer broker.lock.Unlock()
+	return len(broker.topics[topic])
+}
+
+func (broker *eventBroker) Close() {
+	broker.closeOnce.Do(func() { close(broker.closed) })
+}
+
+func (broker *eventBroker) Subscribe(w http.ResponseWriter, r *http.Request, topic string) {
+
+	select {
+	case <-broker.closed:
+		broker.jsonUtils.ServiceUnavailable(w, "Server is shutting down")
+		return
+	default:
+	}
+
+	// subscribe before replaying, so no events are missed in between
+	s := &subscriber{events: make(chan *Event, broker.subscriberBufferSize), lagging: make(chan struct{})}
+	broker.lock.Lock()
+	if broker.topics[topic] == nil {
+		broker.topics[topic] = map[*subscriber]bool{}
+	}
+	broker.topics[topic][s] = true
+	replay := []*Event{}
+	if lastEventID := r.Header.Get("Last-Event-ID"); lastEventID != "" {
+		replay = broker.replayBuffer.Since(topic, lastEventID)
+	}
+	broker.lock.Unlock()
+	defer broker.unsubscribe(topic, s)
+
+	// streams stay open, so they're exempt from the server's write timeout
+	controller := http.NewResponseController(w)
+	controller.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if broker.retry > 0 {
+		fmt.Fprintf(w, "retry: %d\n\n", broker.retry.Milliseconds())
+	}
+	for _, event := range replay {
+		writeEvent(w, event)
+	}
+	controller.Flush()
+
+	heartbeat := time.NewTicker(broker.heartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case event := <-s.events:
+			writeEvent(w, event)
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+		case <-s.lagging:
+			return
+		case <-broker.closed:
+			return
+		case <-r.Context().Done():
+			return
+		}
+		if controller.Flush() != nil {
+			return
+		}
+	}
+}
+
+func (broker *eventBroker) unsubscribe(topic string, s *subscriber) {
+	broker.lock.Lock()
+	defer broker.lock.Unlock()
+	delete(broker.topics[topic], s)
+	if len(broker.topics[topic]) == 0 {
+		delete(broker.topics, topic)
+	}
+}
+
+// writeEvent in text/event-stream framing
+func writeEvent(w http.ResponseWriter, event *Event) {
+	frame := &strings.Builder{}
+	if event.ID != "" {
+		fmt.Fprintf(frame, "id: %v\n", event.ID)
+	}
+	if event.Event != "" {
+		fmt.Fprintf(frame, "event: %v\n", event.Event)
+	}
+	if event.Retry > 0 {
+		fmt.Fprintf(frame, "retry: %d\n", event.Retry.Milliseconds())
+	}
+	for _, line := range strings.Split(strings.ReplaceAll(event.Data, "\r\n", "\n"), "\n") {
+		fmt.Fprintf(frame, "data: %v\n", line)
+	}
+	frame.WriteString("\n")
+	w.Write([]byte(frame.String()))
+}
+
+func stripLineBreaks(value string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(value)
+}
+
+type memoryReplayBuffer struct {
+	size      int
+	maxTopics int
+	lock      sync.Mutex
+
+	// topics by name, ordered from most to least recently published to
+	topics map[string]*list.Element
+	recent *list.List
+}
+
+type bufferedTopic struct {
+	name   string
+	events []*Event
+}
+
+func (buffer *memoryReplayBuffer) Add(topic string, event *Event) {
+	buffer.lock.Lock()
+	defer buffer.lock.Unlock()
+
+	element, found := buffer.topics[topic]
+	if found {
+		buffer.recent.MoveToFront(element)
+	} else {
+		element = buffer.recent.PushFront(&bufferedTopic{name: topic})
+		buffer.topics[topic] = element
+		if buffer.recent.Len() > buffer.maxTopics {
+			oldest := buffer.recent.Remove(buffer.recent.Back()).(*bufferedTopic)
+			delete(buffer.topics, oldest.name)
+		}
+	}
+
+	buffered := element.Value.(*bufferedTopic)
+	buffered.events = append(buffered.events, event)
+	if len(buffered.events) > buffer.size {
+		buffered.events = buffered.events[len(buffered.events)-buffer.size:]
+	}
+}
+
+func (buffer *memoryReplayBuffer) Since(topic string, lastEventID string) []*Event {
+	buffer.lock.Lock()
+	defer buffer.lock.Unlock()
+	element, found := buffer.topics[topic]
+	if !found {
+		return []*Event{}
+	}
+	events := element.Value.(*bufferedTopic).events
+	for i, event := range events {
+		if event.ID == lastEventID {
+			return append([]*Event{}, events[i+1:]...)
+		}
+	}
+	return append([]*Event{}, events...)
+}
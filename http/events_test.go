@@ -0,0 +1,199 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	httpUtils "github.com/saharsh-samples/go-mux-sql-starter/http/utils"
+	"github.com/saharsh-samples/go-mux-sql-starter/test"
+	"github.com/saharsh-samples/go-mux-sql-starter/utils"
+)
+
+type eventRoutes struct {
+	broker EventBroker
+}
+
+func (resource *eventRoutes) Register(agent RoutesAgent) {
+	agent.RegisterGet("/events/{topic}", resource.broker.Handler(func(r *http.Request) string {
+		return strings.TrimPrefix(r.URL.Path, "/events/")
+	}))
+}
+
+// readFrames from stream till count frames have been read
+func readFrames(reader *bufio.Reader, count int) []string {
+	frames := []string{}
+	frame := ""
+	for len(frames) < count {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			break
+		}
+		if line == "\n" {
+			frames = append(frames, frame)
+			frame = ""
+			continue
+		}
+		frame += line
+	}
+	return frames
+}
+
+func TestEventBroker(t *testing.T) {
+
+	// arrange
+	broker := NewEventBroker(&EventsConfiguration{Retry: 3 * time.Second})
+	server := Bootstrap(&ContextIn{
+		Port:             0,
+		RoutesToRegister: []Routes{&eventRoutes{broker: broker}},
+		EventBrokers:     []EventBroker{broker},
+		Timeouts:         ServerTimeouts{WriteTimeout: 50 * time.Millisecond},
+	}).Server
+	go server.Run()
+	utils.WaitTill(func() bool { return server.IsReady() }, 10)
+	port, _ := server.Port()
+
+	// act
+	response, err := http.Get(fmt.Sprintf("http://localhost:%v/events/orders", port))
+	test.AssertTrue("Expected subscription to succeed", err == nil, t)
+	if err != nil {
+		return
+	}
+	defer response.Body.Close()
+	reader := bufio.NewReader(response.Body)
+	retry := readFrames(reader, 1)
+
+	utils.WaitTill(func() bool { return broker.Subscribers("orders") == 1 }, 10)
+	time.Sleep(100 * time.Millisecond) // past write timeout
+	broker.Publish("orders", &Event{Event: "created", Data: "line 1\nline 2"})
+	event, _ := NewJSONEvent("", map[string]int{"ID": 7})
+	broker.Publish("orders", event)
+	broker.Publish("other", &Event{Data: "not subscribed"})
+	frames := readFrames(reader, 2)
+
+	// assert
+	test.AssertEquals("", "text/event-stream", response.Header.Get("Content-Type"), t)
+	test.AssertEquals("", "retry: 3000\n", strings.Join(retry, ""), t)
+	test.AssertEquals("", 2, len(frames), t)
+	test.AssertEquals("", "id: 1\nevent: created\ndata: line 1\ndata: line 2\n", frames[0], t)
+	test.AssertEquals("", "id: 2\ndata: {\"ID\":7}\n", frames[1], t)
+
+	// shutdown closes streams
+	test.AssertTrue("Expected no errors shutting down", server.Shutdown() == nil, t)
+	_, err = reader.ReadString('\n')
+	test.AssertTrue("Expected stream to end", err != nil, t)
+}
+
+func TestEventBroker_resumes_from_last_event_id(t *testing.T) {
+
+	// arrange
+	broker := NewEventBroker(&EventsConfiguration{ReplayBuffer: NewMemoryReplayBuffer(2, DefaultReplayBufferTopics)})
+	for i := 1; i <= 3; i++ {
+		broker.Publish("orders", &Event{Data: fmt.Sprint(i)})
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	r := httptest.NewRequest(http.MethodGet, "/events/orders", nil).WithContext(ctx)
+	r.Header.Set("Last-Event-ID", "2")
+	w := httptest.NewRecorder()
+
+	// act
+	go func() {
+		utils.WaitTill(func() bool { return broker.Subscribers("orders") == 1 }, 10)
+		cancel()
+	}()
+	broker.Subscribe(w, r, "orders")
+
+	// assert
+	test.AssertEquals("", "id: 3\ndata: 3\n\n", w.Body.String(), t)
+	test.AssertEquals("Expected subscriber to be removed", 0, broker.Subscribers("orders"), t)
+
+	// unknown ID replays all buffered events
+	r = httptest.NewRequest(http.MethodGet, "/events/orders", nil)
+	r.Header.Set("Last-Event-ID", "unknown")
+	w = httptest.NewRecorder()
+	broker.Close()
+	broker.Subscribe(w, r, "orders")
+	test.AssertEquals("Expected closed broker to reject subscriptions", http.StatusServiceUnavailable, w.Code, t)
+	errorMsg := &httpUtils.ErrorMessage{}
+	json.Unmarshal(w.Body.Bytes(), errorMsg)
+	test.AssertEquals("", "Server is shutting down", errorMsg.Detail, t)
+}
+
+func TestEventBroker_heartbeats_and_lagging_subscribers(t *testing.T) {
+
+	// arrange
+	broker := NewEventBroker(&EventsConfiguration{HeartbeatInterval: 10 * time.Millisecond, SubscriberBufferSize: 1})
+	w := httptest.NewRecorder()
+	done := make(chan struct{})
+
+	// act
+	go func() {
+		broker.Subscribe(w, httptest.NewRequest(http.MethodGet, "/events/orders", nil), "orders")
+		close(done)
+	}()
+	utils.WaitTill(func() bool { return broker.Subscribers("orders") == 1 }, 10)
+	time.Sleep(50 * time.Millisecond)
+	select {
+	case <-done:
+		t.Error("Expected stream to be open")
+	default:
+	}
+
+	// subscriber can't keep up with a burst
+	for i := 0; i < 100; i++ {
+		broker.Publish("orders", &Event{Data: "burst"})
+	}
+
+	// assert
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("Expected lagging subscriber to be disconnected")
+	}
+	test.AssertTrue("Expected heartbeats", strings.Contains(w.Body.String(), ": heartbeat\n\n"), t)
+}
+
+func TestMemoryReplayBuffer_evicts_least_recently_published_topics(t *testing.T) {
+
+	// arrange
+	buffer := NewMemoryReplayBuffer(2, 2)
+
+	// act
+	buffer.Add("a", &Event{ID: "1"})
+	buffer.Add("b", &Event{ID: "2"})
+	buffer.Add("a", &Event{ID: "3"})
+	buffer.Add("c", &Event{ID: "4"})
+
+	// assert
+	test.AssertEquals("", 2, len(buffer.Since("a", "")), t)
+	test.AssertEquals("Expected topic b to be evicted", 0, len(buffer.Since("b", "")), t)
+	test.AssertEquals("", 1, len(buffer.Since("c", "")), t)
+}
+
+func TestEventBroker_strips_line_breaks(t *testing.T) {
+
+	// arrange
+	broker := NewEventBroker(nil)
+
+	// act
+	published := broker.Publish("orders", &Event{ID: "1\ndata: injected", Event: "created\r\nretry: 1", Data: "a"})
+	r := httptest.NewRequest(http.MethodGet, "/events/orders", nil)
+	r.Header.Set("Last-Event-ID", "0")
+	ctx, cancel := context.WithCancel(context.Background())
+	w := httptest.NewRecorder()
+	go func() {
+		utils.WaitTill(func() bool { return broker.Subscribers("orders") == 1 }, 10)
+		cancel()
+	}()
+	broker.Subscribe(w, r.WithContext(ctx), "orders")
+
+	// assert
+	test.AssertEquals("", "1data: injected", published.ID, t)
+	test.AssertEquals("", "id: 1data: injected\nevent: createdretry: 1\ndata: a\n\n", w.Body.String(), t)
+}
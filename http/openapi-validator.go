@@ -0,0 +1,546 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+	"github.com/saharsh-samples/go-mux-sql-starter/http/utils"
+)
+
+// RequestValidationConfiguration enables validation of requests against
+// an OpenAPI document before handlers run
+type RequestValidationConfiguration struct {
+
+	// Document to validate against. Loaded from DocumentPath if nil, or
+	// generated from registered routes if both are unset. Generated
+	// documents are per API version, so versions can share paths
+	Document     *OpenAPIDocument
+	DocumentPath string
+}
+
+// --------
+// Internal
+// --------
+
+type requestValidator struct {
+
+	// documents by API version. Key "" is used for all other routes
+	documents map[string]*OpenAPIDocument
+
+	// patterns of all documents, compiled once
+	patterns  map[string]*regexp.Regexp
+	jsonUtils utils.JSONUtils
+}
+
+func newRequestValidator(config *RequestValidationConfiguration, in *ContextIn, jsonUtils utils.JSONUtils) *requestValidator {
+
+	document := config.Document
+	if document == nil && config.DocumentPath != "" {
+		loaded, err := LoadOpenAPIDocument(config.DocumentPath)
+		if err != nil {
+			panic(err)
+		}
+		document = loaded
+	}
+	documents := map[string]*OpenAPIDocument{"": document}
+	if document == nil {
+		documents = generateOpenAPIDocuments(in)
+	}
+
+	patterns := make(map[string]*regexp.Regexp)
+	for _, document := range documents {
+		if err := compilePatterns(document, patterns); err != nil {
+			panic(err)
+		}
+	}
+
+	return &requestValidator{documents: documents, patterns: patterns, jsonUtils: jsonUtils}
+}
+
+// compilePatterns of all schemas in document into patterns. Returns error
+// for invalid patterns so they fail startup instead of passing any value
+func compilePatterns(document *OpenAPIDocument, patterns map[string]*regexp.Regexp) error {
+
+	var compile func(schema *OpenAPISchema) error
+	compile = func(schema *OpenAPISchema) error {
+		if schema == nil {
+			return nil
+		}
+		if _, compiled := patterns[schema.Pattern]; schema.Pattern != "" && !compiled {
+			pattern, err := regexp.Compile(schema.Pattern)
+			if err != nil {
+				return fmt.Errorf("Invalid OpenAPI schema pattern '%v': %v", schema.Pattern, err)
+			}
+			patterns[schema.Pattern] = pattern
+		}
+		nested := append([]*OpenAPISchema{schema.Items, schema.AdditionalProperties}, schema.AllOf...)
+		nested = append(nested, schema.AnyOf...)
+		for _, property := range schema.Properties {
+			nested = append(nested, property)
+		}
+		for _, child := range nested {
+			if err := compile(child); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, schema := range document.Components.Schemas {
+		if err := compile(schema); err != nil {
+			return err
+		}
+	}
+	for _, operations := range document.Paths {
+		for _, operation := range operations {
+			if operation == nil {
+				continue
+			}
+			for _, parameter := range operation.Parameters {
+				if err := compile(parameter.Schema); err != nil {
+					return err
+				}
+			}
+			if operation.RequestBody == nil {
+				continue
+			}
+			for _, mediaType := range operation.RequestBody.Content {
+				if mediaType == nil {
+					continue
+				}
+				if err := compile(mediaType.Schema); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// validateRequest against operation documented for matched route. Requests
+// for undocumented routes are passed through
+func (validator *requestValidator) validateRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		metadata, found := GetRouteMetadata(r)
+		if !found {
+			next.ServeHTTP(w, r)
+			return
+		}
+		document := validator.documents[metadata.Version]
+		if document == nil {
+			document = validator.documents[""]
+		}
+		path, _ := openAPIPath(metadata.Path)
+		operation := document.Paths[path][strings.ToLower(metadata.Method)]
+		if operation == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		fieldErrors := &fieldErrors{document: document, patterns: validator.patterns}
+		for _, parameter := range operation.Parameters {
+			fieldErrors.validateParameter(r, parameter)
+		}
+
+		// bodies of other media types are left to decoders and validation
+		// tags, as they can't be checked against JSON schemas generically
+		if schema, found := jsonBodySchema(operation.RequestBody, r.Header.Get("Content-Type")); found {
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				var maxBytesErr *http.MaxBytesError
+				if errors.As(err, &maxBytesErr) {
+					validator.jsonUtils.RequestEntityTooLarge(w, fmt.Sprintf("Request body must not exceed %d bytes", maxBytesErr.Limit))
+				} else {
+					validator.jsonUtils.BadRequest(w, "Unable to read request body")
+				}
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			fieldErrors.validateBody(body, operation.RequestBody, schema)
+		}
+
+		if len(fieldErrors.errors) > 0 {
+			validator.jsonUtils.ValidationFailed(w, fieldErrors.errors)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// fieldErrors collects errors found while validating a request
+type fieldErrors struct {
+	document *OpenAPIDocument
+	patterns map[string]*regexp.Regexp
+	in       string
+	errors   []utils.FieldError
+}
+
+func (fe *fieldErrors) add(field string, code string, format string, args ...interface{}) {
+	fe.errors = append(fe.errors, utils.FieldError{Field: field, In: fe.in, Code: code, Message: fmt.Sprintf(format, args...)})
+}
+
+func (fe *fieldErrors) validateParameter(r *http.Request, parameter *OpenAPIParameter) {
+
+	fe.in = parameter.In
+
+	var values []string
+	switch parameter.In {
+	case "path":
+		if value, found := mux.Vars(r)[parameter.Name]; found {
+			values = []string{value}
+		}
+	case "query":
+		values = r.URL.Query()[parameter.Name]
+	case "header":
+		values = r.Header.Values(parameter.Name)
+	default:
+		return
+	}
+
+	if len(values) == 0 {
+		if parameter.Required {
+			fe.add(parameter.Name, "required", "is required")
+		}
+		return
+	}
+
+	schema := fe.resolve(parameter.Schema)
+	if schema == nil {
+		return
+	}
+
+	// repeated values are only allowed for arrays (URLUtils style)
+	if hasType(schema, "array") {
+		items := make([]interface{}, len(values))
+		for i, value := range values {
+			items[i] = coerceParameter(value, fe.resolve(schema.Items))
+		}
+		fe.validate(schema, items, parameter.Name)
+		return
+	}
+	if len(values) > 1 {
+		fe.add(parameter.Name, "multiple", "expected ONE and only ONE value")
+		return
+	}
+	fe.validate(schema, coerceParameter(values[0], schema), parameter.Name)
+}
+
+// coerceParameter to JSON value of the type described by schema. Values
+// that can't be coerced are left as strings to fail validation
+func coerceParameter(value string, schema *OpenAPISchema) interface{} {
+	switch {
+	case schema == nil:
+		return value
+	case hasType(schema, "integer"), hasType(schema, "number"):
+		if _, err := strconv.ParseFloat(value, 64); err == nil {
+			return json.Number(value)
+		}
+	case hasType(schema, "boolean"):
+		if parsed, err := strconv.ParseBool(strings.ToLower(value)); err == nil {
+			return parsed
+		}
+	}
+	return value
+}
+
+// jsonBodySchema documented for contentType, if request body is JSON.
+// Like decoders, bodies without content type are JSON, and structured
+// syntax suffixes (e.g. 'application/vnd.app.v2+json') fall back to the
+// schema of application/json
+func jsonBodySchema(requestBody *OpenAPIRequestBody, contentType string) (*OpenAPISchema, bool) {
+
+	if requestBody == nil {
+		return nil, false
+	}
+	mediaType := utils.JSONMediaType
+	if strings.TrimSpace(contentType) != "" {
+		parsed, _, err := mime.ParseMediaType(contentType)
+		if err != nil {
+			return nil, false
+		}
+		mediaType = parsed
+	}
+	if mediaType != utils.JSONMediaType && !strings.HasSuffix(mediaType, "+json") {
+		return nil, false
+	}
+
+	for _, candidate := range []string{mediaType, utils.JSONMediaType} {
+		if content := requestBody.Content[candidate]; content != nil {
+			return content.Schema, true
+		}
+	}
+	return nil, false
+}
+
+func (fe *fieldErrors) validateBody(body []byte, requestBody *OpenAPIRequestBody, schema *OpenAPISchema) {
+
+	fe.in = "body"
+
+	if len(bytes.TrimSpace(body)) == 0 {
+		if requestBody.Required {
+			fe.add("", "required", "request body is required")
+		}
+		return
+	}
+
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		fe.add("", "json", "request body is not valid JSON: %v", err)
+		return
+	}
+
+	fe.validate(schema, value, "")
+}
+
+// resolve local $ref. References that loop back to themselves resolve to
+// nil, like unknown ones
+func (fe *fieldErrors) resolve(schema *OpenAPISchema) *OpenAPISchema {
+	visited := make(map[string]bool)
+	for schema != nil && schema.Ref != "" {
+		if visited[schema.Ref] {
+			return nil
+		}
+		visited[schema.Ref] = true
+		schema = fe.document.Components.Schemas[strings.TrimPrefix(schema.Ref, "#/components/schemas/")]
+	}
+	return schema
+}
+
+func (fe *fieldErrors) validate(schema *OpenAPISchema, value interface{}, field string) {
+
+	schema = fe.resolve(schema)
+	if schema == nil {
+		return
+	}
+
+	for _, part := range schema.AllOf {
+		fe.validate(part, value, field)
+	}
+	if len(schema.AnyOf) > 0 && !fe.validateAnyOf(schema.AnyOf, value, field) {
+		return
+	}
+
+	if value == nil {
+		if schemaTypes(schema) != nil && !hasType(schema, "null") {
+			fe.add(field, "type", "must not be null")
+		}
+		return
+	}
+
+	if len(schema.Enum) > 0 && !inEnum(schema.Enum, value) {
+		fe.add(field, "enum", "must be one of %v", schema.Enum)
+		return
+	}
+
+	switch typed := value.(type) {
+
+	case map[string]interface{}:
+		if !fe.expectType(schema, field, "object") {
+			return
+		}
+		fe.validateObject(schema, typed, field)
+
+	case []interface{}:
+		if !fe.expectType(schema, field, "array") {
+			return
+		}
+		if schema.MinItems != nil && len(typed) < *schema.MinItems {
+			fe.add(field, "min", "must have at least %d items", *schema.MinItems)
+		}
+		if schema.MaxItems != nil && len(typed) > *schema.MaxItems {
+			fe.add(field, "max", "must have at most %d items", *schema.MaxItems)
+		}
+		for i, item := range typed {
+			fe.validate(schema.Items, item, fmt.Sprintf("%v[%d]", field, i))
+		}
+
+	case string:
+		if !fe.expectType(schema, field, "string") {
+			return
+		}
+		fe.validateString(schema, typed, field)
+
+	case json.Number:
+		number, _ := typed.Float64()
+		isInteger := !strings.ContainsAny(typed.String(), ".eE") || number == float64(int64(number))
+		if hasType(schema, "integer") && !hasType(schema, "number") && !isInteger {
+			fe.add(field, "type", "must be an integer")
+			return
+		}
+		if !fe.expectType(schema, field, "number", "integer") {
+			return
+		}
+		if schema.Minimum != nil && number < *schema.Minimum {
+			fe.add(field, "min", "must be at least %v", *schema.Minimum)
+		}
+		if schema.Maximum != nil && number > *schema.Maximum {
+			fe.add(field, "max", "must be at most %v", *schema.Maximum)
+		}
+
+	case bool:
+		fe.expectType(schema, field, "boolean")
+	}
+}
+
+// validateAnyOf reports errors of first alternative if value matches none
+func (fe *fieldErrors) validateAnyOf(alternatives []*OpenAPISchema, value interface{}, field string) bool {
+	var firstErrors []utils.FieldError
+	for i, alternative := range alternatives {
+		attempt := &fieldErrors{document: fe.document, patterns: fe.patterns, in: fe.in}
+		attempt.validate(alternative, value, field)
+		if len(attempt.errors) == 0 {
+			return true
+		}
+		if i == 0 {
+			firstErrors = attempt.errors
+		}
+	}
+	fe.errors = append(fe.errors, firstErrors...)
+	return false
+}
+
+func (fe *fieldErrors) validateObject(schema *OpenAPISchema, value map[string]interface{}, field string) {
+
+	// like encoding/json, property names are matched case insensitively
+	matched := make(map[string]bool, len(value))
+	lookup := func(name string) (interface{}, bool) {
+		if propertyValue, found := value[name]; found {
+			matched[name] = true
+			return propertyValue, true
+		}
+		for key, propertyValue := range value {
+			if strings.EqualFold(key, name) {
+				matched[key] = true
+				return propertyValue, true
+			}
+		}
+		return nil, false
+	}
+
+	for _, name := range schema.Required {
+		if _, found := lookup(name); !found {
+			fe.add(joinField(field, name), "required", "is required")
+		}
+	}
+
+	names := make([]string, 0, len(schema.Properties))
+	for name := range schema.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if propertyValue, found := lookup(name); found {
+			fe.validate(schema.Properties[name], propertyValue, joinField(field, name))
+		}
+	}
+
+	if schema.AdditionalProperties != nil {
+		for key, propertyValue := range value {
+			if !matched[key] {
+				fe.validate(schema.AdditionalProperties, propertyValue, joinField(field, key))
+			}
+		}
+	}
+}
+
+func (fe *fieldErrors) validateString(schema *OpenAPISchema, value string, field string) {
+
+	length := utf8.RuneCountInString(value)
+	if schema.MinLength != nil && length < *schema.MinLength {
+		fe.add(field, "min", "must be at least %d characters long", *schema.MinLength)
+	}
+	if schema.MaxLength != nil && length > *schema.MaxLength {
+		fe.add(field, "max", "must be at most %d characters long", *schema.MaxLength)
+	}
+	if schema.Pattern != "" {
+		if pattern := fe.patterns[schema.Pattern]; pattern != nil && !pattern.MatchString(value) {
+			fe.add(field, "pattern", "must match pattern '%v'", schema.Pattern)
+		}
+	}
+
+	switch schema.Format {
+	case "date":
+		if _, err := time.Parse(utils.DateFormat, value); err != nil {
+			fe.add(field, "format", "must be a date formatted as YYYY-MM-DD")
+		}
+	case "date-time":
+		if _, err := time.Parse(time.RFC3339, value); err != nil {
+			fe.add(field, "format", "must be a date-time formatted as RFC 3339")
+		}
+	}
+}
+
+// expectType reports an error if schema declares types and none of them
+// is one of the specified types
+func (fe *fieldErrors) expectType(schema *OpenAPISchema, field string, types ...string) bool {
+	declared := schemaTypes(schema)
+	if declared == nil {
+		return true
+	}
+	for _, t := range types {
+		if hasType(schema, t) {
+			return true
+		}
+	}
+	fe.add(field, "type", "must be of type %v", strings.Join(declared, " or "))
+	return false
+}
+
+// schemaTypes handles type declared as a string or, in loaded documents,
+// a list of strings
+func schemaTypes(schema *OpenAPISchema) []string {
+	switch typed := schema.Type.(type) {
+	case string:
+		return []string{typed}
+	case []string:
+		return typed
+	case []interface{}:
+		types := make([]string, 0, len(typed))
+		for _, t := range typed {
+			types = append(types, fmt.Sprint(t))
+		}
+		return types
+	}
+	return nil
+}
+
+func hasType(schema *OpenAPISchema, t string) bool {
+	for _, declared := range schemaTypes(schema) {
+		if declared == t {
+			return true
+		}
+	}
+	return false
+}
+
+func inEnum(enum []interface{}, value interface{}) bool {
+	for _, allowed := range enum {
+		if fmt.Sprint(allowed) == fmt.Sprint(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func joinField(parent string, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
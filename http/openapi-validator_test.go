@@ -0,0 +1,261 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	httpUtils "github.com/saharsh-samples/go-mux-sql-starter/http/utils"
+	"github.com/saharsh-samples/go-mux-sql-starter/test"
+	"github.com/saharsh-samples/go-mux-sql-starter/utils"
+)
+
+type validatedAddress struct {
+	Lines []string
+	Zip   string `validate:"required"`
+}
+
+type validatedThing struct {
+	Name    string `validate:"required"`
+	Count   int
+	Born    httpUtils.JSONDate `validate:"required"`
+	Address *validatedAddress
+}
+
+type validatedRoutes struct{}
+
+func (resource *validatedRoutes) Register(agent RoutesAgent) {
+	agent.Register(http.MethodPost, "/things/{id:[0-9]+}", SuccessHandler,
+		WithRequestBody(&validatedThing{}),
+		WithQueryParameter("dryRun", false, false),
+		WithQueryParameter("tag", []string{}, false),
+		WithHeaderParameter("X-Request-Id", "", true))
+	agent.RegisterGet("/undocumented", SuccessHandler)
+}
+
+func runValidatingServer(t *testing.T, config *RequestValidationConfiguration) (Server, func(method string, path string, headers map[string]string, body string) (int, *httpUtils.ErrorMessage)) {
+
+	server := Bootstrap(&ContextIn{
+		Port:              0,
+		RoutesToRegister:  []Routes{&validatedRoutes{}},
+		RequestValidation: config,
+	}).Server
+
+	go server.Run()
+	utils.WaitTill(func() bool { return server.IsReady() }, 10)
+	port, _ := server.Port()
+
+	do := func(method string, path string, headers map[string]string, body string) (int, *httpUtils.ErrorMessage) {
+		request, _ := http.NewRequest(method, fmt.Sprintf("http://localhost:%d%s", port, path), strings.NewReader(body))
+		for name, value := range headers {
+			request.Header.Set(name, value)
+		}
+		resp, err := http.DefaultClient.Do(request)
+		test.AssertTrue("Expected no errors doing "+method+" "+path, err == nil, t)
+		defer resp.Body.Close()
+		errorMsg := &httpUtils.ErrorMessage{}
+		json.NewDecoder(resp.Body).Decode(errorMsg)
+		return resp.StatusCode, errorMsg
+	}
+
+	return server, do
+}
+
+func fieldErrorsOf(errorMsg *httpUtils.ErrorMessage) string {
+	described := make([]string, len(errorMsg.Errors))
+	for i, fieldError := range errorMsg.Errors {
+		described[i] = fieldError.In + ":" + fieldError.Field + " " + fieldError.Message
+	}
+	return strings.Join(described, "; ")
+}
+
+func TestRun_with_request_validation(t *testing.T) {
+
+	// arrange
+	server, do := runValidatingServer(t, &RequestValidationConfiguration{})
+	defer server.Shutdown()
+	headers := map[string]string{"X-Request-Id": "abc"}
+
+	// act and assert
+
+	// valid requests pass through
+	status, _ := do(http.MethodPost, "/things/1?dryRun=TRUE&tag=a&tag=b", headers, `{"Name": "a", "Count": 1, "Born": "2020-01-01"}`)
+	test.AssertEquals("", 200, status, t)
+
+	// property names are case insensitive like encoding/json
+	status, _ = do(http.MethodPost, "/things/1", headers, `{"name": "a", "count": 1, "born": "2020-01-01", "address": null}`)
+	test.AssertEquals("", 200, status, t)
+
+	// fields without 'required' validation may be omitted
+	status, _ = do(http.MethodPost, "/things/1", headers, `{"Name": "a", "Born": "2020-01-01"}`)
+	test.AssertEquals("", 200, status, t)
+
+	// undocumented routes are not validated
+	status, _ = do(http.MethodGet, "/undocumented", nil, "")
+	test.AssertEquals("", 200, status, t)
+
+	// parameters
+	status, errorMsg := do(http.MethodPost, "/things/1?dryRun=maybe", nil, `{"Name": "a", "Count": 1, "Born": "2020-01-01"}`)
+	test.AssertEquals("", 400, status, t)
+	test.AssertEquals("", "Request failed validation", errorMsg.Detail, t)
+	test.AssertEquals("",
+		"query:dryRun must be of type boolean; header:X-Request-Id is required",
+		fieldErrorsOf(errorMsg), t)
+
+	// body fields
+	status, errorMsg = do(http.MethodPost, "/things/1", headers, `{"Count": 1.5, "Born": "01/01/2020", "Address": {"Lines": ["a", 2]}}`)
+	test.AssertEquals("", 400, status, t)
+	test.AssertEquals("",
+		"body:Name is required; body:Address.Zip is required; body:Address.Lines[1] must be of type string; "+
+			"body:Born must be a date formatted as YYYY-MM-DD; body:Count must be an integer",
+		fieldErrorsOf(errorMsg), t)
+
+	// malformed body
+	status, errorMsg = do(http.MethodPost, "/things/1", headers, `{"Name": `)
+	test.AssertEquals("", 400, status, t)
+	test.AssertEquals("", "body", errorMsg.Errors[0].In, t)
+
+	// bodies of other media types are left to handlers
+	xmlHeaders := map[string]string{"X-Request-Id": "abc", "Content-Type": "application/xml"}
+	status, _ = do(http.MethodPost, "/things/1", xmlHeaders, `<validatedThing><Name>a</Name></validatedThing>`)
+	test.AssertEquals("", 200, status, t)
+
+	// but JSON with structured syntax suffix is validated
+	vendorHeaders := map[string]string{"X-Request-Id": "abc", "Content-Type": "application/vnd.things+json; charset=utf-8"}
+	status, errorMsg = do(http.MethodPost, "/things/1", vendorHeaders, `{"Count": 1, "Born": "2020-01-01"}`)
+	test.AssertEquals("", 400, status, t)
+	test.AssertEquals("", "body:Name is required", fieldErrorsOf(errorMsg), t)
+}
+
+func TestRun_with_request_validation_against_loaded_document(t *testing.T) {
+
+	// arrange
+	documentPath := filepath.Join(t.TempDir(), "openapi.json")
+	os.WriteFile(documentPath, []byte(`{
+		"openapi": "3.1.0",
+		"info": {"title": "Things", "version": "1"},
+		"paths": {
+			"/things/{id}": {
+				"summary": "path items can hold more than operations",
+				"parameters": [{"name": "id", "in": "path", "required": true, "schema": {"type": "integer", "maximum": 100}}],
+				"post": {
+					"operationId": "updateThing",
+					"requestBody": {
+						"required": true,
+						"content": {"application/json": {"schema": {"$ref": "#/components/schemas/Thing"}}}
+					},
+					"responses": {"200": {"description": "OK"}}
+				}
+			}
+		},
+		"components": {
+			"schemas": {
+				"Thing": {
+					"type": "object",
+					"required": ["Name"],
+					"properties": {
+						"Name": {"type": "string", "minLength": 2, "pattern": "^[a-z]+$"},
+						"Kind": {"type": ["string", "null"], "enum": ["big", "small", null]},
+						"Loop": {"$ref": "#/components/schemas/Loop"}
+					}
+				},
+				"Loop": {"$ref": "#/components/schemas/Loop"}
+			}
+		}
+	}`), 0600)
+
+	server, do := runValidatingServer(t, &RequestValidationConfiguration{DocumentPath: documentPath})
+	defer server.Shutdown()
+
+	// act and assert
+	status, _ := do(http.MethodPost, "/things/100", nil, `{"Name": "ab", "Kind": null}`)
+	test.AssertEquals("", 200, status, t)
+
+	// cyclic references don't constrain values
+	status, _ = do(http.MethodPost, "/things/100", nil, `{"Name": "ab", "Loop": 1}`)
+	test.AssertEquals("", 200, status, t)
+
+	status, errorMsg := do(http.MethodPost, "/things/100", nil, `{"Name": "AB"}`)
+	test.AssertEquals("", 400, status, t)
+	test.AssertEquals("", "body:Name must match pattern '^[a-z]+$'", fieldErrorsOf(errorMsg), t)
+
+	status, errorMsg = do(http.MethodPost, "/things/101", nil, `{"Name": "a", "Kind": "medium"}`)
+	test.AssertEquals("", 400, status, t)
+	test.AssertEquals("",
+		"path:id must be at most 100; body:Kind must be one of [big small <nil>]; body:Name must be at least 2 characters long",
+		fieldErrorsOf(errorMsg), t)
+
+	status, errorMsg = do(http.MethodPost, "/things/1", nil, "")
+	test.AssertEquals("", 400, status, t)
+	test.AssertEquals("", "body: request body is required", fieldErrorsOf(errorMsg), t)
+}
+
+func TestBootstrap_with_invalid_pattern_in_validated_document(t *testing.T) {
+
+	// arrange
+	document := &OpenAPIDocument{
+		Components: OpenAPIComponents{Schemas: map[string]*OpenAPISchema{
+			"Thing": {Type: "object", Properties: map[string]*OpenAPISchema{"Name": {Type: "string", Pattern: "[a-z"}}},
+		}},
+	}
+
+	// act and assert
+	defer test.AssertPanic("Expected invalid pattern to fail startup", t)
+	Bootstrap(&ContextIn{Port: 0, RequestValidation: &RequestValidationConfiguration{Document: document}})
+}
+
+func TestLoadOpenAPIDocument_with_bad_file(t *testing.T) {
+
+	_, err := LoadOpenAPIDocument(filepath.Join(t.TempDir(), "missing.json"))
+	test.AssertTrue("Expected error loading missing file", err != nil, t)
+
+	documentPath := filepath.Join(t.TempDir(), "openapi.json")
+	os.WriteFile(documentPath, []byte(`{"paths": {"/things": {"get": []}}}`), 0600)
+	_, err = LoadOpenAPIDocument(documentPath)
+	test.AssertTrue("Expected error loading malformed operation", strings.Contains(err.Error(), "get /things"), t)
+}
+
+type validatedThingV2 struct {
+	Title string `validate:"required"`
+}
+
+type versionedValidatedRoutes struct{}
+
+func (resource *versionedValidatedRoutes) Register(agent RoutesAgent) {
+	agent.Version("v1").Register(http.MethodPost, "/things", SuccessHandler, WithRequestBody(&validatedThing{}))
+	agent.Version("v2").Register(http.MethodPost, "/things", SuccessHandler, WithRequestBody(&validatedThingV2{}))
+}
+
+func TestRun_with_request_validation_of_versions_sharing_paths(t *testing.T) {
+
+	// arrange
+	server := Bootstrap(&ContextIn{
+		Port:              0,
+		RoutesToRegister:  []Routes{&versionedValidatedRoutes{}},
+		Versioning:        &VersioningConfiguration{Strategy: HeaderVersioning, DefaultVersion: "v2"},
+		RequestValidation: &RequestValidationConfiguration{},
+	}).Server
+	go server.Run()
+	utils.WaitTill(func() bool { return server.IsReady() }, 10)
+	defer server.Shutdown()
+	port, _ := server.Port()
+
+	post := func(version string, body string) int {
+		request, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("http://localhost:%d/things", port), strings.NewReader(body))
+		request.Header.Set(DefaultVersionHeader, version)
+		resp, err := http.DefaultClient.Do(request)
+		test.AssertTrue("Expected no errors doing POST /things", err == nil, t)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// act and assert
+	test.AssertEquals("", 200, post("v1", `{"Name": "a", "Count": 1, "Born": "2020-01-01"}`), t)
+	test.AssertEquals("", 400, post("v1", `{"Title": "a"}`), t)
+	test.AssertEquals("", 200, post("v2", `{"Title": "a"}`), t)
+	test.AssertEquals("", 400, post("v2", `{"Name": "a", "Count": 1, "Born": "2020-01-01"}`), t)
+}
@@ -0,0 +1,667 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/saharsh-samples/go-mux-sql-starter/http/utils"
+)
+
+// DefaultOpenAPIPath value
+const DefaultOpenAPIPath = "/openapi.json"
+
+// OpenAPIConfiguration describes the generated OpenAPI document
+type OpenAPIConfiguration struct {
+	Title       string
+	Version     string
+	Description string
+	Servers     []string
+
+	// Path document is served at (defaults to DefaultOpenAPIPath)
+	Path string
+
+	// APIVersion limits document to routes registered under that version
+	// (and unversioned routes). Needed when versions share paths, as with
+	// MediaTypeVersioning and HeaderVersioning, where it defaults to
+	// VersioningConfiguration.DefaultVersion
+	APIVersion string
+
+	// SecuritySchemes accepted by routes registered with RequiresAuth, any
+	// one of which satisfies a route (defaults to DefaultOpenAPISecuritySchemes)
+	SecuritySchemes map[string]*OpenAPISecurityScheme
+}
+
+// DefaultOpenAPISecuritySchemes match bearer tokens and API keys in the
+// X-API-Key header
+var DefaultOpenAPISecuritySchemes = map[string]*OpenAPISecurityScheme{
+	"bearerAuth": {Type: "http", Scheme: "bearer"},
+	"apiKeyAuth": {Type: "apiKey", Name: "X-API-Key", In: "header"},
+}
+
+// --------------
+// Document model
+// --------------
+
+// OpenAPIDocument is an OpenAPI 3.1 document
+type OpenAPIDocument struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       OpenAPIInfo                             `json:"info"`
+	Servers    []OpenAPIServer                         `json:"servers,omitempty"`
+	Paths      map[string]map[string]*OpenAPIOperation `json:"paths"`
+	Components OpenAPIComponents                       `json:"components"`
+}
+
+// OpenAPIInfo object
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// OpenAPIServer object
+type OpenAPIServer struct {
+	URL string `json:"url"`
+}
+
+// OpenAPIOperation object
+type OpenAPIOperation struct {
+	OperationID string                      `json:"operationId"`
+	Summary     string                      `json:"summary,omitempty"`
+	Tags        []string                    `json:"tags,omitempty"`
+	Deprecated  bool                        `json:"deprecated,omitempty"`
+	Parameters  []*OpenAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *OpenAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*OpenAPIResponse `json:"responses"`
+	Security    []map[string][]string       `json:"security,omitempty"`
+}
+
+// OpenAPIParameter object
+type OpenAPIParameter struct {
+	Name     string         `json:"name"`
+	In       string         `json:"in"`
+	Required bool           `json:"required"`
+	Schema   *OpenAPISchema `json:"schema"`
+}
+
+// OpenAPIRequestBody object
+type OpenAPIRequestBody struct {
+	Required bool                         `json:"required"`
+	Content  map[string]*OpenAPIMediaType `json:"content"`
+}
+
+// OpenAPIResponse object
+type OpenAPIResponse struct {
+	Description string                       `json:"description"`
+	Content     map[string]*OpenAPIMediaType `json:"content,omitempty"`
+}
+
+// OpenAPIMediaType object
+type OpenAPIMediaType struct {
+	Schema *OpenAPISchema `json:"schema"`
+}
+
+// OpenAPIComponents object
+type OpenAPIComponents struct {
+	Schemas         map[string]*OpenAPISchema         `json:"schemas,omitempty"`
+	SecuritySchemes map[string]*OpenAPISecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// OpenAPISecurityScheme object
+type OpenAPISecurityScheme struct {
+	Type   string `json:"type"`
+	Scheme string `json:"scheme,omitempty"`
+	Name   string `json:"name,omitempty"`
+	In     string `json:"in,omitempty"`
+}
+
+// OpenAPISchema is a JSON Schema (draft 2020-12) as used by OpenAPI 3.1.
+// Type is either a string or, for nullable values, a list of strings
+type OpenAPISchema struct {
+	Ref                  string                    `json:"$ref,omitempty"`
+	Type                 interface{}               `json:"type,omitempty"`
+	Format               string                    `json:"format,omitempty"`
+	Pattern              string                    `json:"pattern,omitempty"`
+	Items                *OpenAPISchema            `json:"items,omitempty"`
+	Properties           map[string]*OpenAPISchema `json:"properties,omitempty"`
+	Required             []string                  `json:"required,omitempty"`
+	AdditionalProperties *OpenAPISchema            `json:"additionalProperties,omitempty"`
+	AllOf                []*OpenAPISchema          `json:"allOf,omitempty"`
+	AnyOf                []*OpenAPISchema          `json:"anyOf,omitempty"`
+	Enum                 []interface{}             `json:"enum,omitempty"`
+	Minimum              *float64                  `json:"minimum,omitempty"`
+	Maximum              *float64                  `json:"maximum,omitempty"`
+	MinLength            *int                      `json:"minLength,omitempty"`
+	MaxLength            *int                      `json:"maxLength,omitempty"`
+	MinItems             *int                      `json:"minItems,omitempty"`
+	MaxItems             *int                      `json:"maxItems,omitempty"`
+}
+
+// LoadOpenAPIDocument from JSON file. Path level parameters are copied to
+// the operations of path
+func LoadOpenAPIDocument(path string) (*OpenAPIDocument, error) {
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// path items can hold more than operations, so they are decoded separately
+	document := &OpenAPIDocument{}
+	withRawPaths := struct {
+		*OpenAPIDocument
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}{OpenAPIDocument: document}
+	if err := json.Unmarshal(contents, &withRawPaths); err != nil {
+		return nil, fmt.Errorf("Error parsing OpenAPI document '%v': %v", path, err)
+	}
+
+	document.Paths = make(map[string]map[string]*OpenAPIOperation)
+	for pathTemplate, item := range withRawPaths.Paths {
+		operations := make(map[string]*OpenAPIOperation)
+		var shared []*OpenAPIParameter
+		for key, raw := range item {
+			var err error
+			switch key {
+			case "get", "put", "post", "delete", "options", "head", "patch", "trace":
+				operation := &OpenAPIOperation{}
+				err = json.Unmarshal(raw, operation)
+				operations[key] = operation
+			case "parameters":
+				err = json.Unmarshal(raw, &shared)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("Error parsing '%v %v' in OpenAPI document '%v': %v", key, pathTemplate, path, err)
+			}
+		}
+		for _, operation := range operations {
+			for _, parameter := range shared {
+				if operation.parameter(parameter.In, parameter.Name) == nil {
+					operation.Parameters = append(operation.Parameters, parameter)
+				}
+			}
+		}
+		document.Paths[pathTemplate] = operations
+	}
+
+	return document, nil
+}
+
+func (operation *OpenAPIOperation) parameter(in string, name string) *OpenAPIParameter {
+	for _, parameter := range operation.Parameters {
+		if parameter.In == in && parameter.Name == name {
+			return parameter
+		}
+	}
+	return nil
+}
+
+// ---------------------
+// Document construction
+// ---------------------
+
+// GenerateOpenAPIDocument describes routes that server bootstrapped with
+// same ContextIn would register. Routes are not served
+func GenerateOpenAPIDocument(in *ContextIn) *OpenAPIDocument {
+
+	config := OpenAPIConfiguration{}
+	if in.OpenAPI != nil {
+		config = *in.OpenAPI
+	}
+	if config.Title == "" {
+		config.Title = "API"
+	}
+	if config.Version == "" {
+		config.Version = "1.0.0"
+	}
+	if config.SecuritySchemes == nil {
+		config.SecuritySchemes = DefaultOpenAPISecuritySchemes
+	}
+
+	versioning, agent := registerForOpenAPI(in)
+
+	// versions sharing paths can't be told apart in a single document
+	if config.APIVersion == "" && versioning.config.Strategy != URLPrefixVersioning {
+		config.APIVersion = versioning.config.DefaultVersion
+	}
+
+	generator := newOpenAPIGenerator(config.SecuritySchemes)
+	document := &OpenAPIDocument{
+		OpenAPI: "3.1.0",
+		Info:    OpenAPIInfo{Title: config.Title, Version: config.Version, Description: config.Description},
+		Paths:   make(map[string]map[string]*OpenAPIOperation),
+	}
+	for _, server := range config.Servers {
+		document.Servers = append(document.Servers, OpenAPIServer{URL: server})
+	}
+
+	authRequired := false
+	for _, metadata := range agent.registry.registered {
+		if config.APIVersion != "" && metadata.Version != "" && metadata.Version != config.APIVersion {
+			continue
+		}
+
+		path, parameters := openAPIPath(metadata.Path)
+		operation := generator.operation(metadata, parameters)
+		if version, found := versioning.known[metadata.Version]; found && version.Deprecated {
+			operation.Deprecated = true
+		}
+		authRequired = authRequired || metadata.AuthRequired
+
+		if document.Paths[path] == nil {
+			document.Paths[path] = make(map[string]*OpenAPIOperation)
+		}
+		document.Paths[path][strings.ToLower(metadata.Method)] = operation
+	}
+
+	document.Components.Schemas = generator.schemas
+	if authRequired {
+		document.Components.SecuritySchemes = config.SecuritySchemes
+	}
+
+	return document
+}
+
+// generateOpenAPIDocuments per API version routes were registered under,
+// keyed by version. Key "" holds the document of GenerateOpenAPIDocument
+func generateOpenAPIDocuments(in *ContextIn) map[string]*OpenAPIDocument {
+
+	documents := map[string]*OpenAPIDocument{"": GenerateOpenAPIDocument(in)}
+
+	_, agent := registerForOpenAPI(in)
+	for _, metadata := range agent.registry.registered {
+		if _, found := documents[metadata.Version]; found {
+			continue
+		}
+		config := OpenAPIConfiguration{}
+		if in.OpenAPI != nil {
+			config = *in.OpenAPI
+		}
+		config.APIVersion = metadata.Version
+		versioned := *in
+		versioned.OpenAPI = &config
+		documents[metadata.Version] = GenerateOpenAPIDocument(&versioned)
+	}
+
+	return documents
+}
+
+// serveOpenAPIDocument serialized once at startup
+func serveOpenAPIDocument(document *OpenAPIDocument) func(w http.ResponseWriter, r *http.Request) {
+	serialized, err := json.Marshal(document)
+	if err != nil {
+		panic(err)
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(serialized)
+	}
+}
+
+// --------
+// Internal
+// --------
+
+type openAPIGenerator struct {
+	schemas  map[string]*OpenAPISchema
+	types    map[string]reflect.Type
+	security []map[string][]string
+}
+
+func newOpenAPIGenerator(securitySchemes map[string]*OpenAPISecurityScheme) *openAPIGenerator {
+
+	names := make([]string, 0, len(securitySchemes))
+	for name := range securitySchemes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	security := make([]map[string][]string, len(names))
+	for i, name := range names {
+		security[i] = map[string][]string{name: {}}
+	}
+
+	return &openAPIGenerator{
+		schemas:  make(map[string]*OpenAPISchema),
+		types:    make(map[string]reflect.Type),
+		security: security,
+	}
+}
+
+// registerForOpenAPI registers routes with a throwaway router to collect
+// their metadata
+func registerForOpenAPI(in *ContextIn) (*versioning, *routesAgent) {
+	versioning := newVersioning(in.Versioning, nil)
+	agent := newRoutesAgent(mux.NewRouter(), versioning)
+	for _, r := range in.RoutesToRegister {
+		r.Register(agent)
+	}
+	return versioning, agent
+}
+
+var pathVariable = regexp.MustCompile(`\{([^}:]+)(?::([^}]+))?\}`)
+
+// openAPIPath converts mux path template to OpenAPI path and parameters
+func openAPIPath(template string) (string, []*OpenAPIParameter) {
+	var parameters []*OpenAPIParameter
+	path := pathVariable.ReplaceAllStringFunc(template, func(variable string) string {
+		groups := pathVariable.FindStringSubmatch(variable)
+		schema := &OpenAPISchema{Type: "string"}
+		if groups[2] != "" {
+			// mux patterns match whole path segment
+			schema.Pattern = "^(?:" + groups[2] + ")$"
+		}
+		parameters = append(parameters, &OpenAPIParameter{Name: groups[1], In: "path", Required: true, Schema: schema})
+		return "{" + groups[1] + "}"
+	})
+	return path, parameters
+}
+
+func (generator *openAPIGenerator) operation(metadata *RouteMetadata, parameters []*OpenAPIParameter) *OpenAPIOperation {
+
+	operationID := metadata.Name
+	if operationID == "" {
+		operationID = strings.ToLower(metadata.Method) + strings.NewReplacer("/", "_", "{", "", "}", "").Replace(metadata.Path)
+		if metadata.Version != "" && !strings.HasPrefix(metadata.Path, "/"+metadata.Version) {
+			operationID = metadata.Version + "_" + operationID
+		}
+	}
+
+	for _, parameter := range metadata.Parameters {
+		parameters = append(parameters, &OpenAPIParameter{
+			Name:     parameter.Name,
+			In:       parameter.In,
+			Required: parameter.Required,
+			Schema:   generator.schemaFor(parameter.Example),
+		})
+	}
+
+	operation := &OpenAPIOperation{
+		OperationID: operationID,
+		Summary:     metadata.Summary,
+		Tags:        metadata.Tags,
+		Parameters:  parameters,
+		Responses:   make(map[string]*OpenAPIResponse),
+	}
+
+	if metadata.RequestBody != nil {
+		operation.RequestBody = &OpenAPIRequestBody{
+			Required: true,
+			Content:  jsonContent(generator.schemaFor(metadata.RequestBody)),
+		}
+	}
+
+	for status, body := range metadata.Responses {
+		response := &OpenAPIResponse{Description: http.StatusText(status)}
+		if body != nil {
+			response.Content = jsonContent(generator.schemaFor(body))
+		}
+		operation.Responses[strconv.Itoa(status)] = response
+	}
+	if len(metadata.Responses) == 0 {
+		operation.Responses["200"] = &OpenAPIResponse{Description: http.StatusText(http.StatusOK)}
+	}
+
+	// all errors are reported as ErrorMessage
+	operation.Responses["default"] = &OpenAPIResponse{
+		Description: "Error",
+		Content:     jsonContent(generator.schemaFor(&utils.ErrorMessage{})),
+	}
+
+	if metadata.AuthRequired {
+		operation.Security = generator.security
+	}
+
+	return operation
+}
+
+func jsonContent(schema *OpenAPISchema) map[string]*OpenAPIMediaType {
+	return map[string]*OpenAPIMediaType{"application/json": {Schema: schema}}
+}
+
+// schemaFor value registered with WithRequestBody or WithResponse
+func (generator *openAPIGenerator) schemaFor(value interface{}) *OpenAPISchema {
+	if value == nil {
+		return &OpenAPISchema{}
+	}
+	if paged, ok := value.(pagedResponseOf); ok {
+		return &OpenAPISchema{AllOf: []*OpenAPISchema{
+			generator.schemaOf(reflect.TypeOf(paged.container)),
+			{
+				Type:       "object",
+				Properties: map[string]*OpenAPISchema{"Payload": {Type: "array", Items: generator.schemaFor(paged.item)}},
+			},
+		}}
+	}
+
+	// registered values are usually pointers, which aren't meant as nullable
+	t := reflect.TypeOf(value)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return generator.schemaOf(t)
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	jsonDateType = reflect.TypeOf(utils.JSONDate{})
+)
+
+func (generator *openAPIGenerator) schemaOf(t reflect.Type) *OpenAPISchema {
+
+	// nullable values
+	if t.Kind() == reflect.Ptr {
+		schema := generator.schemaOf(t.Elem())
+		if typeName, ok := schema.Type.(string); ok && typeName != "" {
+			nullable := *schema
+			nullable.Type = []string{typeName, "null"}
+			return &nullable
+		}
+		if schema.Ref != "" {
+			return &OpenAPISchema{AnyOf: []*OpenAPISchema{schema, {Type: "null"}}}
+		}
+		return schema
+	}
+
+	switch t {
+	case timeType:
+		return &OpenAPISchema{Type: "string", Format: "date-time"}
+	case jsonDateType:
+		return &OpenAPISchema{Type: "string", Format: "date"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &OpenAPISchema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return &OpenAPISchema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return &OpenAPISchema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &OpenAPISchema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &OpenAPISchema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &OpenAPISchema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &OpenAPISchema{Type: "string", Format: "byte"}
+		}
+		if t.Kind() == reflect.Array {
+			return &OpenAPISchema{Type: "array", Items: generator.schemaOf(t.Elem())}
+		}
+		return &OpenAPISchema{Type: []string{"array", "null"}, Items: generator.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &OpenAPISchema{Type: []string{"object", "null"}, AdditionalProperties: generator.schemaOf(t.Elem())}
+	case reflect.Struct:
+		return generator.structSchema(t)
+	}
+
+	// interfaces and anything else can be any value
+	return &OpenAPISchema{}
+}
+
+// structSchema of named structs is added to components and referenced
+func (generator *openAPIGenerator) structSchema(t reflect.Type) *OpenAPISchema {
+
+	if t.Name() != "" {
+		name := generator.schemaName(t)
+		ref := &OpenAPISchema{Ref: "#/components/schemas/" + name}
+		if _, found := generator.schemas[name]; found {
+			return ref
+		}
+		// placeholder guards against recursive types
+		generator.schemas[name] = &OpenAPISchema{}
+		*generator.schemas[name] = *generator.objectSchema(t)
+		return ref
+	}
+
+	return generator.objectSchema(t)
+}
+
+var (
+	importPath        = regexp.MustCompile(`[A-Za-z0-9_.\-~]+/`)
+	invalidSchemaName = regexp.MustCompile(`[^A-Za-z0-9._\-]+`)
+)
+
+// schemaName of named type, qualified by its package (e.g. "utils.ErrorMessage").
+// Falls back to full import path if that's already taken by another type.
+// Type arguments of generics are kept with package names only
+func (generator *openAPIGenerator) schemaName(t reflect.Type) string {
+
+	typeName := importPath.ReplaceAllString(t.Name(), "")
+	pkgPath := t.PkgPath()
+	name := pkgPath[strings.LastIndex(pkgPath, "/")+1:] + "." + typeName
+	if existing, found := generator.types[sanitizeSchemaName(name)]; found && existing != t {
+		name = pkgPath + "." + typeName
+	}
+
+	name = sanitizeSchemaName(name)
+	generator.types[name] = t
+	return name
+}
+
+// sanitizeSchemaName to match ^[a-zA-Z0-9._-]+$ as required for component keys
+func sanitizeSchemaName(name string) string {
+	return strings.Trim(invalidSchemaName.ReplaceAllString(name, "_"), "_")
+}
+
+func (generator *openAPIGenerator) objectSchema(t reflect.Type) *OpenAPISchema {
+
+	schema := &OpenAPISchema{Type: "object", Properties: make(map[string]*OpenAPISchema)}
+	generator.addFields(schema, t)
+	sort.Strings(schema.Required)
+	return schema
+}
+
+func (generator *openAPIGenerator) addFields(schema *OpenAPISchema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		// embedded structs are flattened
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			generator.addFields(schema, field.Type)
+			continue
+		}
+		if field.PkgPath != "" || isBoundParameter(field) {
+			continue
+		}
+
+		name := field.Name
+		if tag, found := field.Tag.Lookup("json"); found {
+			options := strings.Split(tag, ",")
+			if options[0] == "-" {
+				continue
+			}
+			if options[0] != "" {
+				name = options[0]
+			}
+		}
+
+		// only fields utils.Validator requires are required, since request
+		// bodies are checked against these schemas
+		schema.Properties[name] = generator.schemaOf(field.Type)
+		required := false
+		if tag := field.Tag.Get("validate"); tag != "" {
+			schema.Properties[name], required = applyValidationTag(schema.Properties[name], tag, required)
+		}
+		if required {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+}
+
+// applyValidationTag describes rules of 'validate' tag (see utils.Validator)
+// as schema constraints
+func applyValidationTag(schema *OpenAPISchema, tag string, required bool) (*OpenAPISchema, bool) {
+
+	constrained := *schema
+	for _, rule := range strings.Split(tag, ",") {
+		name, param := rule, ""
+		if equals := strings.Index(rule, "="); equals >= 0 {
+			name, param = rule[:equals], rule[equals+1:]
+		}
+		limit, _ := strconv.ParseFloat(param, 64)
+		count := int(limit)
+
+		switch {
+		case name == "required":
+			required = true
+		case name == "omitempty":
+			required = false
+		case name == "email":
+			constrained.Format = "email"
+		case name == "oneof":
+			for _, allowed := range strings.Fields(param) {
+				if hasType(schema, "integer") || hasType(schema, "number") {
+					constrained.Enum = append(constrained.Enum, json.Number(allowed))
+				} else {
+					constrained.Enum = append(constrained.Enum, allowed)
+				}
+			}
+		case hasType(schema, "string") && (name == "min" || name == "len"):
+			constrained.MinLength = &count
+		case hasType(schema, "array") && (name == "min" || name == "len"):
+			constrained.MinItems = &count
+		case (hasType(schema, "integer") || hasType(schema, "number")) && name == "min":
+			constrained.Minimum = &limit
+		}
+
+		switch {
+		case hasType(schema, "string") && (name == "max" || name == "len"):
+			constrained.MaxLength = &count
+		case hasType(schema, "array") && (name == "max" || name == "len"):
+			constrained.MaxItems = &count
+		case (hasType(schema, "integer") || hasType(schema, "number")) && name == "max":
+			constrained.Maximum = &limit
+		}
+	}
+
+	return &constrained, required
+}
+
+// isBoundParameter if field is bound from path, query or header by
+// utils.Binder instead of body
+func isBoundParameter(field reflect.StructField) bool {
+	for _, in := range []string{"path", "query", "header"} {
+		if tag := field.Tag.Get(in); tag != "" && tag != "-" {
+			return true
+		}
+	}
+	return false
+}
+
+// pagedResponseOf is registered by WithPagedResponse and
+// WithCursorPagedResponse
+type pagedResponseOf struct {
+	container interface{}
+	item      interface{}
+}
@@ -0,0 +1,233 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	httpUtils "github.com/saharsh-samples/go-mux-sql-starter/http/utils"
+	"github.com/saharsh-samples/go-mux-sql-starter/test"
+	"github.com/saharsh-samples/go-mux-sql-starter/utils"
+)
+
+type documentedThing struct {
+	ID       int64
+	Name     string             `validate:"required"`
+	Born     httpUtils.JSONDate `validate:"required"`
+	Updated  *time.Time
+	Nickname string `json:"nick,omitempty"`
+	secret   string
+
+	httpUtils.AlwaysValidJSON
+}
+
+type documentedRoutes struct{}
+
+func (resource *documentedRoutes) Register(agent RoutesAgent) {
+	agent.Register(http.MethodGet, "/things", SuccessHandler,
+		WithSummary("List things"), WithTags("things"), WithPagedResponse(http.StatusOK, documentedThing{}))
+	agent.Register(http.MethodPost, "/things", SuccessHandler,
+		WithName("createThing"), RequiresAuth(),
+		WithRequestBody(&documentedThing{}), WithResponse(http.StatusCreated, &documentedThing{}))
+	agent.Register(http.MethodDelete, "/things/{id:[0-9]+}", SuccessHandler,
+		WithResponse(http.StatusNoContent, nil))
+	agent.Register(http.MethodGet, "/things/feed", SuccessHandler,
+		WithCursorPagedResponse(http.StatusOK, documentedThing{}))
+}
+
+func TestGenerateOpenAPIDocument(t *testing.T) {
+
+	// act
+	document := GenerateOpenAPIDocument(&ContextIn{
+		RoutesToRegister: []Routes{&documentedRoutes{}},
+		OpenAPI:          &OpenAPIConfiguration{Title: "Things", Servers: []string{"https://api.example.com"}},
+	})
+
+	// assert
+	test.AssertEquals("", "3.1.0", document.OpenAPI, t)
+	test.AssertEquals("", "Things", document.Info.Title, t)
+	test.AssertEquals("", "1.0.0", document.Info.Version, t)
+	test.AssertEquals("", "https://api.example.com", document.Servers[0].URL, t)
+
+	list := document.Paths["/things"]["get"]
+	test.AssertEquals("", "get_things", list.OperationID, t)
+	test.AssertEquals("", "List things", list.Summary, t)
+	paged := list.Responses["200"].Content["application/json"].Schema
+	test.AssertEquals("", "#/components/schemas/utils.PagedResponse", paged.AllOf[0].Ref, t)
+	test.AssertEquals("", "#/components/schemas/http.documentedThing", paged.AllOf[1].Properties["Payload"].Items.Ref, t)
+	test.AssertEquals("", "#/components/schemas/utils.ErrorMessage", list.Responses["default"].Content["application/json"].Schema.Ref, t)
+	test.AssertEquals("", 0, len(list.Security), t)
+
+	feed := document.Paths["/things/feed"]["get"]
+	cursorPaged := feed.Responses["200"].Content["application/json"].Schema
+	test.AssertEquals("", "#/components/schemas/utils.CursorPagedResponse", cursorPaged.AllOf[0].Ref, t)
+	test.AssertEquals("", "cursor", feed.Parameters[0].Name, t)
+
+	create := document.Paths["/things"]["post"]
+	test.AssertEquals("", "createThing", create.OperationID, t)
+	test.AssertEquals("", "#/components/schemas/http.documentedThing", create.RequestBody.Content["application/json"].Schema.Ref, t)
+	test.AssertEquals("", "Created", create.Responses["201"].Description, t)
+	test.AssertEquals("", "[map[apiKeyAuth:[]] map[bearerAuth:[]]]", fmt.Sprint(create.Security), t)
+	test.AssertEquals("", "bearer", document.Components.SecuritySchemes["bearerAuth"].Scheme, t)
+
+	remove := document.Paths["/things/{id}"]["delete"]
+	test.AssertEquals("", "id", remove.Parameters[0].Name, t)
+	test.AssertEquals("", "path", remove.Parameters[0].In, t)
+	test.AssertEquals("", "^(?:[0-9]+)$", remove.Parameters[0].Schema.Pattern, t)
+	test.AssertTrue("Expected no content for 204", remove.Responses["204"].Content == nil, t)
+
+	thing := document.Components.Schemas["http.documentedThing"]
+	test.AssertEquals("", 5, len(thing.Properties), t)
+	test.AssertEquals("", "integer", thing.Properties["ID"].Type, t)
+	test.AssertEquals("", "date", thing.Properties["Born"].Format, t)
+	test.AssertEquals("", "date-time", thing.Properties["Updated"].Format, t)
+	test.AssertEquals("", "[string null]", fmt.Sprint(thing.Properties["Updated"].Type), t)
+	test.AssertEquals("", "[Born Name]", fmt.Sprint(thing.Required), t)
+	test.AssertTrue("Expected json tag to rename field", thing.Properties["nick"] != nil, t)
+}
+
+func TestGenerateOpenAPIDocument_with_versions(t *testing.T) {
+
+	// act
+	in := &ContextIn{
+		RoutesToRegister: []Routes{&VersionedRoutes{Routes: &documentedRoutes{}, Versions: []string{"v1", "v2"}}},
+		Versioning:       &VersioningConfiguration{Versions: []APIVersion{{Name: "v1", Deprecated: true}}},
+	}
+	document := GenerateOpenAPIDocument(in)
+
+	// assert
+	test.AssertTrue("Expected v1 operations to be deprecated", document.Paths["/v1/things"]["get"].Deprecated, t)
+	test.AssertFalse("Expected v2 operations not to be deprecated", document.Paths["/v2/things"]["get"].Deprecated, t)
+
+	// limited to single version
+	in.OpenAPI = &OpenAPIConfiguration{APIVersion: "v2"}
+	document = GenerateOpenAPIDocument(in)
+	test.AssertTrue("Expected v1 operations to be excluded", document.Paths["/v1/things"] == nil, t)
+	test.AssertTrue("Expected v2 operations to be included", document.Paths["/v2/things"] != nil, t)
+}
+
+func TestGenerateOpenAPIDocument_with_shared_version_paths(t *testing.T) {
+
+	// act
+	in := &ContextIn{
+		RoutesToRegister: []Routes{&VersionedRoutes{Routes: &documentedRoutes{}, Versions: []string{"v1", "v2"}}},
+		Versioning:       &VersioningConfiguration{Strategy: HeaderVersioning, DefaultVersion: "v1", Versions: []APIVersion{{Name: "v1", Deprecated: true}}},
+	}
+	document := GenerateOpenAPIDocument(in)
+	documents := generateOpenAPIDocuments(in)
+
+	// assert
+	test.AssertTrue("Expected default version to be documented", document.Paths["/things"]["get"].Deprecated, t)
+	test.AssertTrue("", documents["v1"].Paths["/things"]["get"].Deprecated, t)
+	test.AssertFalse("", documents["v2"].Paths["/things"]["get"].Deprecated, t)
+	test.AssertEquals("", 3, len(documents), t)
+}
+
+func TestGenerateOpenAPIDocument_with_security_schemes(t *testing.T) {
+
+	// act
+	document := GenerateOpenAPIDocument(&ContextIn{
+		RoutesToRegister: []Routes{&documentedRoutes{}},
+		OpenAPI: &OpenAPIConfiguration{SecuritySchemes: map[string]*OpenAPISecurityScheme{
+			"sessionCookie": {Type: "apiKey", Name: "session", In: "cookie"},
+		}},
+	})
+
+	// assert
+	test.AssertEquals("", "[map[sessionCookie:[]]]", fmt.Sprint(document.Paths["/things"]["post"].Security), t)
+	test.AssertEquals("", 1, len(document.Components.SecuritySchemes), t)
+	test.AssertEquals("", "cookie", document.Components.SecuritySchemes["sessionCookie"].In, t)
+}
+
+type page[T any] struct {
+	Items []T
+}
+
+type genericRoutes struct{}
+
+func (resource *genericRoutes) Register(agent RoutesAgent) {
+	agent.Register(http.MethodGet, "/pages", SuccessHandler, WithResponse(http.StatusOK, page[documentedThing]{}))
+	agent.Register(http.MethodGet, "/errors", SuccessHandler, WithResponse(http.StatusOK, ErrorMessage{}))
+}
+
+// ErrorMessage shares its name with utils.ErrorMessage
+type ErrorMessage struct {
+	Code int
+}
+
+func TestGenerateOpenAPIDocument_schema_names(t *testing.T) {
+
+	// act
+	document := GenerateOpenAPIDocument(&ContextIn{RoutesToRegister: []Routes{&genericRoutes{}}})
+
+	// assert
+	pages := document.Paths["/pages"]["get"].Responses["200"].Content["application/json"].Schema
+	test.AssertEquals("", "#/components/schemas/http.page_http.documentedThing", pages.Ref, t)
+	test.AssertTrue("", document.Components.Schemas["http.page_http.documentedThing"].Properties["Items"] != nil, t)
+	errors := document.Paths["/errors"]["get"].Responses["200"].Content["application/json"].Schema
+	test.AssertEquals("", "#/components/schemas/http.ErrorMessage", errors.Ref, t)
+	test.AssertTrue("", document.Components.Schemas["http.ErrorMessage"].Properties["Code"] != nil, t)
+	test.AssertTrue("", document.Components.Schemas["utils.ErrorMessage"].Properties["Message"] != nil, t)
+}
+
+func TestRun_with_openapi(t *testing.T) {
+
+	// arrange
+	server := Bootstrap(&ContextIn{
+		Port:             0,
+		RoutesToRegister: []Routes{&documentedRoutes{}},
+		OpenAPI:          &OpenAPIConfiguration{Title: "Things"},
+	}).Server
+
+	go server.Run()
+	utils.WaitTill(func() bool { return server.IsReady() }, 10)
+	defer server.Shutdown()
+	port, _ := server.Port()
+
+	// act
+	resp, err := http.Get(fmt.Sprintf("http://localhost:%d%s", port, DefaultOpenAPIPath))
+
+	// assert
+	test.AssertTrue("Expected no errors getting document", err == nil, t)
+	defer resp.Body.Close()
+	test.AssertEquals("", 200, resp.StatusCode, t)
+	test.AssertEquals("", "application/json", resp.Header.Get("Content-Type"), t)
+
+	body, _ := io.ReadAll(resp.Body)
+	document := make(map[string]interface{})
+	test.AssertTrue("Expected valid JSON", json.Unmarshal(body, &document) == nil, t)
+	test.AssertEquals("", "3.1.0", document["openapi"], t)
+	test.AssertTrue("Expected /things to be documented", document["paths"].(map[string]interface{})["/things"] != nil, t)
+}
+
+type taggedThing struct {
+	Email string   `validate:"required,email,max=255"`
+	Kind  string   `validate:"omitempty,oneof=big small"`
+	Count int      `validate:"min=1,max=10"`
+	Tags  []string `validate:"required,min=1"`
+}
+
+type taggedRoutes struct{}
+
+func (resource *taggedRoutes) Register(agent RoutesAgent) {
+	agent.Register(http.MethodPost, "/tagged", SuccessHandler, WithRequestBody(&taggedThing{}))
+}
+
+func TestGenerateOpenAPIDocument_with_validation_tags(t *testing.T) {
+
+	// act
+	document := GenerateOpenAPIDocument(&ContextIn{RoutesToRegister: []Routes{&taggedRoutes{}}})
+
+	// assert
+	thing := document.Components.Schemas["http.taggedThing"]
+	test.AssertEquals("", "[Email Tags]", fmt.Sprint(thing.Required), t)
+	test.AssertEquals("", "email", thing.Properties["Email"].Format, t)
+	test.AssertEquals("", 255, *thing.Properties["Email"].MaxLength, t)
+	test.AssertEquals("", "[big small]", fmt.Sprint(thing.Properties["Kind"].Enum), t)
+	test.AssertEquals("", 1.0, *thing.Properties["Count"].Minimum, t)
+	test.AssertEquals("", 10.0, *thing.Properties["Count"].Maximum, t)
+	test.AssertEquals("", 1, *thing.Properties["Tags"].MinItems, t)
+}
@@ -1,9 +1,11 @@
 package http
 
 import (
+	"context"
 	"net/http"
 
 	"github.com/gorilla/mux"
+	"github.com/saharsh-samples/go-mux-sql-starter/http/utils"
 )
 
 // ------
@@ -15,6 +17,133 @@ type Routes interface {
 	Register(agent RoutesAgent)
 }
 
+// --------------
+// Route Metadata
+// --------------
+
+// RouteMetadata describes a registered route
+type RouteMetadata struct {
+	Method       string
+	Path         string
+	Name         string
+	Summary      string
+	Tags         []string
+	AuthRequired bool
+	BodyLimit    int64
+
+	// Version of API route was registered under (see RoutesAgent.Version)
+	Version string
+
+	// RequestBody and Responses are example values whose types describe
+	// route in the OpenAPI document. Responses are keyed by status code
+	RequestBody interface{}
+	Responses   map[int]interface{}
+
+	// Parameters read from query string or headers
+	Parameters []*RouteParameter
+}
+
+// RouteParameter is a query or header parameter of route. Example is a
+// value whose type describes parameter (e.g. 0 or []string{})
+type RouteParameter struct {
+	In       string
+	Name     string
+	Required bool
+	Example  interface{}
+}
+
+// RouteOption sets optional RouteMetadata during registration
+type RouteOption func(*RouteMetadata)
+
+// WithName of route. Named routes can be used to build URLs
+func WithName(name string) RouteOption {
+	return func(metadata *RouteMetadata) { metadata.Name = name }
+}
+
+// WithSummary describing what route does
+func WithSummary(summary string) RouteOption {
+	return func(metadata *RouteMetadata) { metadata.Summary = summary }
+}
+
+// WithTags used to group routes in documentation
+func WithTags(tags ...string) RouteOption {
+	return func(metadata *RouteMetadata) { metadata.Tags = append(metadata.Tags, tags...) }
+}
+
+// RequiresAuth marks route as requiring an authenticated caller
+func RequiresAuth() RouteOption {
+	return func(metadata *RouteMetadata) { metadata.AuthRequired = true }
+}
+
+// WithBodyLimit in bytes for request bodies sent to route
+func WithBodyLimit(bytes int64) RouteOption {
+	return func(metadata *RouteMetadata) { metadata.BodyLimit = bytes }
+}
+
+// WithRequestBody of the type of body (e.g. &CreateUserRequest{})
+func WithRequestBody(body interface{}) RouteOption {
+	return func(metadata *RouteMetadata) { metadata.RequestBody = body }
+}
+
+// WithResponse of the type of body for status code. Body can be nil for
+// responses without content
+func WithResponse(statusCode int, body interface{}) RouteOption {
+	return func(metadata *RouteMetadata) {
+		if metadata.Responses == nil {
+			metadata.Responses = make(map[int]interface{})
+		}
+		metadata.Responses[statusCode] = body
+	}
+}
+
+// WithPagedResponse of utils.PagedResponse whose Payload holds items of
+// the type of item
+func WithPagedResponse(statusCode int, item interface{}) RouteOption {
+	return WithResponse(statusCode, pagedResponseOf{container: utils.PagedResponse{}, item: item})
+}
+
+// WithCursorPagedResponse of utils.CursorPagedResponse whose Payload holds
+// items of the type of item. Also documents 'cursor' query parameter
+func WithCursorPagedResponse(statusCode int, item interface{}) RouteOption {
+	return func(metadata *RouteMetadata) {
+		WithQueryParameter(utils.CursorQueryParameter, "", false)(metadata)
+		WithResponse(statusCode, pagedResponseOf{container: utils.CursorPagedResponse{}, item: item})(metadata)
+	}
+}
+
+// WithQueryParameter read by route. Slice examples describe parameters
+// with repeated values (e.g. ?tag=a&tag=b)
+func WithQueryParameter(name string, example interface{}, required bool) RouteOption {
+	return func(metadata *RouteMetadata) {
+		metadata.Parameters = append(metadata.Parameters, &RouteParameter{In: "query", Name: name, Required: required, Example: example})
+	}
+}
+
+// WithHeaderParameter read by route
+func WithHeaderParameter(name string, example interface{}, required bool) RouteOption {
+	return func(metadata *RouteMetadata) {
+		metadata.Parameters = append(metadata.Parameters, &RouteParameter{In: "header", Name: name, Required: required, Example: example})
+	}
+}
+
+// NewRouteMetadata for method and path with specified options applied
+func NewRouteMetadata(method string, path string, opts ...RouteOption) *RouteMetadata {
+	metadata := &RouteMetadata{Method: method, Path: path}
+	for _, opt := range opts {
+		opt(metadata)
+	}
+	return metadata
+}
+
+type routeMetadataContextKey struct{}
+
+// GetRouteMetadata of route matched for request. Only available to
+// middlewares and handlers running after routing
+func GetRouteMetadata(r *http.Request) (*RouteMetadata, bool) {
+	metadata, ok := r.Context().Value(routeMetadataContextKey{}).(*RouteMetadata)
+	return metadata, ok
+}
+
 // ------------
 // Routes Agent
 // ------------
@@ -25,6 +154,64 @@ type RoutesAgent interface {
 	RegisterPost(path string, f func(w http.ResponseWriter, r *http.Request))
 	RegisterPut(path string, f func(w http.ResponseWriter, r *http.Request))
 	RegisterDelete(path string, f func(w http.ResponseWriter, r *http.Request))
+	RegisterPatch(path string, f func(w http.ResponseWriter, r *http.Request))
+	RegisterHead(path string, f func(w http.ResponseWriter, r *http.Request))
+	RegisterOptions(path string, f func(w http.ResponseWriter, r *http.Request))
+	Register(method string, path string, f func(w http.ResponseWriter, r *http.Request), opts ...RouteOption)
+
+	// RegisterWebSocket route upgrading GET requests to WebSockets managed
+	// by hub and handled by handler
+	RegisterWebSocket(path string, hub WebSocketHub, handler WebSocketHandler, opts ...RouteOption)
+
+	// Group returns a RoutesAgent registering routes under path prefix.
+	// Groups can be nested and have their own middlewares, which run after
+	// the middlewares of enclosing groups and the server
+	Group(prefix string, opts ...GroupOption) RoutesAgent
+
+	// Version returns a RoutesAgent registering routes under specified API
+	// version. How version is selected by requests depends on the server's
+	// VersioningConfiguration
+	Version(name string) RoutesAgent
+}
+
+// ------------
+// Route Groups
+// ------------
+
+// GroupConfiguration of a route group
+type GroupConfiguration struct {
+	Middlewares Middlewares
+	Host        string
+	StrictSlash bool
+}
+
+// GroupOption sets optional GroupConfiguration
+type GroupOption func(*GroupConfiguration)
+
+// WithGroupMiddlewares that only run for routes of group
+func WithGroupMiddlewares(middlewares ...func(http.Handler) http.Handler) GroupOption {
+	return func(config *GroupConfiguration) { config.Middlewares = append(config.Middlewares, middlewares...) }
+}
+
+// WithHost restricts group to requests for specified host. Host can
+// contain variables (e.g. "{subdomain}.example.com")
+func WithHost(host string) GroupOption {
+	return func(config *GroupConfiguration) { config.Host = host }
+}
+
+// WithStrictSlash redirects paths with(out) trailing slash to the
+// registered path. Nested groups inherit this setting
+func WithStrictSlash(strictSlash bool) GroupOption {
+	return func(config *GroupConfiguration) { config.StrictSlash = strictSlash }
+}
+
+// NewGroupConfiguration with specified options applied
+func NewGroupConfiguration(opts ...GroupOption) *GroupConfiguration {
+	config := &GroupConfiguration{}
+	for _, opt := range opts {
+		opt(config)
+	}
+	return config
 }
 
 // --------
@@ -32,21 +219,118 @@ type RoutesAgent interface {
 // --------
 
 type routesAgent struct {
-	router *mux.Router
+	router     *mux.Router
+	prefix     string
+	version    string
+	versioning *versioning
+	registry   *routeRegistry
+}
+
+// routeRegistry holds metadata of all registered routes. Shared by groups
+type routeRegistry struct {
+	registered []*RouteMetadata
+	byRoute    map[*mux.Route]*RouteMetadata
+}
+
+func newRoutesAgent(router *mux.Router, versioning *versioning) *routesAgent {
+	return &routesAgent{
+		router:     router,
+		versioning: versioning,
+		registry:   &routeRegistry{byRoute: make(map[*mux.Route]*RouteMetadata)},
+	}
+}
+
+func (agent *routesAgent) Register(method string, path string, f func(w http.ResponseWriter, r *http.Request), opts ...RouteOption) {
+	metadata := NewRouteMetadata(method, agent.prefix+path, opts...)
+	metadata.Version = agent.version
+	route := agent.router.HandleFunc(path, f).Methods(method)
+	if metadata.Name != "" {
+		route.Name(metadata.Name)
+	}
+	agent.registry.registered = append(agent.registry.registered, metadata)
+	agent.registry.byRoute[route] = metadata
+}
+
+func (agent *routesAgent) Group(prefix string, opts ...GroupOption) RoutesAgent {
+
+	config := NewGroupConfiguration(opts...)
+
+	route := agent.router.NewRoute()
+	if config.Host != "" {
+		route = route.Host(config.Host)
+	}
+	if prefix != "" {
+		route = route.PathPrefix(prefix)
+	}
+
+	// strict slash is inherited from enclosing router unless set
+	subrouter := route.Subrouter()
+	if config.StrictSlash {
+		subrouter.StrictSlash(true)
+	}
+	for _, middleware := range config.Middlewares {
+		subrouter.Use(middleware)
+	}
+
+	return agent.derive(subrouter, agent.prefix+prefix, agent.version)
+}
+
+func (agent *routesAgent) Version(name string) RoutesAgent {
+	subrouter, prefix := agent.versioning.group(agent.router, name)
+	return agent.derive(subrouter, agent.prefix+prefix, name)
+}
+
+func (agent *routesAgent) derive(router *mux.Router, prefix string, version string) *routesAgent {
+	return &routesAgent{
+		router:     router,
+		prefix:     prefix,
+		version:    version,
+		versioning: agent.versioning,
+		registry:   agent.registry,
+	}
+}
+
+func (agent *routesAgent) RegisterWebSocket(path string, hub WebSocketHub, handler WebSocketHandler, opts ...RouteOption) {
+	agent.Register(http.MethodGet, path, hub.Handler(handler), opts...)
 }
 
 func (agent *routesAgent) RegisterGet(path string, f func(w http.ResponseWriter, r *http.Request)) {
-	agent.router.HandleFunc(path, f).Methods("GET")
+	agent.Register(http.MethodGet, path, f)
 }
 
 func (agent *routesAgent) RegisterPost(path string, f func(w http.ResponseWriter, r *http.Request)) {
-	agent.router.HandleFunc(path, f).Methods("POST")
+	agent.Register(http.MethodPost, path, f)
 }
 
 func (agent *routesAgent) RegisterPut(path string, f func(w http.ResponseWriter, r *http.Request)) {
-	agent.router.HandleFunc(path, f).Methods("PUT")
+	agent.Register(http.MethodPut, path, f)
 }
 
 func (agent *routesAgent) RegisterDelete(path string, f func(w http.ResponseWriter, r *http.Request)) {
-	agent.router.HandleFunc(path, f).Methods("DELETE")
+	agent.Register(http.MethodDelete, path, f)
+}
+
+func (agent *routesAgent) RegisterPatch(path string, f func(w http.ResponseWriter, r *http.Request)) {
+	agent.Register(http.MethodPatch, path, f)
+}
+
+func (agent *routesAgent) RegisterHead(path string, f func(w http.ResponseWriter, r *http.Request)) {
+	agent.Register(http.MethodHead, path, f)
+}
+
+func (agent *routesAgent) RegisterOptions(path string, f func(w http.ResponseWriter, r *http.Request)) {
+	agent.Register(http.MethodOptions, path, f)
+}
+
+// attachRouteMetadata makes metadata of matched route available via
+// GetRouteMetadata
+func (agent *routesAgent) attachRouteMetadata(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if route := mux.CurrentRoute(r); route != nil {
+			if metadata, found := agent.registry.byRoute[route]; found {
+				r = r.WithContext(context.WithValue(r.Context(), routeMetadataContextKey{}, metadata))
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
 }
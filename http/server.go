@@ -5,10 +5,9 @@ import (
 	"fmt"
 	"net"
 	"net/http"
-	"time"
 
-	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
+	"github.com/saharsh-samples/go-mux-sql-starter/http/utils"
 )
 
 // Server encapsulates all HTTP exposed functionality of app
@@ -24,6 +23,19 @@ type server struct {
 	routes      []Routes
 	middlewares []mux.MiddlewareFunc
 	tlsConfig   *TLSConfiguration
+	cors        func(http.Handler) http.Handler
+	versioning  *versioning
+
+	timeouts            ServerTimeouts
+	maxHeaderBytes      int
+	maxRequestBodyBytes int64
+	jsonUtils           utils.JSONUtils
+	auditContext        func(http.Handler) http.Handler
+	openAPIDocument     *OpenAPIDocument
+	openAPIPath         string
+	requestValidator    *requestValidator
+	eventBrokers        []EventBroker
+	webSocketHubs       []WebSocketHub
 
 	listener   net.Listener
 	httpServer *http.Server
@@ -37,16 +49,25 @@ func (server *server) Run() {
 	router := mux.NewRouter()
 
 	// init and register all routes
-	routesAgent := &routesAgent{router}
+	routesAgent := newRoutesAgent(router, server.versioning)
 	for _, r := range server.routes {
 		r.Register(routesAgent)
 	}
+	if server.openAPIDocument != nil {
+		router.HandleFunc(server.openAPIPath, serveOpenAPIDocument(server.openAPIDocument)).Methods(http.MethodGet)
+	}
 
 	// register all middlewares
+	router.Use(routesAgent.attachRouteMetadata, server.limitRequestBody, bearerFromSubprotocol)
 	if len(server.middlewares) > 0 {
 		router.Use(server.middlewares...)
 	}
 
+	// validation runs last so that e.g. authentication failures take precedence
+	if server.requestValidator != nil {
+		router.Use(server.requestValidator.validateRequest)
+	}
+
 	// Create a listener for port
 	var err error
 	server.listener, err = net.Listen("tcp", fmt.Sprintf(":%v", server.port))
@@ -56,30 +77,21 @@ func (server *server) Run() {
 
 	// Create server
 	httpServer := &http.Server{
-		ReadTimeout:  5 * time.Second,
-		WriteTimeout: 10 * time.Second,
-		Handler: handlers.CORS(
-			handlers.AllowedOrigins([]string{"*"}),
-			handlers.AllowedMethods([]string{"POST", "GET", "OPTIONS", "PUT", "DELETE"}),
-			handlers.AllowedHeaders([]string{
-				"Accept",
-				"Accept-Encoding",
-				"Access-Control-Request-Headers",
-				"Access-Control-Request-Method",
-				"Authorization",
-				"Cache-Control",
-				"Client-Version",
-				"Connection",
-				"Content-Length",
-				"Content-Type",
-				"Host",
-				"Origin",
-				"Referer",
-				"User-Agent",
-				"X-CSRF-Token",
-				"X-header",
-			}),
-		)(router),
+		ReadTimeout:       server.timeouts.ReadTimeout,
+		ReadHeaderTimeout: server.timeouts.ReadHeaderTimeout,
+		WriteTimeout:      server.timeouts.WriteTimeout,
+		IdleTimeout:       server.timeouts.IdleTimeout,
+		MaxHeaderBytes:    server.maxHeaderBytes,
+		Handler:           server.cors(utils.ErrorContext(server.auditContext(server.versioning.rejectUnknownVersions(router)))),
+	}
+
+	// open event streams would otherwise hold up shutdown, and hijacked
+	// WebSocket connections aren't closed by it
+	for _, broker := range server.eventBrokers {
+		httpServer.RegisterOnShutdown(broker.Close)
+	}
+	for _, hub := range server.webSocketHubs {
+		httpServer.RegisterOnShutdown(hub.Close)
 	}
 
 	// listen for requests till app termination
@@ -111,10 +123,37 @@ func (server *server) Shutdown() error {
 		return fmt.Errorf("Server is not running")
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), server.timeouts.ShutdownGracePeriod)
 	defer cancel()
 	err := server.httpServer.Shutdown(ctx)
 	server.httpServer = nil
 	return err
 
 }
+
+// limitRequestBody rejects requests with bodies larger than configured limit
+// for matched route
+func (server *server) limitRequestBody(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		limit := server.maxRequestBodyBytes
+		if metadata, found := GetRouteMetadata(r); found && metadata.BodyLimit > 0 {
+			limit = metadata.BodyLimit
+		}
+
+		if limit <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// reject early if declared length is already too large, otherwise
+		// enforce limit while handler reads body
+		if r.ContentLength > limit {
+			server.jsonUtils.RequestEntityTooLarge(w, fmt.Sprintf("Request body must not exceed %d bytes", limit))
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -1,10 +1,15 @@
 package http
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"strings"
 	"testing"
+	"time"
 
+	httpUtils "github.com/saharsh-samples/go-mux-sql-starter/http/utils"
 	"github.com/saharsh-samples/go-mux-sql-starter/test"
 	"github.com/saharsh-samples/go-mux-sql-starter/utils"
 )
@@ -145,3 +150,203 @@ func (resource *route2) Register(agent RoutesAgent) {
 func SuccessHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
+
+func TestServerTimeouts_withDefaults(t *testing.T) {
+
+	timeouts := ServerTimeouts{}.withDefaults()
+	test.AssertEquals("", DefaultReadTimeout, timeouts.ReadTimeout, t)
+	test.AssertEquals("", DefaultWriteTimeout, timeouts.WriteTimeout, t)
+	test.AssertEquals("", DefaultShutdownGracePeriod, timeouts.ShutdownGracePeriod, t)
+	test.AssertEquals("", time.Duration(0), timeouts.IdleTimeout, t)
+
+	timeouts = ServerTimeouts{WriteTimeout: time.Minute, IdleTimeout: time.Hour}.withDefaults()
+	test.AssertEquals("", DefaultReadTimeout, timeouts.ReadTimeout, t)
+	test.AssertEquals("", time.Minute, timeouts.WriteTimeout, t)
+	test.AssertEquals("", time.Hour, timeouts.IdleTimeout, t)
+}
+
+func TestRun_with_body_limits(t *testing.T) {
+
+	// arrange
+	server := Bootstrap(&ContextIn{
+		Port:                0,
+		RoutesToRegister:    []Routes{&uploadRoute{}},
+		MaxRequestBodyBytes: 16,
+		Timeouts:            ServerTimeouts{ShutdownGracePeriod: time.Second},
+	}).Server
+
+	go server.Run()
+	utils.WaitTill(func() bool { return server.IsReady() }, 10)
+	defer server.Shutdown()
+	port, _ := server.Port()
+
+	post := func(path string, body io.Reader) (int, *httpUtils.ErrorMessage) {
+		resp, err := http.Post(fmt.Sprintf("http://localhost:%d%s", port, path), "application/json", body)
+		test.AssertTrue("Expected no errors doing POST", err == nil, t)
+		defer resp.Body.Close()
+		errorMessage := &httpUtils.ErrorMessage{}
+		json.NewDecoder(resp.Body).Decode(errorMessage)
+		return resp.StatusCode, errorMessage
+	}
+	body := func(size int) string { return `{"Data":"` + strings.Repeat("x", size-11) + `"}` }
+
+	// act and assert
+
+	// default limit
+	status, _ := post("/uploads", strings.NewReader(body(16)))
+	test.AssertEquals("", 200, status, t)
+	status, errorMessage := post("/uploads", strings.NewReader(body(17)))
+	test.AssertEquals("", 413, status, t)
+	test.AssertEquals("", "Request body must not exceed 16 bytes", errorMessage.Detail, t)
+
+	// route limit
+	status, _ = post("/uploads/1", strings.NewReader(body(64)))
+	test.AssertEquals("", 200, status, t)
+	status, _ = post("/uploads/1", strings.NewReader(body(65)))
+	test.AssertEquals("", 413, status, t)
+
+	// body without declared length is limited while being read
+	status, errorMessage = post("/uploads/1", io.MultiReader(strings.NewReader(body(65))))
+	test.AssertEquals("", 413, status, t)
+	test.AssertEquals("", "Request Entity Too Large", errorMessage.Message, t)
+}
+
+type uploadRoute struct{}
+
+func (resource *uploadRoute) Register(agent RoutesAgent) {
+	agent.RegisterPost("/uploads", resource.Post)
+	agent.Register(http.MethodPost, "/uploads/{id}", resource.Post, WithBodyLimit(64))
+}
+
+type upload struct {
+	Data string
+	httpUtils.AlwaysValidJSON
+}
+
+func (resource *uploadRoute) Post(w http.ResponseWriter, r *http.Request) {
+	jsonUtils := httpUtils.Bootstrap(&httpUtils.ContextIn{}).JSONUtils
+	if jsonUtils.ParseJSONRequest(r, &upload{}, w) == nil {
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func TestRun_with_route_metadata(t *testing.T) {
+
+	// arrange
+	var seen *RouteMetadata
+	middlewares := Middlewares{func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			seen, _ = GetRouteMetadata(r)
+			h.ServeHTTP(w, r)
+		})
+	}}
+
+	server := Bootstrap(&ContextIn{
+		Port:                  0,
+		RoutesToRegister:      []Routes{&route3{}},
+		MiddlewaresToRegister: middlewares,
+	}).Server
+
+	go server.Run()
+	utils.WaitTill(func() bool { return server.IsReady() }, 10)
+	defer server.Shutdown()
+	port, _ := server.Port()
+
+	do := func(method string, body string) int {
+		request, _ := http.NewRequest(method, fmt.Sprintf("http://localhost:%d/route3", port), strings.NewReader(body))
+		resp, err := http.DefaultClient.Do(request)
+		test.AssertTrue("Expected no errors doing "+method+" /route3", err == nil, t)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// act and assert
+	test.AssertEquals("", 200, do(http.MethodHead, ""), t)
+	test.AssertEquals("", http.MethodHead, seen.Method, t)
+
+	test.AssertEquals("", 200, do(http.MethodOptions, ""), t)
+	test.AssertEquals("Expected registered OPTIONS handler to run", http.MethodOptions, seen.Method, t)
+
+	test.AssertEquals("", 200, do(http.MethodPatch, `{"Data":"ok"}`), t)
+	test.AssertEquals("", "patch-route3", seen.Name, t)
+	test.AssertEquals("", "/route3", seen.Path, t)
+	test.AssertTrue("Expected auth requirement", seen.AuthRequired, t)
+
+	test.AssertEquals("", 413, do(http.MethodPatch, `{"Data":"too long for limit"}`), t)
+}
+
+type route3 struct{}
+
+func (resource *route3) Register(agent RoutesAgent) {
+	agent.RegisterHead("/route3", SuccessHandler)
+	agent.RegisterOptions("/route3", SuccessHandler)
+	agent.Register(http.MethodPatch, "/route3", (&uploadRoute{}).Post,
+		WithName("patch-route3"),
+		RequiresAuth(),
+		WithBodyLimit(16),
+	)
+}
+
+func TestRun_with_route_groups(t *testing.T) {
+
+	// arrange
+	var calls []string
+	tracer := func(name string) func(http.Handler) http.Handler {
+		return func(h http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls = append(calls, name)
+				h.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	server := Bootstrap(&ContextIn{
+		Port:                  0,
+		RoutesToRegister:      []Routes{&groupedRoutes{tracer: tracer}},
+		MiddlewaresToRegister: Middlewares{tracer("server")},
+	}).Server
+
+	go server.Run()
+	utils.WaitTill(func() bool { return server.IsReady() }, 10)
+	defer server.Shutdown()
+	port, _ := server.Port()
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	get := func(host string, path string) int {
+		calls = nil
+		request, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("http://localhost:%d%s", port, path), nil)
+		if host != "" {
+			request.Host = host
+		}
+		resp, err := client.Do(request)
+		test.AssertTrue("Expected no errors doing GET "+path, err == nil, t)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// act and assert
+
+	// public group
+	test.AssertEquals("", 200, get("", "/api/v1/things"), t)
+	test.AssertEquals("", "server,api,v1", strings.Join(calls, ","), t)
+
+	// strict slash redirects
+	test.AssertEquals("", 301, get("", "/api/v1/things/"), t)
+
+	// admin group only matches admin host
+	test.AssertEquals("", 404, get("", "/admin/things"), t)
+	test.AssertEquals("", 200, get("admin.example.com", "/admin/things"), t)
+	test.AssertEquals("", "server,admin", strings.Join(calls, ","), t)
+}
+
+type groupedRoutes struct {
+	tracer func(string) func(http.Handler) http.Handler
+}
+
+func (resource *groupedRoutes) Register(agent RoutesAgent) {
+	api := agent.Group("/api", WithGroupMiddlewares(resource.tracer("api")), WithStrictSlash(true))
+	api.Group("/v1", WithGroupMiddlewares(resource.tracer("v1"))).RegisterGet("/things", SuccessHandler)
+
+	admin := agent.Group("/admin", WithHost("admin.example.com"), WithGroupMiddlewares(resource.tracer("admin")))
+	admin.RegisterGet("/things", SuccessHandler)
+}
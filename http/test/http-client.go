@@ -6,6 +6,7 @@ import (
 	"io"
 	"net/http"
 
+	"github.com/gorilla/websocket"
 	"github.com/saharsh-samples/go-mux-sql-starter/test"
 )
 
@@ -81,3 +82,24 @@ func doRequest(t test.T, url string, httpMethod string, reqBody interface{}, res
 
 	return response.StatusCode
 }
+
+// DialWebSocket url (e.g. "ws://localhost:8080/chat") authenticating with
+// same bearer token as the other helpers. Returns nil if dialing fails
+func DialWebSocket(t test.T, url string, apiToken string) *websocket.Conn {
+
+	header := http.Header{}
+	if apiToken != "" {
+		header.Add("Authorization", "Bearer "+apiToken)
+	}
+
+	conn, response, dialErr := websocket.DefaultDialer.Dial(url, header)
+	if dialErr != nil {
+		status := 0
+		if response != nil {
+			status = response.StatusCode
+		}
+		t.Errorf("Error dialing WebSocket '%v' (status %v): %v", url, status, dialErr)
+		return nil
+	}
+	return conn
+}
@@ -7,6 +7,8 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/gorilla/websocket"
+
 	base "github.com/saharsh-samples/go-mux-sql-starter/http"
 	httpUtils "github.com/saharsh-samples/go-mux-sql-starter/http/utils"
 	"github.com/saharsh-samples/go-mux-sql-starter/test"
@@ -191,6 +193,41 @@ func TestDeleteWithoutBody(t *testing.T) {
 	test.AssertEquals("", http.StatusOK, DeleteWithoutBody(t, url, ""), t)
 }
 
+type echoRoute struct {
+	hub base.WebSocketHub
+}
+
+func (resource *echoRoute) Register(agent base.RoutesAgent) {
+	agent.RegisterWebSocket("/echo", resource.hub, func(conn base.WebSocketConnection) {
+		if conn.Request().Header.Get("Authorization") != "Bearer api-token" {
+			conn.Close(websocket.ClosePolicyViolation, "Unauthorized")
+			return
+		}
+		messageType, message, err := conn.Receive()
+		if err == nil {
+			conn.Send(messageType, message)
+		}
+	})
+}
+
+func TestDialWebSocket(t *testing.T) {
+
+	// Arrange
+	serverPort, appCtx := StartTestServer([]base.Routes{&echoRoute{hub: base.NewWebSocketHub(nil)}}, t)
+	defer StopTestServer(appCtx, t)
+	url := fmt.Sprintf("ws://localhost:%d/echo", serverPort)
+
+	// Act
+	conn := DialWebSocket(t, url, "api-token")
+	defer conn.Close()
+	conn.WriteMessage(websocket.TextMessage, []byte("Ping"))
+	_, message, err := conn.ReadMessage()
+
+	// Assert
+	test.AssertTrue("Expected echo", err == nil, t)
+	test.AssertEquals("", "Ping", string(message), t)
+}
+
 // Error testing
 
 type dummyT struct {
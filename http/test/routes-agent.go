@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"reflect"
 	"runtime"
+	"strings"
 
 	base "github.com/saharsh-samples/go-mux-sql-starter/http"
 	"github.com/saharsh-samples/go-mux-sql-starter/test"
@@ -16,7 +17,12 @@ import (
 // NewMockRoutesAgent with test friendly features
 func NewMockRoutesAgent() MockRoutesAgent {
 	return &mockRoutesAgent{
-		httpHandlers: make(map[string]string),
+		registrations: &mockRegistrations{
+			httpHandlers: make(map[string]string),
+			handlerFuncs: make(map[string]func(http.ResponseWriter, *http.Request)),
+			metadata:     make(map[string]*base.RouteMetadata),
+			groups:       make(map[string]*base.GroupConfiguration),
+		},
 	}
 }
 
@@ -28,6 +34,12 @@ type MockRoutesAgent interface {
 	base.RoutesAgent
 	OverrideHandlerFuncRegistration(HandlerFuncRegistrationOverride)
 	VerifyThatRoute(t test.T, url string) RouteVerifierFactory
+	VerifyThatVersionedRoute(t test.T, version string, url string) RouteVerifierFactory
+	VerifyThatGroup(t test.T, prefix string) GroupVerifier
+
+	// GetHandlerFunc registered for method and url, so it can be invoked
+	// directly (e.g. handlers created by adapters). Returns nil if none
+	GetHandlerFunc(method string, url string) func(http.ResponseWriter, *http.Request)
 }
 
 // RouteVerifierFactory creates RouteVerifier instances
@@ -38,6 +50,20 @@ type RouteVerifierFactory interface {
 // RouteVerifier is used to verify proper configuration of HTTP routes
 type RouteVerifier interface {
 	UsesHandler(interface{}) RouteVerifier
+	HasName(string) RouteVerifier
+	HasSummary(string) RouteVerifier
+	HasTags(...string) RouteVerifier
+	RequiresAuth(bool) RouteVerifier
+	HasBodyLimit(int64) RouteVerifier
+}
+
+// GroupVerifier is used to verify proper configuration of route groups.
+// Groups are identified by their full path prefix
+type GroupVerifier interface {
+	Exists() GroupVerifier
+	HasHost(string) GroupVerifier
+	UsesStrictSlash(bool) GroupVerifier
+	HasMiddlewareCount(int) GroupVerifier
 }
 
 // StringifyHandlerFunc for comparisons in testing
@@ -50,74 +76,217 @@ func StringifyHandlerFunc(handlerFunc interface{}) string {
 // ---
 
 type mockRoutesAgent struct {
+	prefix        string
+	version       string
+	registrations *mockRegistrations
+}
+
+// mockRegistrations are shared by an agent and its groups
+type mockRegistrations struct {
 	httpHandlers                    map[string]string
+	handlerFuncs                    map[string]func(http.ResponseWriter, *http.Request)
+	metadata                        map[string]*base.RouteMetadata
+	groups                          map[string]*base.GroupConfiguration
 	overrideHandlerFuncRegistration HandlerFuncRegistrationOverride
 }
 
-func (agent *mockRoutesAgent) register(method string, path string, f func(w http.ResponseWriter, r *http.Request)) {
+func (agent *mockRoutesAgent) Register(method string, path string, f func(w http.ResponseWriter, r *http.Request), opts ...base.RouteOption) {
+	path = agent.prefix + path
 	var handlerFunc interface{} = f
-	if agent.overrideHandlerFuncRegistration != nil {
-		override := agent.overrideHandlerFuncRegistration(method, path, f)
+	if agent.registrations.overrideHandlerFuncRegistration != nil {
+		override := agent.registrations.overrideHandlerFuncRegistration(method, path, f)
 		if override != nil {
 			handlerFunc = override
 		}
 	}
-	agent.httpHandlers[method+":"+path] = StringifyHandlerFunc(handlerFunc)
+	metadata := base.NewRouteMetadata(method, path, opts...)
+	metadata.Version = agent.version
+	agent.registrations.httpHandlers[registrationKey(agent.version, method, path)] = StringifyHandlerFunc(handlerFunc)
+	agent.registrations.handlerFuncs[registrationKey(agent.version, method, path)] = f
+	agent.registrations.metadata[registrationKey(agent.version, method, path)] = metadata
+}
+
+func (agent *mockRoutesAgent) Group(prefix string, opts ...base.GroupOption) base.RoutesAgent {
+	prefix = agent.prefix + prefix
+	agent.registrations.groups[prefix] = base.NewGroupConfiguration(opts...)
+	return &mockRoutesAgent{prefix: prefix, version: agent.version, registrations: agent.registrations}
+}
+
+// Version routes are registered without a path prefix, regardless of
+// strategy, and verified with VerifyThatVersionedRoute
+func (agent *mockRoutesAgent) Version(name string) base.RoutesAgent {
+	return &mockRoutesAgent{prefix: agent.prefix, version: name, registrations: agent.registrations}
+}
+
+func (agent *mockRoutesAgent) RegisterWebSocket(path string, hub base.WebSocketHub, handler base.WebSocketHandler, opts ...base.RouteOption) {
+	agent.Register(http.MethodGet, path, hub.Handler(handler), opts...)
+	// verifiable by handler rather than by the hub's upgrade handler
+	agent.registrations.httpHandlers[registrationKey(agent.version, http.MethodGet, agent.prefix+path)] = StringifyHandlerFunc(handler)
 }
 
 func (agent *mockRoutesAgent) RegisterGet(path string, f func(w http.ResponseWriter, r *http.Request)) {
-	agent.register(http.MethodGet, path, f)
+	agent.Register(http.MethodGet, path, f)
 }
 
 func (agent *mockRoutesAgent) RegisterPost(path string, f func(w http.ResponseWriter, r *http.Request)) {
-	agent.register(http.MethodPost, path, f)
+	agent.Register(http.MethodPost, path, f)
 }
 
 func (agent *mockRoutesAgent) RegisterPut(path string, f func(w http.ResponseWriter, r *http.Request)) {
-	agent.register(http.MethodPut, path, f)
+	agent.Register(http.MethodPut, path, f)
 }
 
 func (agent *mockRoutesAgent) RegisterDelete(path string, f func(w http.ResponseWriter, r *http.Request)) {
-	agent.register(http.MethodDelete, path, f)
+	agent.Register(http.MethodDelete, path, f)
+}
+
+func (agent *mockRoutesAgent) RegisterPatch(path string, f func(w http.ResponseWriter, r *http.Request)) {
+	agent.Register(http.MethodPatch, path, f)
+}
+
+func (agent *mockRoutesAgent) RegisterHead(path string, f func(w http.ResponseWriter, r *http.Request)) {
+	agent.Register(http.MethodHead, path, f)
+}
+
+func (agent *mockRoutesAgent) RegisterOptions(path string, f func(w http.ResponseWriter, r *http.Request)) {
+	agent.Register(http.MethodOptions, path, f)
 }
 
 func (agent *mockRoutesAgent) OverrideHandlerFuncRegistration(override HandlerFuncRegistrationOverride) {
-	agent.overrideHandlerFuncRegistration = override
+	agent.registrations.overrideHandlerFuncRegistration = override
 }
 
 func (agent *mockRoutesAgent) VerifyThatRoute(t test.T, url string) RouteVerifierFactory {
 	return &routeVerifierFactory{t: t, agent: agent, url: url}
 }
 
-func (agent *mockRoutesAgent) getHandler(method string, path string) (string, bool) {
-	handler, found := agent.httpHandlers[method+":"+path]
+func (agent *mockRoutesAgent) VerifyThatVersionedRoute(t test.T, version string, url string) RouteVerifierFactory {
+	return &routeVerifierFactory{t: t, agent: agent, version: version, url: url}
+}
+
+func (agent *mockRoutesAgent) VerifyThatGroup(t test.T, prefix string) GroupVerifier {
+	return &groupVerifier{t: t, agent: agent, prefix: prefix}
+}
+
+func (agent *mockRoutesAgent) GetHandlerFunc(method string, url string) func(http.ResponseWriter, *http.Request) {
+	return agent.registrations.handlerFuncs[registrationKey(agent.version, method, url)]
+}
+
+func (agent *mockRoutesAgent) getHandler(version string, method string, path string) (string, bool) {
+	handler, found := agent.registrations.httpHandlers[registrationKey(version, method, path)]
 	return handler, found
 }
 
+func (agent *mockRoutesAgent) getMetadata(version string, method string, path string) *base.RouteMetadata {
+	metadata, found := agent.registrations.metadata[registrationKey(version, method, path)]
+	if !found {
+		return &base.RouteMetadata{}
+	}
+	return metadata
+}
+
+func registrationKey(version string, method string, path string) string {
+	if version == "" {
+		return method + ":" + path
+	}
+	return version + " " + method + ":" + path
+}
+
 // ---
 // RouteVerifier impl
 // ---
 
 type routeVerifierFactory struct {
-	t     test.T
-	agent *mockRoutesAgent
-	url   string
+	t       test.T
+	agent   *mockRoutesAgent
+	version string
+	url     string
 }
 
 func (factory *routeVerifierFactory) ForHTTPMethod(method string) RouteVerifier {
-	return &routeVerifier{t: factory.t, agent: factory.agent, url: factory.url, method: method}
+	return &routeVerifier{t: factory.t, agent: factory.agent, version: factory.version, url: factory.url, method: method}
 }
 
 type routeVerifier struct {
-	t      test.T
-	agent  *mockRoutesAgent
-	url    string
-	method string
+	t       test.T
+	agent   *mockRoutesAgent
+	version string
+	url     string
+	method  string
 }
 
 func (v *routeVerifier) UsesHandler(handler interface{}) RouteVerifier {
 	expectedHandler := StringifyHandlerFunc(handler)
-	actualHandler, _ := v.agent.getHandler(v.method, v.url)
+	actualHandler, _ := v.agent.getHandler(v.version, v.method, v.url)
 	test.AssertEquals("Expected "+expectedHandler+" to be handler function for "+v.method+" "+v.url, expectedHandler, actualHandler, v.t)
 	return v
 }
+
+func (v *routeVerifier) HasName(name string) RouteVerifier {
+	actual := v.agent.getMetadata(v.version, v.method, v.url).Name
+	test.AssertEquals("Expected name of "+v.method+" "+v.url, name, actual, v.t)
+	return v
+}
+
+func (v *routeVerifier) HasSummary(summary string) RouteVerifier {
+	actual := v.agent.getMetadata(v.version, v.method, v.url).Summary
+	test.AssertEquals("Expected summary of "+v.method+" "+v.url, summary, actual, v.t)
+	return v
+}
+
+func (v *routeVerifier) HasTags(tags ...string) RouteVerifier {
+	actual := v.agent.getMetadata(v.version, v.method, v.url).Tags
+	test.AssertEquals("Expected tags of "+v.method+" "+v.url, strings.Join(tags, ","), strings.Join(actual, ","), v.t)
+	return v
+}
+
+func (v *routeVerifier) RequiresAuth(required bool) RouteVerifier {
+	actual := v.agent.getMetadata(v.version, v.method, v.url).AuthRequired
+	test.AssertEquals("Expected auth requirement of "+v.method+" "+v.url, required, actual, v.t)
+	return v
+}
+
+func (v *routeVerifier) HasBodyLimit(bytes int64) RouteVerifier {
+	actual := v.agent.getMetadata(v.version, v.method, v.url).BodyLimit
+	test.AssertEquals("Expected body limit of "+v.method+" "+v.url, bytes, actual, v.t)
+	return v
+}
+
+// ---
+// GroupVerifier impl
+// ---
+
+type groupVerifier struct {
+	t      test.T
+	agent  *mockRoutesAgent
+	prefix string
+}
+
+func (v *groupVerifier) getConfiguration() *base.GroupConfiguration {
+	config, found := v.agent.registrations.groups[v.prefix]
+	if !found {
+		return &base.GroupConfiguration{}
+	}
+	return config
+}
+
+func (v *groupVerifier) Exists() GroupVerifier {
+	_, found := v.agent.registrations.groups[v.prefix]
+	test.AssertTrue("Expected group "+v.prefix+" to exist", found, v.t)
+	return v
+}
+
+func (v *groupVerifier) HasHost(host string) GroupVerifier {
+	test.AssertEquals("Expected host of group "+v.prefix, host, v.getConfiguration().Host, v.t)
+	return v
+}
+
+func (v *groupVerifier) UsesStrictSlash(strictSlash bool) GroupVerifier {
+	test.AssertEquals("Expected strict slash of group "+v.prefix, strictSlash, v.getConfiguration().StrictSlash, v.t)
+	return v
+}
+
+func (v *groupVerifier) HasMiddlewareCount(count int) GroupVerifier {
+	test.AssertEquals("Expected middleware count of group "+v.prefix, count, len(v.getConfiguration().Middlewares), v.t)
+	return v
+}
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	base "github.com/saharsh-samples/go-mux-sql-starter/http"
+	"github.com/saharsh-samples/go-mux-sql-starter/test"
 )
 
 type route struct{}
@@ -15,12 +16,24 @@ func (resource *route) Register(agent base.RoutesAgent) {
 	agent.RegisterPost("/test", resource.dummyPost)
 	agent.RegisterPut("/test", resource.dummyPut)
 	agent.RegisterDelete("/test", resource.dummyDelete)
+	agent.RegisterPatch("/test", resource.dummyPatch)
+	agent.RegisterHead("/test", resource.dummyGet)
+	agent.RegisterOptions("/test", resource.dummyOptions)
+	agent.Register(http.MethodPost, "/test/{id}", resource.dummyPost,
+		base.WithName("create-test"),
+		base.WithSummary("Creates a test"),
+		base.WithTags("tests", "dummies"),
+		base.RequiresAuth(),
+		base.WithBodyLimit(1024),
+	)
 }
 
-func (resource *route) dummyGet(w http.ResponseWriter, r *http.Request)    {}
-func (resource *route) dummyPost(w http.ResponseWriter, r *http.Request)   {}
-func (resource *route) dummyPut(w http.ResponseWriter, r *http.Request)    {}
-func (resource *route) dummyDelete(w http.ResponseWriter, r *http.Request) {}
+func (resource *route) dummyGet(w http.ResponseWriter, r *http.Request)     {}
+func (resource *route) dummyPost(w http.ResponseWriter, r *http.Request)    {}
+func (resource *route) dummyPut(w http.ResponseWriter, r *http.Request)     {}
+func (resource *route) dummyDelete(w http.ResponseWriter, r *http.Request)  {}
+func (resource *route) dummyPatch(w http.ResponseWriter, r *http.Request)   {}
+func (resource *route) dummyOptions(w http.ResponseWriter, r *http.Request) {}
 
 func TestMockRoutesAgent(t *testing.T) {
 
@@ -39,6 +52,28 @@ func TestMockRoutesAgent(t *testing.T) {
 	verifyThatTestRoute.ForHTTPMethod(http.MethodPost).UsesHandler(route.dummyPost)
 	verifyThatTestRoute.ForHTTPMethod(http.MethodPut).UsesHandler(route.dummyPut)
 	verifyThatTestRoute.ForHTTPMethod(http.MethodDelete).UsesHandler(route.dummyDelete)
+	verifyThatTestRoute.ForHTTPMethod(http.MethodPatch).UsesHandler(route.dummyPatch)
+	verifyThatTestRoute.ForHTTPMethod(http.MethodHead).UsesHandler(route.dummyGet)
+	verifyThatTestRoute.ForHTTPMethod(http.MethodOptions).UsesHandler(route.dummyOptions)
+
+	// metadata
+	verifyThatTestRoute.ForHTTPMethod(http.MethodGet).
+		HasName("").
+		HasSummary("").
+		HasTags().
+		RequiresAuth(false).
+		HasBodyLimit(0)
+	agent.VerifyThatRoute(t, "/test/{id}").ForHTTPMethod(http.MethodPost).
+		UsesHandler(route.dummyPost).
+		HasName("create-test").
+		HasSummary("Creates a test").
+		HasTags("tests", "dummies").
+		RequiresAuth(true).
+		HasBodyLimit(1024)
+
+	// handler funcs
+	test.AssertTrue("Registered handler func is returned", agent.GetHandlerFunc(http.MethodGet, "/test") != nil, t)
+	test.AssertTrue("Unregistered handler func isn't returned", agent.GetHandlerFunc(http.MethodGet, "/other") == nil, t)
 }
 
 func TestMockRoutesAgent_with_handlerFuncRegistrationOverride(t *testing.T) {
@@ -68,3 +103,47 @@ func TestMockRoutesAgent_with_handlerFuncRegistrationOverride(t *testing.T) {
 
 	verifyThatTestRoute.ForHTTPMethod(http.MethodDelete).UsesHandler(route.dummyDelete)
 }
+
+type groupedRoute struct{ route }
+
+func (resource *groupedRoute) Register(agent base.RoutesAgent) {
+	noop := func(h http.Handler) http.Handler { return h }
+	api := agent.Group("/api", base.WithStrictSlash(true))
+	v1 := api.Group("/v1", base.WithGroupMiddlewares(noop, noop))
+	v1.RegisterGet("/test", resource.dummyGet)
+	admin := agent.Group("/admin", base.WithHost("admin.example.com"))
+	admin.RegisterDelete("/test", resource.dummyDelete)
+}
+
+func TestMockRoutesAgent_with_groups(t *testing.T) {
+
+	// Arrange
+	agent := NewMockRoutesAgent()
+	route := groupedRoute{}
+
+	// Act
+	route.Register(agent)
+
+	// Assert
+	agent.VerifyThatGroup(t, "/api").Exists().UsesStrictSlash(true).HasHost("").HasMiddlewareCount(0)
+	agent.VerifyThatGroup(t, "/api/v1").Exists().UsesStrictSlash(false).HasMiddlewareCount(2)
+	agent.VerifyThatGroup(t, "/admin").Exists().HasHost("admin.example.com")
+
+	agent.VerifyThatRoute(t, "/api/v1/test").ForHTTPMethod(http.MethodGet).UsesHandler(route.dummyGet)
+	agent.VerifyThatRoute(t, "/admin/test").ForHTTPMethod(http.MethodDelete).UsesHandler(route.dummyDelete)
+}
+
+func TestMockRoutesAgent_with_versions(t *testing.T) {
+
+	// Arrange
+	agent := NewMockRoutesAgent()
+	route := route{}
+	versioned := &base.VersionedRoutes{Routes: &route, Versions: []string{"v1", "v2"}}
+
+	// Act
+	versioned.Register(agent)
+
+	// Assert
+	agent.VerifyThatVersionedRoute(t, "v1", "/test").ForHTTPMethod(http.MethodGet).UsesHandler(route.dummyGet)
+	agent.VerifyThatVersionedRoute(t, "v2", "/test").ForHTTPMethod(http.MethodGet).UsesHandler(route.dummyGet)
+}
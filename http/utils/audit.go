@@ -0,0 +1,17 @@
+package utils
+
+import (
+	"net/http"
+
+	"github.com/saharsh-samples/go-mux-sql-starter/db"
+)
+
+// newAuditContext makes request ID of request (requestIDHeader) available
+// to db.Audit. Authentication middlewares add the actor
+func newAuditContext(requestIDHeader string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(db.NewAuditContext(r.Context(), "", r.Header.Get(requestIDHeader))))
+		})
+	}
+}
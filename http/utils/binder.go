@@ -0,0 +1,341 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Binder fills structs from requests using field tags:
+//
+//	ID     int64    `path:"id"`
+//	Limit  int      `query:"limit,default=20"`
+//	Tags   []string `query:"tag"`
+//	Tenant string   `header:"X-Tenant,required"`
+//
+// Remaining fields are decoded from the body according to its Content-Type.
+// Supported parameter types are strings, ints, uints, floats, bools,
+// time.Time (RFC 3339), JSONDate, pointers to these and, for query and
+// header parameters, slices of these. Parameter fields of other types fail
+// binding with Internal Server Error.
+// Bound structs are then validated like in ParseJSONRequest. All errors are
+// reported together in a single Bad Request response
+type Binder interface {
+	Bind(r *http.Request, value interface{}, w http.ResponseWriter) error
+}
+
+// --------
+// Internal
+// --------
+
+type binder struct {
+	jsonUtils *jsonUtils
+
+	// errors of checked parameter field types by struct type
+	mutex sync.Mutex
+	types map[reflect.Type]error
+}
+
+// parameter sources in order fields are bound from
+var parameterSources = []string{"path", "query", "header"}
+
+func (b *binder) Bind(r *http.Request, value interface{}, w http.ResponseWriter) error {
+
+	target := reflect.ValueOf(value)
+	if target.Kind() != reflect.Ptr || target.Elem().Kind() != reflect.Struct {
+		panic(fmt.Sprintf("Can only bind to pointers to structs, not %T", value))
+	}
+	b.mutex.Lock()
+	typeError := b.checkType(target.Elem().Type())
+	b.mutex.Unlock()
+	if typeError != nil {
+		fmt.Printf("Unable to bind request: %v\n", typeError)
+		b.jsonUtils.InternalError(w, "Unable to bind request")
+		return typeError
+	}
+
+	var errs FieldErrors
+
+	// body first, so that parameter fields set by it can be reset
+	if r.Body != nil && r.ContentLength != 0 {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			var maxBytesError *http.MaxBytesError
+			if errors.As(err, &maxBytesError) {
+				b.jsonUtils.RequestEntityTooLarge(w, fmt.Sprintf("Request body must not exceed %d bytes", maxBytesError.Limit))
+			} else {
+				b.jsonUtils.BadRequest(w, "Unable to read request body")
+			}
+			return err
+		}
+		if len(bytes.TrimSpace(body)) > 0 {
+			decoder, found := b.jsonUtils.codecs.decoder(r.Header.Get("Content-Type"))
+			if !found {
+				b.jsonUtils.UnsupportedMediaType(w, fmt.Sprintf("Unsupported Content-Type '%v'. Supported media types are [%v]",
+					r.Header.Get("Content-Type"), strings.Join(b.jsonUtils.decoderMediaTypes(), ", ")))
+				return fmt.Errorf("Unsupported Content-Type '%v'", r.Header.Get("Content-Type"))
+			}
+			if err := decoder.Decode(bytes.NewReader(body), value); err != nil {
+				errs = append(errs, FieldError{In: "body", Code: "json", Message: malformedBodyMessage(decoder.MediaType())})
+			}
+		}
+	}
+
+	errs = append(errs, b.bindParameters(r, target.Elem())...)
+
+	// validation only makes sense once all values are bound
+	if len(errs) == 0 {
+		var tagError error
+		if errs, tagError = b.jsonUtils.validator.ValidateStruct(value); tagError != nil {
+			fmt.Printf("Unable to validate request: %v\n", tagError)
+			b.jsonUtils.InternalError(w, "Unable to validate request")
+			return tagError
+		}
+	}
+	if len(errs) > 0 {
+		b.jsonUtils.ValidationFailed(w, errs)
+		return errs
+	}
+
+	if body, ok := value.(JSONBody); ok {
+		validationError := body.Validate()
+		if fieldErrors, ok := validationError.(FieldErrors); ok && len(fieldErrors) > 0 {
+			b.jsonUtils.ValidationFailed(w, fieldErrors)
+			return fieldErrors
+		}
+		if validationError != nil {
+			b.jsonUtils.BadRequest(w, validationError.Error())
+			return validationError
+		}
+	}
+
+	return nil
+}
+
+// checkType reports parameter fields of t, including those of embedded
+// structs, that can't be bound. Must be called with mutex held
+func (b *binder) checkType(t reflect.Type) error {
+
+	if err, found := b.types[t]; found {
+		return err
+	}
+
+	var err error
+	for i := 0; i < t.NumField() && err == nil; i++ {
+		structField := t.Field(i)
+		if structField.Anonymous && structField.Type.Kind() == reflect.Struct {
+			err = b.checkType(structField.Type)
+			continue
+		}
+		if structField.PkgPath != "" {
+			continue
+		}
+		if in, _, found := parameterTag(structField); found && !isBindable(structField.Type, in) {
+			err = fmt.Errorf("Can't bind %v parameters to field '%v' of type %v in %v", in, structField.Name, structField.Type, t)
+		}
+	}
+
+	b.types[t] = err
+	return err
+}
+
+// isBindable returns 'true' for parameter types described by Binder
+func isBindable(t reflect.Type, in string) bool {
+
+	if t.Kind() == reflect.Slice && in != "path" {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == timeType || t == jsonDateType {
+		return true
+	}
+
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+func (b *binder) bindParameters(r *http.Request, target reflect.Value) FieldErrors {
+
+	var errs FieldErrors
+	for i := 0; i < target.NumField(); i++ {
+		structField := target.Type().Field(i)
+		if structField.Anonymous && structField.Type.Kind() == reflect.Struct {
+			errs = append(errs, b.bindParameters(r, target.Field(i))...)
+			continue
+		}
+		if structField.PkgPath != "" {
+			continue
+		}
+
+		in, tag, found := parameterTag(structField)
+		if !found {
+			continue
+		}
+		target.Field(i).Set(reflect.Zero(structField.Type))
+		name, required, defaultValue, hasDefault := parseParameterTag(tag)
+
+		var values []string
+		switch in {
+		case "path":
+			if pathValue, found := mux.Vars(r)[name]; found {
+				values = []string{pathValue}
+			}
+		case "query":
+			values = r.URL.Query()[name]
+		case "header":
+			values = r.Header.Values(name)
+		}
+
+		if len(values) == 0 {
+			if hasDefault {
+				values = []string{defaultValue}
+			} else {
+				if required {
+					errs = append(errs, FieldError{Field: name, In: in, Code: "required", Message: "is required"})
+				}
+				continue
+			}
+		}
+
+		if err := setParameter(target.Field(i), values); err != nil {
+			errs = append(errs, FieldError{Field: name, In: in, Code: err.code, Message: err.message})
+		}
+	}
+	return errs
+}
+
+// parameterTag returns source and tag of field bound from a parameter
+func parameterTag(field reflect.StructField) (string, string, bool) {
+	for _, in := range parameterSources {
+		if tag, found := field.Tag.Lookup(in); found && tag != "" && tag != "-" {
+			return in, tag, true
+		}
+	}
+	return "", "", false
+}
+
+// parseParameterTag of form 'name[,required][,default=value]'. Default
+// value must be last since it can contain commas
+func parseParameterTag(tag string) (name string, required bool, defaultValue string, hasDefault bool) {
+	if index := strings.Index(tag, ",default="); index >= 0 {
+		tag, defaultValue, hasDefault = tag[:index], tag[index+len(",default="):], true
+	}
+	options := strings.Split(tag, ",")
+	for _, option := range options[1:] {
+		required = required || option == "required"
+	}
+	return options[0], required, defaultValue, hasDefault
+}
+
+type bindingError struct {
+	code    string
+	message string
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	jsonDateType = reflect.TypeOf(JSONDate{})
+)
+
+func setParameter(field reflect.Value, values []string) *bindingError {
+
+	if field.Kind() == reflect.Slice {
+		slice := reflect.MakeSlice(field.Type(), len(values), len(values))
+		for i, value := range values {
+			if err := setValue(slice.Index(i), value); err != nil {
+				return err
+			}
+		}
+		field.Set(slice)
+		return nil
+	}
+
+	if len(values) > 1 {
+		return &bindingError{code: "multiple", message: "expected ONE and only ONE value"}
+	}
+
+	if field.Kind() == reflect.Ptr {
+		pointer := reflect.New(field.Type().Elem())
+		if err := setValue(pointer.Elem(), values[0]); err != nil {
+			return err
+		}
+		field.Set(pointer)
+		return nil
+	}
+
+	return setValue(field, values[0])
+}
+
+func setValue(field reflect.Value, value string) *bindingError {
+
+	invalid := func(description string) *bindingError {
+		return &bindingError{code: "type", message: fmt.Sprintf("'%v' is not %v", value, description)}
+	}
+
+	switch field.Type() {
+	case timeType:
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return invalid("a date-time formatted as RFC 3339")
+		}
+		field.Set(reflect.ValueOf(parsed))
+		return nil
+	case jsonDateType:
+		parsed, err := time.Parse(DateFormat, value)
+		if err != nil {
+			return invalid("a date formatted as YYYY-MM-DD")
+		}
+		field.Set(reflect.ValueOf(JSONDate(parsed)))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(strings.ToLower(value))
+		if err != nil {
+			return invalid("a boolean")
+		}
+		field.SetBool(parsed)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		parsed, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return invalid("an integer")
+		}
+		field.SetInt(parsed)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		parsed, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return invalid("a non-negative integer")
+		}
+		field.SetUint(parsed)
+	case reflect.Float32, reflect.Float64:
+		parsed, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return invalid("a number")
+		}
+		field.SetFloat(parsed)
+	default:
+		// unreachable, as field types are checked before binding
+		return &bindingError{code: "type", message: fmt.Sprintf("can't bind to fields of type %v", field.Type())}
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/saharsh-samples/go-mux-sql-starter/test"
+)
+
+type boundRequest struct {
+	ID      int64     `path:"id"`
+	Limit   int       `query:"limit,default=20" validate:"max=100"`
+	Active  *bool     `query:"active"`
+	Tags    []string  `query:"tag"`
+	Since   time.Time `query:"since"`
+	On      JSONDate  `query:"on,default=2020-01-31"`
+	Tenant  string    `header:"X-Tenant,required"`
+	Name    string    `validate:"required"`
+	Comment string
+}
+
+func bind(method string, target string, headers map[string]string, body string) (*httptest.ResponseRecorder, *boundRequest, error) {
+
+	value := &boundRequest{}
+	w := httptest.NewRecorder()
+
+	router := mux.NewRouter()
+	var err error
+	router.HandleFunc("/things/{id}", func(w http.ResponseWriter, r *http.Request) {
+		err = Bootstrap(nil).Binder.Bind(r, value, w)
+	})
+
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	if body == "" {
+		r = httptest.NewRequest(method, target, nil)
+	}
+	for name, headerValue := range headers {
+		r.Header.Set(name, headerValue)
+	}
+	router.ServeHTTP(w, r)
+	return w, value, err
+}
+
+func TestBinder_Bind(t *testing.T) {
+
+	// act
+	w, bound, err := bind(http.MethodPost,
+		"/things/42?active=TRUE&tag=a&tag=b&since=2021-06-01T10:00:00Z",
+		map[string]string{"X-Tenant": "acme"},
+		`{"Name": "thing", "Comment": "hello", "ID": 7}`)
+
+	// assert
+	test.AssertTrue("Expected no binding errors", err == nil, t)
+	test.AssertEquals("", 200, w.Code, t)
+	test.AssertEquals("", int64(42), bound.ID, t)
+	test.AssertEquals("", 20, bound.Limit, t)
+	test.AssertEquals("", true, *bound.Active, t)
+	test.AssertEquals("", "a,b", strings.Join(bound.Tags, ","), t)
+	test.AssertEquals("", time.Date(2021, 6, 1, 10, 0, 0, 0, time.UTC), bound.Since, t)
+	test.AssertEquals("", "2020-01-31", time.Time(bound.On).Format(DateFormat), t)
+	test.AssertEquals("", "acme", bound.Tenant, t)
+	test.AssertEquals("", "thing", bound.Name, t)
+	test.AssertEquals("", "hello", bound.Comment, t)
+}
+
+func TestBinder_Bind_with_errors(t *testing.T) {
+
+	// act
+	w, _, err := bind(http.MethodGet, "/things/abc?limit=1&limit=2&active=maybe&on=yesterday", nil, "")
+
+	// assert
+	test.AssertTrue("Expected binding errors", err != nil, t)
+	test.AssertEquals("", 400, w.Code, t)
+
+	errorMsg := &ErrorMessage{}
+	json.Unmarshal(w.Body.Bytes(), errorMsg)
+	test.AssertEquals("",
+		"id:type,limit:multiple,active:type,on:type,X-Tenant:required",
+		describe(errorMsg.Errors), t)
+	test.AssertEquals("", "path", errorMsg.Errors[0].In, t)
+	test.AssertEquals("", "'abc' is not an integer", errorMsg.Errors[0].Message, t)
+	test.AssertEquals("", "header", errorMsg.Errors[4].In, t)
+}
+
+func TestBinder_Bind_with_validation_errors(t *testing.T) {
+
+	// act
+	w, _, _ := bind(http.MethodPost, "/things/1?limit=500", map[string]string{"X-Tenant": "acme"}, `{"Comment": "no name"}`)
+
+	// assert
+	errorMsg := &ErrorMessage{}
+	json.Unmarshal(w.Body.Bytes(), errorMsg)
+	test.AssertEquals("", 400, w.Code, t)
+	test.AssertEquals("", "limit:max,Name:required", describe(errorMsg.Errors), t)
+	test.AssertEquals("", "query", errorMsg.Errors[0].In, t)
+	test.AssertEquals("", "body", errorMsg.Errors[1].In, t)
+
+	// malformed body
+	w, _, _ = bind(http.MethodPost, "/things/1", map[string]string{"X-Tenant": "acme"}, `{"Name": `)
+	errorMsg = &ErrorMessage{}
+	json.Unmarshal(w.Body.Bytes(), errorMsg)
+	test.AssertEquals("", ":json", describe(errorMsg.Errors), t)
+}
+
+type unbindableRequest struct {
+	Filter map[string]string `query:"filter"`
+}
+
+type failingReader struct{}
+
+func (reader *failingReader) Read(p []byte) (int, error) {
+	return 0, errors.New("connection reset")
+}
+
+func TestBinder_Bind_with_unsupported_parameter_type(t *testing.T) {
+
+	// arrange
+	binder := Bootstrap(nil).Binder
+
+	// act and assert
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		err := binder.Bind(httptest.NewRequest(http.MethodGet, "/things?filter=a", nil), &unbindableRequest{}, w)
+		test.AssertTrue("Expected unsupported field type to be reported", strings.Contains(err.Error(), "field 'Filter'"), t)
+		test.AssertEquals("", 500, w.Code, t)
+	}
+}
+
+func TestBinder_Bind_with_unreadable_body(t *testing.T) {
+
+	// arrange
+	r := httptest.NewRequest(http.MethodPost, "/things", &failingReader{})
+	r.ContentLength = -1
+	w := httptest.NewRecorder()
+
+	// act
+	err := Bootstrap(nil).Binder.Bind(r, &boundRequest{}, w)
+
+	// assert
+	test.AssertTrue("Expected read error", err != nil, t)
+	test.AssertEquals("", 400, w.Code, t)
+}
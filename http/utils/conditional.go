@@ -0,0 +1,176 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+
+	// NoETags are computed for responses (default)
+	NoETags = ""
+
+	// WeakETags (e.g. W/"xyz") are computed from response bodies. Suitable
+	// when semantically equivalent bodies may differ in bytes
+	WeakETags = "weak"
+
+	// StrongETags (e.g. "xyz") are computed from response bodies. Usable
+	// with If-Match
+	StrongETags = "strong"
+)
+
+// VersionETag returns the strong ETag of a resource version (e.g. the
+// value of a version column incremented by every update)
+func VersionETag(version int64) string {
+	return fmt.Sprintf("\"%d\"", version)
+}
+
+// IfMatchVersion returns the resource version of the If-Match header of
+// request, if header is a single version ETag. Handlers pass it on to
+// the update or delete conditioned on the version column
+func IfMatchVersion(r *http.Request) (int64, bool) {
+	tags := parseETags(r.Header.Get("If-Match"))
+	if len(tags) != 1 || strings.HasPrefix(tags[0], "W/") {
+		return 0, false
+	}
+	version, err := strconv.ParseInt(strings.Trim(tags[0], "\""), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return version, true
+}
+
+// SetValidators sets ETag and Last-Modified headers of response. Call
+// before SetJSONResponse or SetResponse so that conditional GETs can be
+// answered with 304 Not Modified. Empty ETag and zero time are skipped
+func SetValidators(w http.ResponseWriter, etag string, lastModified time.Time) {
+	if etag != "" {
+		w.Header().Set("ETag", etag)
+	}
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+}
+
+// CheckPreconditions evaluates If-Match and If-Unmodified-Since headers of
+// request against current ETag and modification time of the resource.
+// Writes 412 Precondition Failed, or 428 Precondition Required if If-Match
+// is required but missing, and returns 'false' if request must not proceed.
+// Empty ETag means resource doesn't exist
+func (jsonUtils *jsonUtils) CheckPreconditions(w http.ResponseWriter, r *http.Request, etag string, lastModified time.Time) bool {
+
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+		if !matchesETag(ifMatch, etag, false) {
+			jsonUtils.PreconditionFailed(w, "Resource has been modified (If-Match)")
+			return false
+		}
+		return true
+	}
+
+	if since, err := http.ParseTime(r.Header.Get("If-Unmodified-Since")); err == nil && !lastModified.IsZero() {
+		if lastModified.Truncate(time.Second).After(since) {
+			jsonUtils.PreconditionFailed(w, "Resource has been modified (If-Unmodified-Since)")
+			return false
+		}
+		return true
+	}
+
+	if jsonUtils.requireIfMatch && isUnsafeUpdate(r.Method) {
+		jsonUtils.PreconditionRequired(w, "If-Match header is required")
+		return false
+	}
+
+	return true
+}
+
+// --------
+// Internal
+// --------
+
+// writeBody of successful responses with an ETag, if configured, and
+// answers conditional GETs with 304 Not Modified
+func (jsonUtils *jsonUtils) writeBody(w http.ResponseWriter, statusCode int, body []byte) {
+
+	if statusCode >= http.StatusOK && statusCode < http.StatusMultipleChoices {
+		if jsonUtils.etags != NoETags && w.Header().Get("ETag") == "" {
+			w.Header().Set("ETag", computeETag(body, jsonUtils.etags == WeakETags))
+		}
+		if r, found := requestOf(w); found && statusCode == http.StatusOK && notModified(r, w.Header()) {
+			w.Header().Del("Content-Type")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	w.WriteHeader(statusCode)
+	w.Write(body)
+}
+
+func computeETag(body []byte, weak bool) string {
+	sum := sha256.Sum256(body)
+	etag := "\"" + base64.RawURLEncoding.EncodeToString(sum[:16]) + "\""
+	if weak {
+		return "W/" + etag
+	}
+	return etag
+}
+
+// notModified evaluates If-None-Match, or If-Modified-Since in its absence,
+// of GET and HEAD requests against response headers
+func notModified(r *http.Request, header http.Header) bool {
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		return matchesETag(ifNoneMatch, header.Get("ETag"), true)
+	}
+
+	since, sinceErr := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	lastModified, lastModifiedErr := http.ParseTime(header.Get("Last-Modified"))
+	return sinceErr == nil && lastModifiedErr == nil && !lastModified.After(since)
+}
+
+// matchesETag returns 'true' if any of the comma separated ETags (or "*")
+// matches current one. Weak comparison ignores W/ prefixes, while strong
+// comparison never matches weak ETags
+func matchesETag(header string, current string, weak bool) bool {
+
+	if current == "" {
+		return false
+	}
+
+	for _, tag := range parseETags(header) {
+		if tag == "*" {
+			return true
+		}
+		if weak {
+			if strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(current, "W/") {
+				return true
+			}
+		} else if tag == current && !strings.HasPrefix(tag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+func parseETags(header string) []string {
+	tags := []string{}
+	for _, tag := range strings.Split(header, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+func isUnsafeUpdate(method string) bool {
+	return method == http.MethodPut || method == http.MethodPatch || method == http.MethodDelete
+}
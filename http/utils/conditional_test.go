@@ -0,0 +1,149 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/saharsh-samples/go-mux-sql-starter/test"
+)
+
+type versionedThing struct {
+	Name    string
+	Version int64
+}
+
+// serveConditional serves request through ErrorContext like the server does
+func serveConditional(r *http.Request, handler func(w http.ResponseWriter, r *http.Request)) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	ErrorContext(http.HandlerFunc(handler)).ServeHTTP(w, r)
+	return w
+}
+
+func TestSetJSONResponse_with_computed_etags(t *testing.T) {
+
+	// arrange
+	strong := Bootstrap(&ContextIn{ETags: StrongETags}).JSONUtils
+	weak := Bootstrap(&ContextIn{ETags: WeakETags}).JSONUtils
+	respond := func(jsonUtils JSONUtils, statusCode int) func(w http.ResponseWriter, r *http.Request) {
+		return func(w http.ResponseWriter, r *http.Request) {
+			jsonUtils.SetJSONResponse(w, statusCode, &versionedThing{Name: "thing"})
+		}
+	}
+
+	// act
+	first := serveConditional(httptest.NewRequest(http.MethodGet, "/things/1", nil), respond(strong, http.StatusOK))
+	etag := first.Header().Get("ETag")
+	ifNoneMatch := httptest.NewRequest(http.MethodGet, "/things/1", nil)
+	ifNoneMatch.Header.Set("If-None-Match", `"other", `+etag)
+	second := serveConditional(ifNoneMatch, respond(strong, http.StatusOK))
+	weakResponse := serveConditional(ifNoneMatch, respond(weak, http.StatusOK))
+	created := serveConditional(httptest.NewRequest(http.MethodPost, "/things", nil), respond(strong, http.StatusCreated))
+	failed := serveConditional(ifNoneMatch, func(w http.ResponseWriter, r *http.Request) { strong.NotFound(w, "gone") })
+
+	// assert
+	test.AssertEquals("", http.StatusOK, first.Code, t)
+	test.AssertTrue("Expected strong ETag", strings.HasPrefix(etag, "\"") && len(etag) > 2, t)
+	test.AssertEquals("", http.StatusNotModified, second.Code, t)
+	test.AssertEquals("", 0, second.Body.Len(), t)
+	test.AssertEquals("", etag, second.Header().Get("ETag"), t)
+	test.AssertEquals("", "W/"+etag, weakResponse.Header().Get("ETag"), t)
+	test.AssertEquals("If-None-Match uses weak comparison", http.StatusNotModified, weakResponse.Code, t)
+	test.AssertEquals("", etag, created.Header().Get("ETag"), t)
+	test.AssertEquals("", http.StatusNotFound, failed.Code, t)
+	test.AssertEquals("", "", failed.Header().Get("ETag"), t)
+	test.AssertEquals("", "", Bootstrap(nil).JSONUtils.(*jsonUtils).etags, t)
+}
+
+func TestSetResponse_with_validators(t *testing.T) {
+
+	// arrange
+	jsonUtils := Bootstrap(nil).JSONUtils
+	modified := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		SetValidators(w, VersionETag(3), modified)
+		jsonUtils.SetResponse(w, r, http.StatusOK, &versionedThing{Name: "thing", Version: 3})
+	}
+	request := func(header string, value string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/things/1", nil)
+		r.Header.Set(header, value)
+		return r
+	}
+
+	// act and assert
+	w := serveConditional(request("Accept", JSONMediaType), handler)
+	test.AssertEquals("", http.StatusOK, w.Code, t)
+	test.AssertEquals("", `"3"`, w.Header().Get("ETag"), t)
+	test.AssertEquals("", "Wed, 01 May 2024 10:00:00 GMT", w.Header().Get("Last-Modified"), t)
+
+	test.AssertEquals("", http.StatusNotModified, serveConditional(request("If-None-Match", `"3"`), handler).Code, t)
+	test.AssertEquals("", http.StatusOK, serveConditional(request("If-None-Match", `"2"`), handler).Code, t)
+	test.AssertEquals("", http.StatusNotModified, serveConditional(request("If-None-Match", "*"), handler).Code, t)
+	test.AssertEquals("", http.StatusNotModified, serveConditional(request("If-Modified-Since", modified.Format(http.TimeFormat)), handler).Code, t)
+	test.AssertEquals("", http.StatusOK, serveConditional(request("If-Modified-Since", modified.Add(-time.Second).Format(http.TimeFormat)), handler).Code, t)
+
+	ignored := request("If-None-Match", `"2"`)
+	ignored.Header.Set("If-Modified-Since", modified.Format(http.TimeFormat))
+	test.AssertEquals("If-Modified-Since is ignored with If-None-Match", http.StatusOK, serveConditional(ignored, handler).Code, t)
+}
+
+func TestCheckPreconditions(t *testing.T) {
+
+	// arrange
+	modified := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	check := func(jsonUtils JSONUtils, method string, header string, value string, etag string) (*httptest.ResponseRecorder, bool) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(method, "/things/1", nil)
+		if header != "" {
+			r.Header.Set(header, value)
+		}
+		return w, jsonUtils.CheckPreconditions(w, r, etag, modified)
+	}
+	jsonUtils := Bootstrap(nil).JSONUtils
+	requiring := Bootstrap(&ContextIn{RequireIfMatch: true}).JSONUtils
+
+	// act and assert
+	_, ok := check(jsonUtils, http.MethodPut, "If-Match", `"1", "3"`, VersionETag(3))
+	test.AssertTrue("Expected matching version to pass", ok, t)
+	_, ok = check(jsonUtils, http.MethodPut, "If-Match", "*", VersionETag(3))
+	test.AssertTrue("Expected '*' to match existing resource", ok, t)
+	_, ok = check(jsonUtils, http.MethodPut, "", "", VersionETag(3))
+	test.AssertTrue("Expected If-Match to be optional", ok, t)
+
+	w, ok := check(jsonUtils, http.MethodPut, "If-Match", `"2"`, VersionETag(3))
+	test.AssertTrue("Expected stale version to fail", !ok, t)
+	test.AssertEquals("", http.StatusPreconditionFailed, w.Code, t)
+	_, ok = check(jsonUtils, http.MethodDelete, "If-Match", `W/"3"`, VersionETag(3))
+	test.AssertTrue("Expected weak ETag to fail strong comparison", !ok, t)
+	_, ok = check(jsonUtils, http.MethodDelete, "If-Match", "*", "")
+	test.AssertTrue("Expected '*' to fail for missing resource", !ok, t)
+
+	_, ok = check(jsonUtils, http.MethodPut, "If-Unmodified-Since", modified.Format(http.TimeFormat), VersionETag(3))
+	test.AssertTrue("Expected unmodified resource to pass", ok, t)
+	w, ok = check(jsonUtils, http.MethodPut, "If-Unmodified-Since", modified.Add(-time.Hour).Format(http.TimeFormat), VersionETag(3))
+	test.AssertTrue("Expected modified resource to fail", !ok, t)
+	test.AssertEquals("", http.StatusPreconditionFailed, w.Code, t)
+
+	w, ok = check(requiring, http.MethodDelete, "", "", VersionETag(3))
+	test.AssertTrue("Expected missing If-Match to fail", !ok, t)
+	test.AssertEquals("", http.StatusPreconditionRequired, w.Code, t)
+	_, ok = check(requiring, http.MethodGet, "", "", VersionETag(3))
+	test.AssertTrue("Expected safe methods to pass", ok, t)
+}
+
+func TestIfMatchVersion(t *testing.T) {
+
+	for header, expected := range map[string]int64{`"7"`: 7, ` "12" `: 12, `W/"7"`: -1, `"7", "8"`: -1, `"abc"`: -1, "": -1, "*": -1} {
+		r := httptest.NewRequest(http.MethodPut, "/things/1", nil)
+		r.Header.Set("If-Match", header)
+		version, ok := IfMatchVersion(r)
+		if expected < 0 {
+			test.AssertTrue("Expected no version for "+header, !ok, t)
+		} else {
+			test.AssertTrue("Expected version for "+header, ok, t)
+			test.AssertEquals("", expected, version, t)
+		}
+	}
+}
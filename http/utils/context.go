@@ -1,23 +1,96 @@
 package utils
 
+import (
+	"net/http"
+	"reflect"
+)
+
 // ContextIn describes dependecies needed by this package
 type ContextIn struct {
-	// Nothing
+
+	// ValidationRules usable in 'validate' tags in addition to the
+	// built-in rules, keyed by name. Can replace built-in rules
+	ValidationRules map[string]ValidationRule
+
+	// ErrorFormat is ErrorMessageFormat (default) or ProblemDetailsFormat
+	ErrorFormat string
+
+	// ProblemTypeBaseURI prefixes problem types (defaults to
+	// DefaultProblemTypeBaseURI)
+	ProblemTypeBaseURI string
+
+	// RequestIDHeader is read from request, or response, for the request ID
+	// of problem details and audit entries (defaults to
+	// DefaultRequestIDHeader)
+	RequestIDHeader string
+
+	// CursorSecret signs pagination cursors. If empty, a random secret is
+	// used and cursors are only valid until restart
+	CursorSecret []byte
+
+	// Encoders and Decoders of media types in addition to the built-in
+	// JSON, XML, CSV, MessagePack and YAML ones. Replace built-in ones of
+	// the same media type
+	Encoders []Encoder
+	Decoders []Decoder
+
+	// ETags computed from response bodies: NoETags (default), WeakETags or
+	// StrongETags. ETags set by handlers (e.g. VersionETag) take precedence
+	ETags string
+
+	// RequireIfMatch makes CheckPreconditions reject PUT, PATCH and DELETE
+	// requests without If-Match header with 428 Precondition Required
+	RequireIfMatch bool
 }
 
 // ContextOut describes dependencies exported by this package
 type ContextOut struct {
 	JSONUtils JSONUtils
 	URLUtils  URLUtils
+	Validator Validator
+	Binder    Binder
+
+	CursorPagination CursorPagination
+	Streamer         Streamer
+
+	// AuditContext middleware makes request ID available to db.Audit
+	AuditContext func(http.Handler) http.Handler
 }
 
 // Bootstrap initializes this module with ContextIn and exports
 // resulting ContextOut
 func Bootstrap(in *ContextIn) *ContextOut {
 
+	// ContextIn is optional
+	if in == nil {
+		in = &ContextIn{}
+	}
+	validator := newValidator(in.ValidationRules)
+
+	jsonUtils := &jsonUtils{
+		validator:          validator,
+		errorFormat:        in.ErrorFormat,
+		problemTypeBaseURI: in.ProblemTypeBaseURI,
+		requestIDHeader:    in.RequestIDHeader,
+		codecs:             newCodecs(in.Encoders, in.Decoders),
+		etags:              in.ETags,
+		requireIfMatch:     in.RequireIfMatch,
+	}
+	if jsonUtils.problemTypeBaseURI == "" {
+		jsonUtils.problemTypeBaseURI = DefaultProblemTypeBaseURI
+	}
+	if jsonUtils.requestIDHeader == "" {
+		jsonUtils.requestIDHeader = DefaultRequestIDHeader
+	}
+
 	out := &ContextOut{}
-	out.JSONUtils = &jsonUtils{}
+	out.JSONUtils = jsonUtils
 	out.URLUtils = &urlUtils{}
+	out.Validator = validator
+	out.Binder = &binder{jsonUtils: jsonUtils, types: make(map[reflect.Type]error)}
+	out.CursorPagination = newCursorPagination(in.CursorSecret, jsonUtils)
+	out.Streamer = &streamer{jsonUtils: jsonUtils}
+	out.AuditContext = newAuditContext(jsonUtils.requestIDHeader)
 
 	return out
 }
@@ -0,0 +1,266 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CursorQueryParameter holds cursor of requested page
+const CursorQueryParameter = "cursor"
+
+// Cursor is the keyset position a page continues from. Position holds the
+// sort column values of the row next to the page. Pages are after the
+// position, or before it if Backward. Position values can be of basic
+// types, time.Time, JSONDate, []byte or driver.Valuer (e.g. sql.NullString)
+type Cursor struct {
+	Position []interface{}
+	Backward bool
+
+	// Sort the position was taken with. Set when cursor is encoded, so
+	// cursors can't be reused with a different sort
+	Sort string
+}
+
+// CursorPagedResponse is the container for cursor paginated responses.
+// Next and Prev are cursors of adjacent pages, empty if there are none
+type CursorPagedResponse struct {
+	Limit   int
+	Next    string
+	Prev    string
+	Payload []interface{}
+
+	// response only struct, so validation is unnecessary
+	AlwaysValidJSON
+}
+
+// CursorPagination reads cursors from requests and writes pages with
+// cursors of adjacent pages. Cursors are opaque tokens signed with
+// HMAC-SHA256, so clients can't forge positions
+type CursorPagination interface {
+
+	// GetCursor from 'cursor' query param. Returns nil if there is none.
+	// Cursors issued for a different path or sort are rejected
+	GetCursor(r *http.Request) (*Cursor, error)
+
+	// SetCursorPagedResponse with 'next' and 'prev' cursors in body and in
+	// RFC 8288 Link header. Cursors are optional. Responds with Internal
+	// Server Error if cursor positions can't be encoded
+	SetCursorPagedResponse(w http.ResponseWriter, r *http.Request, statusCode int, payload []interface{}, limit int, next *Cursor, prev *Cursor)
+}
+
+// --------
+// Internal
+// --------
+
+type cursorPagination struct {
+	secret    []byte
+	jsonUtils JSONUtils
+}
+
+func newCursorPagination(secret []byte, jsonUtils JSONUtils) *cursorPagination {
+	if len(secret) == 0 {
+		// cursors only survive as long as the process
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			panic(err)
+		}
+	}
+	return &cursorPagination{secret: secret, jsonUtils: jsonUtils}
+}
+
+func (pagination *cursorPagination) GetCursor(r *http.Request) (*Cursor, error) {
+
+	values := r.URL.Query()[CursorQueryParameter]
+	switch len(values) {
+	case 0:
+		return nil, nil
+	case 1:
+	default:
+		return nil, fmt.Errorf("Expected ONE and only ONE value for '%v' Query Parameter", CursorQueryParameter)
+	}
+
+	cursor, path, err := pagination.decode(values[0])
+	if err != nil {
+		return nil, err
+	}
+	if path != r.URL.Path {
+		return nil, fmt.Errorf("Cursor was issued for a different path")
+	}
+	if cursor.Sort != r.URL.Query().Get(SortQueryParameter) {
+		return nil, fmt.Errorf("Cursor was issued for a different sort")
+	}
+	return cursor, nil
+}
+
+func (pagination *cursorPagination) SetCursorPagedResponse(w http.ResponseWriter, r *http.Request, statusCode int, payload []interface{}, limit int, next *Cursor, prev *Cursor) {
+
+	response := &CursorPagedResponse{Limit: limit, Payload: payload}
+	links := []string{}
+	var err error
+	if next != nil {
+		if response.Next, err = pagination.encode(next, r); err != nil {
+			fmt.Printf("Unable to encode cursor: %v\n", err)
+			pagination.jsonUtils.InternalError(w, "Unable to encode cursor")
+			return
+		}
+		links = append(links, fmt.Sprintf(`<%v>; rel="next"`, pageURL(r, response.Next)))
+	}
+	if prev != nil {
+		if response.Prev, err = pagination.encode(prev, r); err != nil {
+			fmt.Printf("Unable to encode cursor: %v\n", err)
+			pagination.jsonUtils.InternalError(w, "Unable to encode cursor")
+			return
+		}
+		links = append(links, fmt.Sprintf(`<%v>; rel="prev"`, pageURL(r, response.Prev)))
+	}
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+
+	pagination.jsonUtils.SetJSONResponse(w, statusCode, response)
+}
+
+// pageURL is request URL with cursor replaced
+func pageURL(r *http.Request, cursor string) string {
+	pageURL := *r.URL
+	query := pageURL.Query()
+	query.Set(CursorQueryParameter, cursor)
+	pageURL.RawQuery = query.Encode()
+	return pageURL.RequestURI()
+}
+
+// ---------
+// Encoding
+// ---------
+
+// cursorToken is the signed payload. Position values are tagged with
+// their type so they're decoded as the same Go type. Path of the request
+// is included so cursors of one list can't be used with another
+type cursorToken struct {
+	P [][2]string `json:"p"`
+	B bool        `json:"b,omitempty"`
+	S string      `json:"s,omitempty"`
+	R string      `json:"r"`
+}
+
+func (pagination *cursorPagination) encode(cursor *Cursor, r *http.Request) (string, error) {
+
+	token := &cursorToken{B: cursor.Backward, S: r.URL.Query().Get(SortQueryParameter), R: r.URL.Path}
+	for _, value := range cursor.Position {
+		encoded, err := encodePositionValue(value)
+		if err != nil {
+			return "", err
+		}
+		token.P = append(token.P, encoded)
+	}
+
+	payload, _ := json.Marshal(token)
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(pagination.sign(payload)), nil
+}
+
+// decode cursor and path it was issued for
+func (pagination *cursorPagination) decode(encoded string) (*Cursor, string, error) {
+
+	invalid := fmt.Errorf("Invalid cursor '%v'", encoded)
+
+	parts := strings.Split(encoded, ".")
+	if len(parts) != 2 {
+		return nil, "", invalid
+	}
+	payload, payloadErr := base64.RawURLEncoding.DecodeString(parts[0])
+	signature, signatureErr := base64.RawURLEncoding.DecodeString(parts[1])
+	if payloadErr != nil || signatureErr != nil || !hmac.Equal(signature, pagination.sign(payload)) {
+		return nil, "", invalid
+	}
+
+	token := &cursorToken{}
+	if err := json.Unmarshal(payload, token); err != nil {
+		return nil, "", invalid
+	}
+	cursor := &Cursor{Backward: token.B, Sort: token.S}
+	for _, value := range token.P {
+		decoded, err := decodePositionValue(value)
+		if err != nil {
+			return nil, "", invalid
+		}
+		cursor.Position = append(cursor.Position, decoded)
+	}
+	return cursor, token.R, nil
+}
+
+func (pagination *cursorPagination) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, pagination.secret)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// encodePositionValue tagged with its type. JSONDates are decoded as
+// time.Time and driver.Valuers as the value they hold
+func encodePositionValue(value interface{}) ([2]string, error) {
+	switch typed := value.(type) {
+	case nil:
+		return [2]string{"n", ""}, nil
+	case time.Time:
+		return [2]string{"t", typed.Format(time.RFC3339Nano)}, nil
+	case *time.Time:
+		if typed == nil {
+			return [2]string{"n", ""}, nil
+		}
+		return [2]string{"t", typed.Format(time.RFC3339Nano)}, nil
+	case JSONDate:
+		return [2]string{"t", time.Time(typed).Format(time.RFC3339Nano)}, nil
+	case int, int8, int16, int32, int64:
+		return [2]string{"i", fmt.Sprint(typed)}, nil
+	case uint, uint8, uint16, uint32, uint64:
+		return [2]string{"u", fmt.Sprint(typed)}, nil
+	case float32, float64:
+		return [2]string{"f", fmt.Sprint(typed)}, nil
+	case bool:
+		return [2]string{"b", strconv.FormatBool(typed)}, nil
+	case string:
+		return [2]string{"s", typed}, nil
+	case []byte:
+		return [2]string{"x", base64.RawURLEncoding.EncodeToString(typed)}, nil
+	case driver.Valuer:
+		held, err := typed.Value()
+		if err != nil {
+			return [2]string{}, fmt.Errorf("Can't encode cursor position value of type %T: %v", value, err)
+		}
+		if _, nested := held.(driver.Valuer); nested {
+			return [2]string{}, fmt.Errorf("Can't encode cursor position value of type %T", value)
+		}
+		return encodePositionValue(held)
+	}
+	return [2]string{}, fmt.Errorf("Can't encode cursor position value of type %T", value)
+}
+
+func decodePositionValue(value [2]string) (interface{}, error) {
+	switch value[0] {
+	case "n":
+		return nil, nil
+	case "t":
+		return time.Parse(time.RFC3339Nano, value[1])
+	case "i":
+		return strconv.ParseInt(value[1], 10, 64)
+	case "u":
+		return strconv.ParseUint(value[1], 10, 64)
+	case "f":
+		return strconv.ParseFloat(value[1], 64)
+	case "b":
+		return strconv.ParseBool(value[1])
+	case "s":
+		return value[1], nil
+	case "x":
+		return base64.RawURLEncoding.DecodeString(value[1])
+	}
+	return nil, fmt.Errorf("unknown cursor position value type '%v'", value[0])
+}
@@ -0,0 +1,116 @@
+package utils
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/saharsh-samples/go-mux-sql-starter/test"
+)
+
+func TestCursorPagination(t *testing.T) {
+
+	// Arrange
+	pagination := Bootstrap(&ContextIn{CursorSecret: []byte("secret")}).CursorPagination
+	created := time.Date(2024, 1, 1, 10, 0, 0, 5, time.UTC)
+	r := httptest.NewRequest("GET", "/things?sort=-created&limit=2", nil)
+	w := httptest.NewRecorder()
+
+	// Act
+	pagination.SetCursorPagedResponse(w, r, 200, []interface{}{"a", "b"}, 2,
+		&Cursor{Position: []interface{}{created, int64(7), "b"}},
+		&Cursor{Position: []interface{}{created, int64(6), nil}, Backward: true})
+
+	// Assert
+	response := &CursorPagedResponse{}
+	json.Unmarshal(w.Body.Bytes(), response)
+	test.AssertEquals("", 2, len(response.Payload), t)
+	test.AssertTrue("Expected Link header with next page",
+		strings.Contains(w.Header().Get("Link"), "<"+"/things?cursor="+response.Next+"&limit=2&sort=-created>; rel=\"next\""), t)
+	test.AssertTrue("Expected Link header with prev page",
+		strings.Contains(w.Header().Get("Link"), "?cursor="+response.Prev+"&limit=2&sort=-created>; rel=\"prev\""), t)
+
+	next, err := pagination.GetCursor(httptest.NewRequest("GET", "/things?sort=-created&cursor="+response.Next, nil))
+	test.AssertTrue("Expected cursor to decode", err == nil, t)
+	test.AssertFalse("", next.Backward, t)
+	test.AssertTrue("Expected time to round trip", created.Equal(next.Position[0].(time.Time)), t)
+	test.AssertEquals("", int64(7), next.Position[1], t)
+	test.AssertEquals("", "b", next.Position[2], t)
+
+	prev, _ := pagination.GetCursor(httptest.NewRequest("GET", "/things?sort=-created&cursor="+response.Prev, nil))
+	test.AssertTrue("", prev.Backward, t)
+	test.AssertTrue("", prev.Position[2] == nil, t)
+}
+
+func TestCursorPagination_GetCursor_with_invalid_cursors(t *testing.T) {
+
+	pagination := Bootstrap(&ContextIn{CursorSecret: []byte("secret")}).CursorPagination
+	other := Bootstrap(&ContextIn{CursorSecret: []byte("other")}).CursorPagination
+	w := httptest.NewRecorder()
+	other.SetCursorPagedResponse(w, httptest.NewRequest("GET", "/things", nil), 200, nil, 1, &Cursor{Position: []interface{}{1}}, nil)
+	forged := &CursorPagedResponse{}
+	json.Unmarshal(w.Body.Bytes(), forged)
+
+	w = httptest.NewRecorder()
+	pagination.SetCursorPagedResponse(w, httptest.NewRequest("GET", "/things", nil), 200, nil, 1, &Cursor{Position: []interface{}{1}}, nil)
+	valid := &CursorPagedResponse{}
+	json.Unmarshal(w.Body.Bytes(), valid)
+
+	cursor, err := pagination.GetCursor(httptest.NewRequest("GET", "/things", nil))
+	test.AssertTrue("Expected no cursor", cursor == nil && err == nil, t)
+
+	_, err = pagination.GetCursor(httptest.NewRequest("GET", "/things?cursor="+forged.Next, nil))
+	test.AssertTrue("Expected forged cursor to be rejected", err != nil, t)
+
+	_, err = pagination.GetCursor(httptest.NewRequest("GET", "/things?cursor=garbage", nil))
+	test.AssertTrue("Expected malformed cursor to be rejected", err != nil, t)
+
+	_, err = pagination.GetCursor(httptest.NewRequest("GET", "/things?sort=name&cursor="+valid.Next, nil))
+	test.AssertTrue("Expected cursor of different sort to be rejected", err != nil, t)
+
+	_, err = pagination.GetCursor(httptest.NewRequest("GET", "/others?cursor="+valid.Next, nil))
+	test.AssertTrue("Expected cursor of different path to be rejected", err != nil, t)
+
+	_, err = pagination.GetCursor(httptest.NewRequest("GET", "/things?cursor="+valid.Next, nil))
+	test.AssertTrue("Expected valid cursor", err == nil, t)
+}
+
+func TestCursorPagination_with_database_values(t *testing.T) {
+
+	// Arrange
+	pagination := Bootstrap(&ContextIn{CursorSecret: []byte("secret")}).CursorPagination
+	born := JSONDate(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	w := httptest.NewRecorder()
+
+	// Act
+	pagination.SetCursorPagedResponse(w, httptest.NewRequest("GET", "/things", nil), 200, nil, 1,
+		&Cursor{Position: []interface{}{born, []byte("ab"), sql.NullString{String: "jo", Valid: true}, sql.NullInt64{}}}, nil)
+
+	// Assert
+	response := &CursorPagedResponse{}
+	json.Unmarshal(w.Body.Bytes(), response)
+	next, err := pagination.GetCursor(httptest.NewRequest("GET", "/things?cursor="+response.Next, nil))
+	test.AssertTrue("Expected cursor to decode", err == nil, t)
+	test.AssertTrue("Expected date to round trip as time", time.Time(born).Equal(next.Position[0].(time.Time)), t)
+	test.AssertEquals("", "ab", string(next.Position[1].([]byte)), t)
+	test.AssertEquals("", "jo", next.Position[2], t)
+	test.AssertTrue("", next.Position[3] == nil, t)
+}
+
+func TestCursorPagination_with_unsupported_position_value(t *testing.T) {
+
+	// Arrange
+	pagination := Bootstrap(nil).CursorPagination
+	w := httptest.NewRecorder()
+
+	// Act
+	pagination.SetCursorPagedResponse(w, httptest.NewRequest("GET", "/things", nil), 200, nil, 1,
+		&Cursor{Position: []interface{}{struct{}{}}}, nil)
+
+	// Assert
+	test.AssertEquals("", 500, w.Code, t)
+	test.AssertEquals("", "", w.Header().Get("Link"), t)
+}
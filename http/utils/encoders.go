@@ -0,0 +1,502 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"gopkg.in/yaml.v3"
+)
+
+// Encoder writes response bodies of a media type
+type Encoder interface {
+	MediaType() string
+	Encode(w io.Writer, value interface{}) error
+}
+
+// SelectiveEncoder is an Encoder that can only encode some values (e.g. CSV
+// only encodes lists). Other encoders are negotiated for other values
+type SelectiveEncoder interface {
+	Encoder
+	CanEncode(value interface{}) bool
+}
+
+// Decoder reads request bodies of a media type
+type Decoder interface {
+	MediaType() string
+	Decode(r io.Reader, value interface{}) error
+}
+
+// Built-in media types. Encoders and decoders are registered for all of
+// them, except CSV which is only encoded
+const (
+	JSONMediaType        = "application/json"
+	XMLMediaType         = "application/xml"
+	CSVMediaType         = "text/csv"
+	MessagePackMediaType = "application/msgpack"
+	YAMLMediaType        = "application/yaml"
+)
+
+// --------
+// Internal
+// --------
+
+// codecs registered with a jsonUtils. Encoders are in order of preference
+// when clients accept several media types equally
+type codecs struct {
+	encoders []Encoder
+	decoders map[string]Decoder
+}
+
+func newCodecs(encoders []Encoder, decoders []Decoder) *codecs {
+
+	registry := &codecs{decoders: map[string]Decoder{}}
+	for _, builtIn := range []interface{}{&jsonCodec{}, &xmlCodec{}, &yamlCodec{}, &msgpackCodec{}, &csvEncoder{}} {
+		registry.addEncoder(builtIn.(Encoder))
+		if decoder, ok := builtIn.(Decoder); ok {
+			registry.decoders[decoder.MediaType()] = decoder
+		}
+	}
+	for _, encoder := range encoders {
+		registry.addEncoder(encoder)
+	}
+	for _, decoder := range decoders {
+		registry.decoders[strings.ToLower(decoder.MediaType())] = decoder
+	}
+	return registry
+}
+
+// addEncoder replacing any encoder of the same media type
+func (registry *codecs) addEncoder(encoder Encoder) {
+	for i, registered := range registry.encoders {
+		if strings.EqualFold(registered.MediaType(), encoder.MediaType()) {
+			registry.encoders[i] = encoder
+			return
+		}
+	}
+	registry.encoders = append(registry.encoders, encoder)
+}
+
+// negotiate encoder for Accept header. Returns nil if no encoder that can
+// encode value is acceptable
+func (registry *codecs) negotiate(accept string, value interface{}) Encoder {
+
+	candidates := []Encoder{}
+	for _, encoder := range registry.encoders {
+		if selective, ok := encoder.(SelectiveEncoder); !ok || selective.CanEncode(value) {
+			candidates = append(candidates, encoder)
+		}
+	}
+
+	if len(candidates) == 0 {
+		return nil
+	}
+	if strings.TrimSpace(accept) == "" {
+		return candidates[0]
+	}
+
+	for _, mediaRange := range parseAccept(accept) {
+		for _, encoder := range candidates {
+			if mediaRange.matches(encoder.MediaType()) {
+				return encoder
+			}
+		}
+	}
+	return nil
+}
+
+// decoder for Content-Type header. Bodies without content type are JSON
+func (registry *codecs) decoder(contentType string) (Decoder, bool) {
+	if strings.TrimSpace(contentType) == "" {
+		return registry.decoders[JSONMediaType], true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, false
+	}
+	if decoder, found := registry.decoders[mediaType]; found {
+		return decoder, true
+	}
+	// structured syntax suffixes, e.g. 'application/vnd.app.v2+json'
+	if suffix := strings.LastIndex(mediaType, "+"); suffix >= 0 {
+		decoder, found := registry.decoders["application/"+mediaType[suffix+1:]]
+		return decoder, found
+	}
+	return nil, false
+}
+
+func (registry *codecs) mediaTypes() []string {
+	mediaTypes := make([]string, len(registry.encoders))
+	for i, encoder := range registry.encoders {
+		mediaTypes[i] = encoder.MediaType()
+	}
+	return mediaTypes
+}
+
+// ------------------
+// Accept negotiation
+// ------------------
+
+type mediaRange struct {
+	mainType string
+	subType  string
+	quality  float64
+}
+
+// parseAccept into acceptable media ranges, most preferred first
+func parseAccept(accept string) []*mediaRange {
+
+	ranges := []*mediaRange{}
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		quality := 1.0
+		if q, found := params["q"]; found {
+			if parsed, err := strconv.ParseFloat(q, 64); err == nil {
+				quality = parsed
+			}
+		}
+		if quality <= 0 {
+			continue
+		}
+		types := strings.SplitN(mediaType, "/", 2)
+		if len(types) != 2 {
+			continue
+		}
+		ranges = append(ranges, &mediaRange{mainType: types[0], subType: types[1], quality: quality})
+	}
+
+	// more specific ranges are preferred among equally acceptable ones
+	sort.SliceStable(ranges, func(i, j int) bool {
+		if ranges[i].quality != ranges[j].quality {
+			return ranges[i].quality > ranges[j].quality
+		}
+		return ranges[i].specificity() > ranges[j].specificity()
+	})
+	return ranges
+}
+
+func (r *mediaRange) specificity() int {
+	switch {
+	case r.mainType == "*":
+		return 0
+	case r.subType == "*":
+		return 1
+	}
+	return 2
+}
+
+func (r *mediaRange) matches(mediaType string) bool {
+	types := strings.SplitN(strings.ToLower(mediaType), "/", 2)
+	if r.mainType == "*" {
+		return true
+	}
+	if r.mainType != types[0] {
+		return false
+	}
+	if r.subType == "*" || r.subType == types[1] {
+		return true
+	}
+	// structured syntax suffixes, e.g. 'application/vnd.app.v2+json'
+	suffix := strings.LastIndex(r.subType, "+")
+	return suffix >= 0 && r.subType[suffix+1:] == types[1]
+}
+
+// ------------------
+// Built-in encoders
+// ------------------
+
+type jsonCodec struct{}
+
+func (codec *jsonCodec) MediaType() string { return JSONMediaType }
+
+func (codec *jsonCodec) Encode(w io.Writer, value interface{}) error {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(encoded)
+	return err
+}
+
+func (codec *jsonCodec) Decode(r io.Reader, value interface{}) error {
+	return json.NewDecoder(r).Decode(value)
+}
+
+type xmlCodec struct{}
+
+func (codec *xmlCodec) MediaType() string { return XMLMediaType }
+
+func (codec *xmlCodec) Encode(w io.Writer, value interface{}) error {
+	return xml.NewEncoder(w).Encode(value)
+}
+
+func (codec *xmlCodec) Decode(r io.Reader, value interface{}) error {
+	return xml.NewDecoder(r).Decode(value)
+}
+
+// yamlCodec goes through JSON, so YAML documents have the same field names
+// and formats as JSON documents
+type yamlCodec struct{}
+
+func (codec *yamlCodec) MediaType() string { return YAMLMediaType }
+
+func (codec *yamlCodec) Encode(w io.Writer, value interface{}) error {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	// JSON is YAML, and nodes keep field order
+	node := &yaml.Node{}
+	if err := yaml.Unmarshal(encoded, node); err != nil {
+		return err
+	}
+	resetStyle(node)
+	return yaml.NewEncoder(w).Encode(node)
+}
+
+// resetStyle of nodes parsed from JSON, which would be written in flow style
+func resetStyle(node *yaml.Node) {
+	node.Style = node.Style &^ (yaml.FlowStyle | yaml.DoubleQuotedStyle)
+	for _, child := range node.Content {
+		resetStyle(child)
+	}
+}
+
+func (codec *yamlCodec) Decode(r io.Reader, value interface{}) error {
+	var decoded interface{}
+	if err := yaml.NewDecoder(r).Decode(&decoded); err != nil {
+		return err
+	}
+	encoded, err := json.Marshal(decoded)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(encoded, value)
+}
+
+// msgpackCodec honours json tags, so field names match JSON documents
+type msgpackCodec struct{}
+
+func (codec *msgpackCodec) MediaType() string { return MessagePackMediaType }
+
+func (codec *msgpackCodec) Encode(w io.Writer, value interface{}) error {
+	encoder := msgpack.NewEncoder(w)
+	encoder.SetCustomStructTag("json")
+	return encoder.Encode(value)
+}
+
+func (codec *msgpackCodec) Decode(r io.Reader, value interface{}) error {
+	decoder := msgpack.NewDecoder(r)
+	decoder.SetCustomStructTag("json")
+	return decoder.Decode(value)
+}
+
+// csvEncoder writes lists, i.e. slices or Payload of paged responses, of
+// structs or maps with string keys. First row holds field names
+type csvEncoder struct{}
+
+func (encoder *csvEncoder) MediaType() string { return CSVMediaType }
+
+func (encoder *csvEncoder) CanEncode(value interface{}) bool {
+	items, ok := csvItems(value)
+	if !ok {
+		return false
+	}
+	// items of interface types are only known when encoded
+	itemType := items.Type().Elem()
+	for itemType.Kind() == reflect.Ptr {
+		itemType = itemType.Elem()
+	}
+	return itemType.Kind() != reflect.Map || itemType.Key().Kind() == reflect.String
+}
+
+func (encoder *csvEncoder) Encode(w io.Writer, value interface{}) error {
+
+	items, ok := csvItems(value)
+	if !ok {
+		return fmt.Errorf("Only lists can be encoded as CSV, not %T", value)
+	}
+
+	var header []string
+	rows := [][]string{}
+	for i := 0; i < items.Len(); i++ {
+		item := indirect(items.Index(i))
+		switch item.Kind() {
+		case reflect.Struct:
+			if header == nil {
+				header = csvStructHeader(item.Type())
+			}
+			rows = append(rows, csvStructRow(item))
+		case reflect.Map:
+			if err := csvCheckMap(item); err != nil {
+				return err
+			}
+			if header == nil {
+				header = csvMapHeader(item)
+			}
+			rows = append(rows, csvMapRow(item, header))
+		case reflect.Invalid:
+			continue
+		default:
+			rows = append(rows, []string{csvCell(item)})
+		}
+	}
+
+	writer := csv.NewWriter(w)
+	if header != nil {
+		writer.Write(header)
+	}
+	writer.WriteAll(rows)
+	return writer.Error()
+}
+
+// csvItems of slices and of Payload field of paged responses
+func csvItems(value interface{}) (reflect.Value, bool) {
+	list := indirect(reflect.ValueOf(value))
+	if list.Kind() == reflect.Struct {
+		list = indirect(list.FieldByName("Payload"))
+	}
+	if list.Kind() != reflect.Slice && list.Kind() != reflect.Array {
+		return reflect.Value{}, false
+	}
+	return list, true
+}
+
+func csvStructHeader(t reflect.Type) []string {
+	header := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		if name, ok := csvFieldName(t.Field(i)); ok {
+			header = append(header, name)
+		}
+	}
+	return header
+}
+
+// csvCheckMap reports maps whose keys aren't strings, which can't be
+// matched to header names
+func csvCheckMap(item reflect.Value) error {
+	if item.Type().Key().Kind() != reflect.String {
+		return fmt.Errorf("Only maps with string keys can be encoded as CSV, not %v", item.Type())
+	}
+	return nil
+}
+
+// csvMapHeader of map with string keys, in sorted order
+func csvMapHeader(item reflect.Value) []string {
+	header := []string{}
+	for _, key := range item.MapKeys() {
+		header = append(header, key.String())
+	}
+	sort.Strings(header)
+	return header
+}
+
+// csvMapRow of map with string keys. Keys can be of named string types
+func csvMapRow(item reflect.Value, header []string) []string {
+	row := make([]string, len(header))
+	for i, name := range header {
+		row[i] = csvCell(item.MapIndex(reflect.ValueOf(name).Convert(item.Type().Key())))
+	}
+	return row
+}
+
+func csvStructRow(item reflect.Value) []string {
+	row := []string{}
+	for i := 0; i < item.NumField(); i++ {
+		if _, ok := csvFieldName(item.Type().Field(i)); ok {
+			row = append(row, csvCell(item.Field(i)))
+		}
+	}
+	return row
+}
+
+func csvFieldName(field reflect.StructField) (string, bool) {
+	if field.PkgPath != "" || field.Anonymous {
+		return "", false
+	}
+	name := jsonName(field)
+	return name, name != "-"
+}
+
+// csvCell holds strings and times as is, and anything else as JSON
+func csvCell(value reflect.Value) string {
+	if isNilValue(value) {
+		return ""
+	}
+	value = indirect(value)
+	switch typed := value.Interface().(type) {
+	case string:
+		return typed
+	case time.Time:
+		return typed.Format(time.RFC3339)
+	}
+	encoded, _ := json.Marshal(value.Interface())
+	return string(bytes.Trim(encoded, `"`))
+}
+
+// -----------------
+// Encoding helpers
+// -----------------
+
+// writeEncoded buffers encoded body, so encoding errors can still be
+// reported as errors
+func (jsonUtils *jsonUtils) writeEncoded(w http.ResponseWriter, encoder Encoder, statusCode int, body interface{}) {
+
+	buffer := &bytes.Buffer{}
+	if err := encoder.Encode(buffer, body); err != nil {
+		jsonUtils.InternalError(w, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", encoder.MediaType())
+	jsonUtils.writeBody(w, statusCode, buffer.Bytes())
+}
+
+// decode request body with decoder for its Content-Type. Writes error
+// response if that fails
+func (jsonUtils *jsonUtils) decode(r *http.Request, value interface{}, w http.ResponseWriter) error {
+
+	decoder, found := jsonUtils.codecs.decoder(r.Header.Get("Content-Type"))
+	if !found {
+		err := fmt.Errorf("Unsupported Content-Type '%v'", r.Header.Get("Content-Type"))
+		jsonUtils.UnsupportedMediaType(w, fmt.Sprintf("%v. Supported media types are [%v]", err.Error(), strings.Join(jsonUtils.decoderMediaTypes(), ", ")))
+		return err
+	}
+
+	if err := decoder.Decode(r.Body, value); err != nil {
+		jsonUtils.handleDecodeError(w, err, decoder.MediaType())
+		return err
+	}
+	return nil
+}
+
+func (jsonUtils *jsonUtils) decoderMediaTypes() []string {
+	mediaTypes := []string{}
+	for mediaType := range jsonUtils.codecs.decoders {
+		mediaTypes = append(mediaTypes, mediaType)
+	}
+	sort.Strings(mediaTypes)
+	return mediaTypes
+}
+
+// malformedBodyMessage for bodies of media type
+func malformedBodyMessage(mediaType string) string {
+	if mediaType == JSONMediaType {
+		return "Malformed JSON body"
+	}
+	return fmt.Sprintf("Malformed %v body", mediaType)
+}
@@ -0,0 +1,208 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/saharsh-samples/go-mux-sql-starter/test"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+type encodedItem struct {
+	ID       int64
+	Name     string `json:"name"`
+	Tags     []string
+	Created  time.Time
+	Optional *string
+	internal string
+
+	AlwaysValidJSON
+}
+
+var encodedCreated = time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+
+func respondWith(accept string, body interface{}) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	if accept != "" {
+		r.Header.Set("Accept", accept)
+	}
+	w := httptest.NewRecorder()
+	Bootstrap(&ContextIn{}).JSONUtils.SetResponse(w, r, http.StatusOK, body)
+	return w
+}
+
+func TestSetResponse_negotiates_encoder(t *testing.T) {
+
+	item := &encodedItem{ID: 1, Name: "gear", Tags: []string{"a", "b"}, Created: encodedCreated}
+
+	for accept, mediaType := range map[string]string{
+		"":                              JSONMediaType,
+		"*/*":                           JSONMediaType,
+		"application/xml":               XMLMediaType,
+		"application/*;q=0.5, text/csv": JSONMediaType,
+		"application/json;q=0.1, application/yaml": YAMLMediaType,
+		"application/vnd.app.v2+json":              JSONMediaType,
+		"text/html, application/msgpack":           MessagePackMediaType,
+	} {
+		w := respondWith(accept, item)
+		test.AssertEquals("Media type for '"+accept+"'", mediaType, w.Header().Get("Content-Type"), t)
+		test.AssertEquals("", http.StatusOK, w.Code, t)
+		test.AssertEquals("", "Accept", w.Header().Get("Vary"), t)
+	}
+}
+
+func TestSetResponse_encodes_body(t *testing.T) {
+
+	item := &encodedItem{ID: 1, Name: "gear", Tags: []string{"a", "b"}, Created: encodedCreated}
+
+	yamlBody := respondWith(YAMLMediaType, item).Body.String()
+	test.AssertTrue("Expected YAML with JSON field names: "+yamlBody,
+		strings.Contains(yamlBody, "ID: 1\nname: gear\nTags:\n    - a\n    - b\nCreated: \"2024-01-01T10:00:00Z\"\n"), t)
+
+	decodedMap := map[string]interface{}{}
+	msgpack.Unmarshal(respondWith(MessagePackMediaType, item).Body.Bytes(), &decodedMap)
+	test.AssertEquals("", "gear", decodedMap["name"], t)
+	test.AssertTrue("", encodedCreated.Equal(decodedMap["Created"].(time.Time)), t)
+
+	decoded := &encodedItem{}
+	xml.Unmarshal(respondWith(XMLMediaType, item).Body.Bytes(), decoded)
+	test.AssertEquals("", "gear", decoded.Name, t)
+}
+
+func TestSetResponse_with_csv(t *testing.T) {
+
+	optional := "yes"
+	w := respondWith(CSVMediaType, &PagedResponse{Limit: 2, Payload: []interface{}{
+		&encodedItem{ID: 1, Name: "gear, large", Tags: []string{"a"}, Created: encodedCreated, Optional: &optional},
+		&encodedItem{ID: 2, Name: "cog", Created: encodedCreated},
+	}})
+
+	test.AssertEquals("", CSVMediaType, w.Header().Get("Content-Type"), t)
+	test.AssertEquals("",
+		"ID,name,Tags,Created,Optional\n"+
+			"1,\"gear, large\",\"[\"\"a\"\"]\",2024-01-01T10:00:00Z,yes\n"+
+			"2,cog,,2024-01-01T10:00:00Z,\n",
+		w.Body.String(), t)
+
+	// only lists are encoded as CSV
+	w = respondWith(CSVMediaType, &encodedItem{})
+	test.AssertEquals("", http.StatusNotAcceptable, w.Code, t)
+}
+
+type csvColumn string
+
+func TestSetResponse_with_csv_maps(t *testing.T) {
+
+	w := respondWith(CSVMediaType, []map[csvColumn]interface{}{{"b": 2, "a": "x"}, {"a": "y"}})
+	test.AssertEquals("", "a,b\nx,2\ny,\n", w.Body.String(), t)
+
+	// maps of other keys can't be encoded
+	w = respondWith(CSVMediaType, []map[int]string{{1: "a"}})
+	test.AssertEquals("", http.StatusNotAcceptable, w.Code, t)
+	w = respondWith(CSVMediaType, []interface{}{map[int]string{1: "a"}})
+	test.AssertEquals("", http.StatusInternalServerError, w.Code, t)
+}
+
+func TestSetResponse_not_acceptable(t *testing.T) {
+	w := respondWith("text/html, application/json;q=0", &encodedItem{})
+	errorMsg := &ErrorMessage{}
+	json.Unmarshal(w.Body.Bytes(), errorMsg)
+	test.AssertEquals("", http.StatusNotAcceptable, w.Code, t)
+	test.AssertTrue("Expected supported media types in detail", strings.Contains(errorMsg.Detail, "text/csv"), t)
+}
+
+func TestParseJSONRequest_decodes_content_type(t *testing.T) {
+
+	for contentType, body := range map[string]string{
+		"":                                `{"name":"gear","ID":1}`,
+		"application/json; charset=utf-8": `{"name":"gear","ID":1}`,
+		"application/vnd.app.v2+json":     `{"name":"gear","ID":1}`,
+		"application/xml":                 `<encodedItem><ID>1</ID><Name>gear</Name></encodedItem>`,
+		"application/yaml":                "ID: 1\nname: gear\n",
+	} {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		r.Header.Set("Content-Type", contentType)
+		decoded := &encodedItem{}
+		err := Bootstrap(&ContextIn{}).JSONUtils.ParseJSONRequest(r, decoded, httptest.NewRecorder())
+		test.AssertTrue("Expected body to be decoded for '"+contentType+"'", err == nil, t)
+		test.AssertEquals("", "gear", decoded.Name, t)
+		test.AssertEquals("", int64(1), decoded.ID, t)
+	}
+
+	// msgpack
+	encoded, _ := msgpack.Marshal(map[string]interface{}{"name": "gear"})
+	r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(encoded))
+	r.Header.Set("Content-Type", MessagePackMediaType)
+	decoded := &encodedItem{}
+	Bootstrap(&ContextIn{}).JSONUtils.Unmarshal(r, decoded, httptest.NewRecorder())
+	test.AssertEquals("", "gear", decoded.Name, t)
+}
+
+func TestParseJSONRequest_with_unsupported_content_type(t *testing.T) {
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("name=gear"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+
+	err := Bootstrap(&ContextIn{}).JSONUtils.ParseJSONRequest(r, &encodedItem{}, w)
+
+	test.AssertTrue("Expected error", err != nil, t)
+	test.AssertEquals("", http.StatusUnsupportedMediaType, w.Code, t)
+
+	// malformed
+	r = httptest.NewRequest(http.MethodPost, "/", strings.NewReader("<encodedItem>"))
+	r.Header.Set("Content-Type", XMLMediaType)
+	w = httptest.NewRecorder()
+	Bootstrap(&ContextIn{}).JSONUtils.ParseJSONRequest(r, &encodedItem{}, w)
+	errorMsg := &ErrorMessage{}
+	json.Unmarshal(w.Body.Bytes(), errorMsg)
+	test.AssertEquals("", http.StatusBadRequest, w.Code, t)
+	test.AssertEquals("", "Malformed application/xml body", errorMsg.Detail, t)
+}
+
+type upperCaseEncoder struct{}
+
+func (encoder *upperCaseEncoder) MediaType() string { return "text/plain" }
+
+func (encoder *upperCaseEncoder) Encode(w io.Writer, value interface{}) error {
+	_, err := w.Write([]byte(strings.ToUpper(value.(string))))
+	return err
+}
+
+// refusingEncoder can't encode anything
+type refusingEncoder struct {
+	mediaType string
+}
+
+func (encoder *refusingEncoder) MediaType() string { return encoder.mediaType }
+
+func (encoder *refusingEncoder) CanEncode(value interface{}) bool { return false }
+
+func (encoder *refusingEncoder) Encode(w io.Writer, value interface{}) error {
+	return nil
+}
+
+func TestSetResponse_without_capable_encoders(t *testing.T) {
+	encoders := []Encoder{}
+	for _, mediaType := range []string{JSONMediaType, XMLMediaType, YAMLMediaType, MessagePackMediaType} {
+		encoders = append(encoders, &refusingEncoder{mediaType: mediaType})
+	}
+	w := httptest.NewRecorder()
+	Bootstrap(&ContextIn{Encoders: encoders}).JSONUtils.SetResponse(w, httptest.NewRequest(http.MethodGet, "/", nil), http.StatusOK, "hello")
+	test.AssertEquals("", http.StatusNotAcceptable, w.Code, t)
+}
+
+func TestSetResponse_with_custom_encoder(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept", "text/plain")
+	w := httptest.NewRecorder()
+	Bootstrap(&ContextIn{Encoders: []Encoder{&upperCaseEncoder{}}}).JSONUtils.SetResponse(w, r, http.StatusOK, "hello")
+	test.AssertEquals("", "HELLO", w.Body.String(), t)
+}
@@ -2,8 +2,11 @@ package utils
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/saharsh-samples/go-mux-sql-starter/db"
 )
@@ -36,21 +39,43 @@ type PagedResponse struct {
 // JSONUtils can be used to delegate JSON specific concerns
 type JSONUtils interface {
 
-	// serialization/deserialization
+	// serialization/deserialization. Requests are decoded according to
+	// their Content-Type
 	SetJSONResponse(w http.ResponseWriter, statusCode int, body interface{})
+	SetResponse(w http.ResponseWriter, r *http.Request, statusCode int, body interface{})
 	ParseJSONRequest(r *http.Request, value JSONBody, w http.ResponseWriter) error
 	Unmarshal(r *http.Request, value interface{}, w http.ResponseWriter) error
 
+	// conditional requests. Responses carry ETags if configured, and
+	// conditional GETs are answered with 304 Not Modified
+	CheckPreconditions(w http.ResponseWriter, r *http.Request, etag string, lastModified time.Time) bool
+
 	// error handling support
 	BadRequest(w http.ResponseWriter, detail string)
+	ValidationFailed(w http.ResponseWriter, errors []FieldError)
 	Unauthorized(w http.ResponseWriter, detail string)
 	Forbidden(w http.ResponseWriter, detail string)
 	NotFound(w http.ResponseWriter, detail string)
+	NotAcceptable(w http.ResponseWriter, detail string)
+	Conflict(w http.ResponseWriter, detail string)
+	PreconditionFailed(w http.ResponseWriter, detail string)
+	UnsupportedMediaType(w http.ResponseWriter, detail string)
+	PreconditionRequired(w http.ResponseWriter, detail string)
+	RequestEntityTooLarge(w http.ResponseWriter, detail string)
 	InternalError(w http.ResponseWriter, detail string)
+	ServiceUnavailable(w http.ResponseWriter, detail string)
 	HandleDatabaseError(w http.ResponseWriter, err db.Error)
 }
 
-type jsonUtils struct{}
+type jsonUtils struct {
+	validator          Validator
+	errorFormat        string
+	problemTypeBaseURI string
+	requestIDHeader    string
+	codecs             *codecs
+	etags              string
+	requireIfMatch     bool
+}
 
 //---------------
 // Error messages
@@ -61,49 +86,132 @@ type ErrorMessage struct {
 	StatusCode int
 	Message    string
 	Detail     string
+
+	// Errors of individual fields, when request failed validation
+	Errors []FieldError `json:",omitempty"`
+}
+
+// FieldError describes why a single field of a request is invalid
+type FieldError struct {
+	// Field name. Nested fields are dot separated and array elements
+	// indexed (e.g. "Address.Lines[1]")
+	Field string
+	// In is where field was found: "path", "query", "header" or "body"
+	In string
+	// Code identifies failed rule (e.g. "required" or "max")
+	Code    string
+	Message string
 }
 
 func (jsonUtils *jsonUtils) setErrorResponse(w http.ResponseWriter, errorMsg *ErrorMessage) {
+	jsonUtils.setTypedErrorResponse(w, errorMsg, "")
+}
+
+// setTypedErrorResponse in configured format. Problem type defaults to one
+// derived from status code
+func (jsonUtils *jsonUtils) setTypedErrorResponse(w http.ResponseWriter, errorMsg *ErrorMessage, problemType string) {
 	fmt.Printf("ErrorMessage: '%v'\n", *errorMsg)
+	if jsonUtils.errorFormat == ProblemDetailsFormat {
+		jsonUtils.setProblemResponse(w, errorMsg, problemType)
+		return
+	}
 	jsonUtils.SetJSONResponse(w, errorMsg.StatusCode, errorMsg)
 }
 
 // BadRequest will set response header and body to indicate Bad Request error
 func (jsonUtils *jsonUtils) BadRequest(w http.ResponseWriter, detail string) {
-	jsonUtils.setErrorResponse(w, &ErrorMessage{http.StatusBadRequest, "Bad Request", detail})
+	jsonUtils.setErrorResponse(w, &ErrorMessage{StatusCode: http.StatusBadRequest, Message: "Bad Request", Detail: detail})
+}
+
+// ValidationFailed will set response header and body to indicate Bad Request
+// error listing errors of individual fields
+func (jsonUtils *jsonUtils) ValidationFailed(w http.ResponseWriter, errors []FieldError) {
+	jsonUtils.setTypedErrorResponse(w, &ErrorMessage{
+		StatusCode: http.StatusBadRequest,
+		Message:    "Bad Request",
+		Detail:     "Request failed validation",
+		Errors:     errors,
+	}, validationFailedProblemType)
 }
 
 // Unauthorized will set response header and body to indicate Unauthorized error
 func (jsonUtils *jsonUtils) Unauthorized(w http.ResponseWriter, detail string) {
-	jsonUtils.setErrorResponse(w, &ErrorMessage{http.StatusUnauthorized, "Unauthorized", detail})
+	jsonUtils.setErrorResponse(w, &ErrorMessage{StatusCode: http.StatusUnauthorized, Message: "Unauthorized", Detail: detail})
 }
 
 // Forbidden will set response header and body to indicate Forbidden error
 func (jsonUtils *jsonUtils) Forbidden(w http.ResponseWriter, detail string) {
-	jsonUtils.setErrorResponse(w, &ErrorMessage{http.StatusForbidden, "Forbidden", detail})
+	jsonUtils.setErrorResponse(w, &ErrorMessage{StatusCode: http.StatusForbidden, Message: "Forbidden", Detail: detail})
 }
 
 // NotFound will set response header and body to indicate Not Found error
 func (jsonUtils *jsonUtils) NotFound(w http.ResponseWriter, detail string) {
-	jsonUtils.setErrorResponse(w, &ErrorMessage{http.StatusNotFound, "Not Found", detail})
+	jsonUtils.setErrorResponse(w, &ErrorMessage{StatusCode: http.StatusNotFound, Message: "Not Found", Detail: detail})
+}
+
+// NotAcceptable will set response header and body to indicate Not Acceptable error
+func (jsonUtils *jsonUtils) NotAcceptable(w http.ResponseWriter, detail string) {
+	jsonUtils.setErrorResponse(w, &ErrorMessage{StatusCode: http.StatusNotAcceptable, Message: "Not Acceptable", Detail: detail})
+}
+
+// Conflict will set response header and body to indicate Conflict error
+func (jsonUtils *jsonUtils) Conflict(w http.ResponseWriter, detail string) {
+	jsonUtils.setErrorResponse(w, &ErrorMessage{StatusCode: http.StatusConflict, Message: "Conflict", Detail: detail})
+}
+
+// PreconditionFailed will set response header and body to indicate Precondition Failed error
+func (jsonUtils *jsonUtils) PreconditionFailed(w http.ResponseWriter, detail string) {
+	jsonUtils.setErrorResponse(w, &ErrorMessage{StatusCode: http.StatusPreconditionFailed, Message: "Precondition Failed", Detail: detail})
+}
+
+// UnsupportedMediaType will set response header and body to indicate Unsupported Media Type error
+func (jsonUtils *jsonUtils) UnsupportedMediaType(w http.ResponseWriter, detail string) {
+	jsonUtils.setErrorResponse(w, &ErrorMessage{StatusCode: http.StatusUnsupportedMediaType, Message: "Unsupported Media Type", Detail: detail})
+}
+
+// PreconditionRequired will set response header and body to indicate Precondition Required error
+func (jsonUtils *jsonUtils) PreconditionRequired(w http.ResponseWriter, detail string) {
+	jsonUtils.setErrorResponse(w, &ErrorMessage{StatusCode: http.StatusPreconditionRequired, Message: "Precondition Required", Detail: detail})
+}
+
+// RequestEntityTooLarge will set response header and body to indicate Request Entity Too Large error
+func (jsonUtils *jsonUtils) RequestEntityTooLarge(w http.ResponseWriter, detail string) {
+	jsonUtils.setErrorResponse(w, &ErrorMessage{StatusCode: http.StatusRequestEntityTooLarge, Message: "Request Entity Too Large", Detail: detail})
 }
 
 // InternalError will set response header and body to indicate ISE
 func (jsonUtils *jsonUtils) InternalError(w http.ResponseWriter, detail string) {
-	jsonUtils.setErrorResponse(w, &ErrorMessage{http.StatusInternalServerError, "Internal Server Error", detail})
+	jsonUtils.setErrorResponse(w, &ErrorMessage{StatusCode: http.StatusInternalServerError, Message: "Internal Server Error", Detail: detail})
+}
+
+// ServiceUnavailable will set response header and body to indicate Service Unavailable error
+func (jsonUtils *jsonUtils) ServiceUnavailable(w http.ResponseWriter, detail string) {
+	jsonUtils.setErrorResponse(w, &ErrorMessage{StatusCode: http.StatusServiceUnavailable, Message: "Service Unavailable", Detail: detail})
 }
 
 // HandleDatabaseError cetralizes logic to process database errors
 func (jsonUtils *jsonUtils) HandleDatabaseError(w http.ResponseWriter, err db.Error) {
-	if err.Type() == db.BadRequest {
-		jsonUtils.BadRequest(w, err.Error())
-	} else if err.Type() == db.NotFound {
-		jsonUtils.NotFound(w, err.Error())
-	} else if err.Type() == db.Forbidden {
-		jsonUtils.Forbidden(w, err.Error())
-	} else {
-		jsonUtils.InternalError(w, err.Error())
+	response, found := databaseErrorResponses[err.Type()]
+	if !found {
+		response = databaseErrorResponses[db.GenericError]
 	}
+	jsonUtils.setTypedErrorResponse(w, &ErrorMessage{
+		StatusCode: response.statusCode,
+		Message:    http.StatusText(response.statusCode),
+		Detail:     err.Error(),
+	}, response.problemType)
+}
+
+// databaseErrorResponses by db.Error type
+var databaseErrorResponses = map[string]struct {
+	statusCode  int
+	problemType string
+}{
+	db.BadRequest:   {http.StatusBadRequest, "bad-request"},
+	db.NotFound:     {http.StatusNotFound, "not-found"},
+	db.Forbidden:    {http.StatusForbidden, "forbidden"},
+	db.Conflict:     {http.StatusConflict, "conflict"},
+	db.GenericError: {http.StatusInternalServerError, "internal-error"},
 }
 
 // ----------------------------------
@@ -113,29 +221,69 @@ func (jsonUtils *jsonUtils) HandleDatabaseError(w http.ResponseWriter, err db.Er
 // SetJSONResponse is used to serialize given struct to a JSON object
 func (jsonUtils *jsonUtils) SetJSONResponse(w http.ResponseWriter, statusCode int, body interface{}) {
 
+	jsonUtils.writeJSON(w, JSONMediaType, statusCode, body)
+}
+
+// SetResponse is used to serialize given struct in the media type
+// negotiated with the Accept header of request. Responds with Not
+// Acceptable if body can't be encoded in any acceptable media type
+func (jsonUtils *jsonUtils) SetResponse(w http.ResponseWriter, r *http.Request, statusCode int, body interface{}) {
+
+	w.Header().Add("Vary", "Accept")
+	encoder := jsonUtils.codecs.negotiate(r.Header.Get("Accept"), body)
+	if encoder == nil {
+		jsonUtils.NotAcceptable(w, fmt.Sprintf("Supported media types are [%v]", strings.Join(jsonUtils.codecs.mediaTypes(), ", ")))
+		return
+	}
+	jsonUtils.writeEncoded(w, encoder, statusCode, body)
+}
+
+func (jsonUtils *jsonUtils) writeJSON(w http.ResponseWriter, contentType string, statusCode int, body interface{}) {
+
 	bodyJSON, marshalError := json.Marshal(body)
 	if marshalError != nil {
 		jsonUtils.InternalError(w, marshalError.Error())
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
-	w.Write(bodyJSON)
+	w.Header().Set("Content-Type", contentType)
+	jsonUtils.writeBody(w, statusCode, bodyJSON)
+}
+
+// handleDecodeError distinguishes oversized bodies from malformed ones
+func (jsonUtils *jsonUtils) handleDecodeError(w http.ResponseWriter, err error, mediaType string) {
+	var maxBytesError *http.MaxBytesError
+	if errors.As(err, &maxBytesError) {
+		jsonUtils.RequestEntityTooLarge(w, fmt.Sprintf("Request body must not exceed %d bytes", maxBytesError.Limit))
+		return
+	}
+	jsonUtils.BadRequest(w, malformedBodyMessage(mediaType))
 }
 
-// ParseJSONRequest is used to parse request body as a JSON object
-// the JSON object is deserialized into provided struct
+// ParseJSONRequest is used to parse request body as a JSON object, or
+// any other registered media type, deserialized into provided struct
 func (jsonUtils *jsonUtils) ParseJSONRequest(r *http.Request, value JSONBody, w http.ResponseWriter) error {
 
-	decoder := json.NewDecoder(r.Body)
+	if decodeError := jsonUtils.decode(r, value, w); decodeError != nil {
+		return decodeError
+	}
 
-	unmarshalError := decoder.Decode(value)
-	if unmarshalError != nil {
-		jsonUtils.BadRequest(w, "Malformed JSON body")
-		return unmarshalError
+	// declarative validation first, then custom
+	fieldErrors, tagError := jsonUtils.validator.ValidateStruct(value)
+	if tagError != nil {
+		fmt.Printf("Unable to validate request: %v\n", tagError)
+		jsonUtils.InternalError(w, "Unable to validate request")
+		return tagError
+	}
+	if len(fieldErrors) > 0 {
+		jsonUtils.ValidationFailed(w, fieldErrors)
+		return fieldErrors
 	}
 
 	validationError := value.Validate()
+	if fieldErrors, ok := validationError.(FieldErrors); ok && len(fieldErrors) > 0 {
+		jsonUtils.ValidationFailed(w, fieldErrors)
+		return fieldErrors
+	}
 	if validationError != nil {
 		jsonUtils.BadRequest(w, validationError.Error())
 		return validationError
@@ -148,12 +296,8 @@ func (jsonUtils *jsonUtils) ParseJSONRequest(r *http.Request, value JSONBody, w
 // Unmarshal is a WIP function to allow unmarshalling bodies not compliant yet with JSONBody
 func (jsonUtils *jsonUtils) Unmarshal(r *http.Request, value interface{}, w http.ResponseWriter) error {
 
-	decoder := json.NewDecoder(r.Body)
-
-	unmarshalError := decoder.Decode(value)
-	if unmarshalError != nil {
-		jsonUtils.BadRequest(w, "Malformed JSON body")
-		return unmarshalError
+	if decodeError := jsonUtils.decode(r, value, w); decodeError != nil {
+		return decodeError
 	}
 
 	return nil
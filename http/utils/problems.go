@@ -0,0 +1,139 @@
+package utils
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+)
+
+const (
+
+	// ErrorMessageFormat writes errors as ErrorMessage (default)
+	ErrorMessageFormat = "ErrorMessage"
+
+	// ProblemDetailsFormat writes errors as RFC 7807 ProblemDetails with
+	// 'application/problem+json' content type
+	ProblemDetailsFormat = "ProblemDetails"
+)
+
+// DefaultProblemTypeBaseURI value. Problem types are relative to it
+// (e.g. "/problems/not-found")
+const DefaultProblemTypeBaseURI = "/problems/"
+
+// DefaultRequestIDHeader value
+const DefaultRequestIDHeader = "X-Request-Id"
+
+// ProblemDetails model (RFC 7807)
+type ProblemDetails struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+
+	// extension members
+	RequestID string         `json:"requestId,omitempty"`
+	Errors    []ProblemError `json:"errors,omitempty"`
+}
+
+// ProblemError is a FieldError as extension member of ProblemDetails
+type ProblemError struct {
+	Field   string `json:"field"`
+	In      string `json:"in,omitempty"`
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message"`
+}
+
+// ErrorContext makes instance (request URI) and request ID available to
+// ProblemDetails written for request, and conditional headers to
+// successful responses. The request travels with the response writer, so
+// writers wrapping it in turn must implement Unwrap (like
+// http.ResponseController expects) for details to be found
+func ErrorContext(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(&requestResponseWriter{ResponseWriter: w, request: r}, r)
+	})
+}
+
+// --------
+// Internal
+// --------
+
+// requestResponseWriter carries request being served by ErrorContext
+type requestResponseWriter struct {
+	http.ResponseWriter
+	request *http.Request
+}
+
+func (w *requestResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Flush and Hijack for code asserting interfaces instead of using
+// http.ResponseController, e.g. WebSocket upgraders
+func (w *requestResponseWriter) Flush() {
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *requestResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
+
+// requestOf response writer, if it is or wraps one passed on by ErrorContext
+func requestOf(w http.ResponseWriter) (*http.Request, bool) {
+	for {
+		switch typed := w.(type) {
+		case *requestResponseWriter:
+			return typed.request, true
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = typed.Unwrap()
+		default:
+			return nil, false
+		}
+	}
+}
+
+// problem types by status code
+var problemTypes = map[int]string{
+	http.StatusBadRequest:            "bad-request",
+	http.StatusUnauthorized:          "unauthorized",
+	http.StatusForbidden:             "forbidden",
+	http.StatusNotFound:              "not-found",
+	http.StatusNotAcceptable:         "not-acceptable",
+	http.StatusConflict:              "conflict",
+	http.StatusPreconditionFailed:    "precondition-failed",
+	http.StatusRequestEntityTooLarge: "request-entity-too-large",
+	http.StatusUnsupportedMediaType:  "unsupported-media-type",
+	http.StatusPreconditionRequired:  "precondition-required",
+	http.StatusInternalServerError:   "internal-error",
+	http.StatusServiceUnavailable:    "service-unavailable",
+}
+
+// validationFailedProblemType is used for errors listing field errors
+const validationFailedProblemType = "validation-failed"
+
+func (jsonUtils *jsonUtils) setProblemResponse(w http.ResponseWriter, errorMsg *ErrorMessage, problemType string) {
+
+	if problemType == "" {
+		problemType = problemTypes[errorMsg.StatusCode]
+	}
+
+	problem := &ProblemDetails{
+		Type:   jsonUtils.problemTypeBaseURI + problemType,
+		Title:  errorMsg.Message,
+		Status: errorMsg.StatusCode,
+		Detail: errorMsg.Detail,
+	}
+	if request, found := requestOf(w); found {
+		problem.Instance = request.URL.RequestURI()
+		problem.RequestID = request.Header.Get(jsonUtils.requestIDHeader)
+	}
+	if problem.RequestID == "" {
+		problem.RequestID = w.Header().Get(jsonUtils.requestIDHeader)
+	}
+	for _, fieldError := range errorMsg.Errors {
+		problem.Errors = append(problem.Errors, ProblemError(fieldError))
+	}
+
+	jsonUtils.writeJSON(w, "application/problem+json", problem.Status, problem)
+}
@@ -0,0 +1,161 @@
+package utils
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/saharsh-samples/go-mux-sql-starter/db"
+	"github.com/saharsh-samples/go-mux-sql-starter/test"
+)
+
+func serveProblem(jsonUtils JSONUtils, target string, requestID string, handle func(w http.ResponseWriter)) (*httptest.ResponseRecorder, *ProblemDetails) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, target, nil)
+	if requestID != "" {
+		r.Header.Set(DefaultRequestIDHeader, requestID)
+	}
+	ErrorContext(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { handle(w) })).ServeHTTP(w, r)
+
+	problem := &ProblemDetails{}
+	json.Unmarshal(w.Body.Bytes(), problem)
+	return w, problem
+}
+
+func TestProblemDetails(t *testing.T) {
+
+	// arrange
+	jsonUtils := Bootstrap(&ContextIn{ErrorFormat: ProblemDetailsFormat}).JSONUtils
+
+	// act
+	w, problem := serveProblem(jsonUtils, "/things/1?verbose=true", "req-1", func(w http.ResponseWriter) {
+		jsonUtils.NotFound(w, "Thing 1 not found")
+	})
+
+	// assert
+	test.AssertEquals("", 404, w.Code, t)
+	test.AssertEquals("", "application/problem+json", w.Header().Get("Content-Type"), t)
+	test.AssertEquals("", "/problems/not-found", problem.Type, t)
+	test.AssertEquals("", "Not Found", problem.Title, t)
+	test.AssertEquals("", 404, problem.Status, t)
+	test.AssertEquals("", "Thing 1 not found", problem.Detail, t)
+	test.AssertEquals("", "/things/1?verbose=true", problem.Instance, t)
+	test.AssertEquals("", "req-1", problem.RequestID, t)
+}
+
+func TestProblemDetails_have_types_for_all_error_responses(t *testing.T) {
+
+	// arrange
+	jsonUtils := Bootstrap(&ContextIn{ErrorFormat: ProblemDetailsFormat}).JSONUtils
+	writerType := reflect.TypeOf((*http.ResponseWriter)(nil)).Elem()
+
+	// act and assert
+
+	// error responses are written by methods taking writer and detail
+	methods := reflect.TypeOf((*JSONUtils)(nil)).Elem()
+	for i := 0; i < methods.NumMethod(); i++ {
+		method := methods.Method(i)
+		if method.Type.NumIn() != 2 || method.Type.In(0) != writerType || method.Type.In(1).Kind() != reflect.String {
+			continue
+		}
+		w, problem := serveProblem(jsonUtils, "/things", "", func(w http.ResponseWriter) {
+			reflect.ValueOf(jsonUtils).MethodByName(method.Name).Call([]reflect.Value{reflect.ValueOf(w), reflect.ValueOf("detail")})
+		})
+		test.AssertTrue("Expected problem type for "+method.Name, problem.Type != DefaultProblemTypeBaseURI, t)
+		test.AssertEquals("", w.Code, problem.Status, t)
+	}
+
+	for _, response := range databaseErrorResponses {
+		test.AssertEquals("", problemTypes[response.statusCode], response.problemType, t)
+	}
+}
+
+func TestProblemDetails_with_validation_errors(t *testing.T) {
+
+	// arrange
+	jsonUtils := Bootstrap(&ContextIn{
+		ErrorFormat:        ProblemDetailsFormat,
+		ProblemTypeBaseURI: "https://example.com/problems/",
+	}).JSONUtils
+
+	// act
+	w, problem := serveProblem(jsonUtils, "/things", "", func(w http.ResponseWriter) {
+		w.Header().Set(DefaultRequestIDHeader, "generated")
+		jsonUtils.ValidationFailed(w, []FieldError{{Field: "Name", In: "body", Code: "required", Message: "is required"}})
+	})
+
+	// assert
+	test.AssertEquals("", 400, w.Code, t)
+	test.AssertEquals("", "https://example.com/problems/validation-failed", problem.Type, t)
+	test.AssertEquals("", "generated", problem.RequestID, t)
+	test.AssertEquals("", 1, len(problem.Errors), t)
+	test.AssertEquals("", ProblemError{Field: "Name", In: "body", Code: "required", Message: "is required"}, problem.Errors[0], t)
+
+	raw := make(map[string]interface{})
+	json.Unmarshal(w.Body.Bytes(), &raw)
+	test.AssertTrue("Expected lower case extension members", raw["errors"] != nil && raw["requestId"] != nil, t)
+}
+
+func TestProblemDetails_for_database_errors(t *testing.T) {
+
+	jsonUtils := Bootstrap(&ContextIn{ErrorFormat: ProblemDetailsFormat}).JSONUtils
+	expectations := map[db.Error]string{
+		db.NewNotFoundError("missing"):   "/problems/not-found",
+		db.NewBadRequestError("bad"):     "/problems/bad-request",
+		db.NewForbiddenError("nope"):     "/problems/forbidden",
+		db.NewConflictError("stale"):     "/problems/conflict",
+		db.NewGenericError("connection"): "/problems/internal-error",
+	}
+
+	for err, expectedType := range expectations {
+		_, problem := serveProblem(jsonUtils, "/", "", func(w http.ResponseWriter) { jsonUtils.HandleDatabaseError(w, err) })
+		test.AssertEquals("", expectedType, problem.Type, t)
+		test.AssertEquals("", err.Error(), problem.Detail, t)
+	}
+
+	_, problem := serveProblem(jsonUtils, "/", "", func(w http.ResponseWriter) { jsonUtils.HandleDatabaseError(w, db.NewConflictError("stale")) })
+	test.AssertEquals("", http.StatusConflict, problem.Status, t)
+}
+
+func TestErrorMessage_is_default_format(t *testing.T) {
+
+	jsonUtils := Bootstrap(nil).JSONUtils
+	w, _ := serveProblem(jsonUtils, "/", "", func(w http.ResponseWriter) { jsonUtils.Forbidden(w, "nope") })
+
+	errorMsg := &ErrorMessage{}
+	json.Unmarshal(w.Body.Bytes(), errorMsg)
+	test.AssertEquals("", "application/json", w.Header().Get("Content-Type"), t)
+	test.AssertEquals("", 403, errorMsg.StatusCode, t)
+	test.AssertEquals("", "Forbidden", errorMsg.Message, t)
+	test.AssertEquals("", "nope", errorMsg.Detail, t)
+}
+
+// wrappingWriter stands in for middleware wrapping response writers
+type wrappingWriter struct {
+	http.ResponseWriter
+}
+
+func (w *wrappingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func TestProblemDetails_through_wrapped_writer(t *testing.T) {
+
+	// arrange
+	jsonUtils := Bootstrap(&ContextIn{ErrorFormat: ProblemDetailsFormat}).JSONUtils
+
+	// act
+	_, problem := serveProblem(jsonUtils, "/things/1", "req-1", func(w http.ResponseWriter) {
+		jsonUtils.NotFound(&wrappingWriter{w}, "Thing 1 not found")
+	})
+	_, unserved := serveProblem(jsonUtils, "/things/1", "", func(w http.ResponseWriter) {
+		jsonUtils.NotFound(httptest.NewRecorder(), "Thing 1 not found")
+	})
+
+	// assert
+	test.AssertEquals("", "/things/1", problem.Instance, t)
+	test.AssertEquals("", "req-1", problem.RequestID, t)
+	test.AssertEquals("Expected no instance for other writers", "", unserved.Instance, t)
+}
@@ -0,0 +1,221 @@
+package utils
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// NDJSONMediaType is newline delimited JSON, one value per line
+const NDJSONMediaType = "application/x-ndjson"
+
+// StreamFlushInterval is the number of items written between flushes
+const StreamFlushInterval = 100
+
+// Iterator returns next item of a stream. Returns false once there are no
+// more items
+type Iterator func() (item interface{}, more bool, err error)
+
+// RowScanner scans current row of rows into an item
+type RowScanner func(rows *sql.Rows) (interface{}, error)
+
+// Streamer writes large results incrementally as NDJSON (default) or CSV,
+// as negotiated with the Accept header, instead of building them in
+// memory. Streams aren't subject to the server's write timeout and stop
+// when the client disconnects. Errors after the first item can't change
+// the response status anymore, so they only end the stream
+type Streamer interface {
+
+	// Stream items until iterator has no more items
+	Stream(w http.ResponseWriter, r *http.Request, items Iterator) error
+
+	// StreamRows scanned by scan, closing rows when done. Rows should be
+	// queried with the request context (see db.Connection.QueryContext) so
+	// queries are cancelled when clients disconnect
+	StreamRows(w http.ResponseWriter, r *http.Request, rows *sql.Rows, scan RowScanner) error
+}
+
+// --------
+// Internal
+// --------
+
+type streamer struct {
+	jsonUtils *jsonUtils
+}
+
+// streamEncoder writes items of a stream
+type streamEncoder interface {
+
+	// check reports items that can't be encoded, so the first item can be
+	// rejected before the response is committed
+	check(item interface{}) error
+	encode(item interface{}) error
+	flush() error
+}
+
+func (s *streamer) StreamRows(w http.ResponseWriter, r *http.Request, rows *sql.Rows, scan RowScanner) error {
+	defer rows.Close()
+	return s.Stream(w, r, func() (interface{}, bool, error) {
+		if !rows.Next() {
+			return nil, false, rows.Err()
+		}
+		item, err := scan(rows)
+		return item, err == nil, err
+	})
+}
+
+func (s *streamer) Stream(w http.ResponseWriter, r *http.Request, items Iterator) error {
+
+	mediaType := negotiateStream(r.Header.Get("Accept"))
+	if mediaType == "" {
+		s.jsonUtils.NotAcceptable(w, fmt.Sprintf("Supported media types are [%v, %v]", NDJSONMediaType, CSVMediaType))
+		return fmt.Errorf("Not acceptable: '%v'", r.Header.Get("Accept"))
+	}
+
+	// streams take as long as they take
+	controller := http.NewResponseController(w)
+	controller.SetWriteDeadline(time.Time{})
+
+	var encoder streamEncoder
+	if mediaType == CSVMediaType {
+		encoder = &csvStreamEncoder{writer: csv.NewWriter(w)}
+	} else {
+		encoder = &ndjsonStreamEncoder{encoder: json.NewEncoder(w)}
+	}
+
+	for written := 0; ; written++ {
+
+		if err := r.Context().Err(); err != nil {
+			return err
+		}
+
+		item, more, err := items()
+		if err != nil {
+			if written == 0 {
+				s.jsonUtils.InternalError(w, err.Error())
+			}
+			return err
+		}
+		if written == 0 && more {
+			if err := encoder.check(item); err != nil {
+				fmt.Printf("Unable to stream response: %v\n", err)
+				s.jsonUtils.InternalError(w, "Unable to encode items")
+				return err
+			}
+		}
+		if written == 0 {
+			w.Header().Set("Content-Type", mediaType)
+			w.Header().Add("Vary", "Accept")
+			w.WriteHeader(http.StatusOK)
+		}
+		if !more {
+			break
+		}
+
+		if err := encoder.encode(item); err != nil {
+			return err
+		}
+		if (written+1)%StreamFlushInterval == 0 {
+			if err := flushStream(encoder, controller); err != nil {
+				return err
+			}
+		}
+	}
+
+	return flushStream(encoder, controller)
+}
+
+// negotiateStream media type for Accept header, or empty string if
+// neither NDJSON nor CSV is acceptable
+func negotiateStream(accept string) string {
+	if strings.TrimSpace(accept) == "" {
+		return NDJSONMediaType
+	}
+	for _, mediaRange := range parseAccept(accept) {
+		for _, mediaType := range []string{NDJSONMediaType, CSVMediaType} {
+			if mediaRange.matches(mediaType) {
+				return mediaType
+			}
+		}
+	}
+	return ""
+}
+
+func flushStream(encoder streamEncoder, controller *http.ResponseController) error {
+	if err := encoder.flush(); err != nil {
+		return err
+	}
+	// not all response writers can flush, which is fine
+	if err := controller.Flush(); err != nil && err != http.ErrNotSupported {
+		return err
+	}
+	return nil
+}
+
+type ndjsonStreamEncoder struct {
+	encoder *json.Encoder
+}
+
+func (e *ndjsonStreamEncoder) check(item interface{}) error {
+	return nil
+}
+
+// encode appends a newline after each value
+func (e *ndjsonStreamEncoder) encode(item interface{}) error {
+	return e.encoder.Encode(item)
+}
+
+func (e *ndjsonStreamEncoder) flush() error {
+	return nil
+}
+
+// csvStreamEncoder writes header for first item, which determines the
+// columns of all rows
+type csvStreamEncoder struct {
+	writer *csv.Writer
+	header []string
+}
+
+func (e *csvStreamEncoder) check(item interface{}) error {
+	if value := indirect(reflect.ValueOf(item)); value.Kind() == reflect.Map {
+		return csvCheckMap(value)
+	}
+	return nil
+}
+
+func (e *csvStreamEncoder) encode(item interface{}) error {
+
+	if err := e.check(item); err != nil {
+		return err
+	}
+
+	value := indirect(reflect.ValueOf(item))
+	switch value.Kind() {
+
+	case reflect.Struct:
+		if e.header == nil {
+			e.header = csvStructHeader(value.Type())
+			e.writer.Write(e.header)
+		}
+		return e.writer.Write(csvStructRow(value))
+
+	case reflect.Map:
+		if e.header == nil {
+			e.header = csvMapHeader(value)
+			e.writer.Write(e.header)
+		}
+		return e.writer.Write(csvMapRow(value, e.header))
+	}
+
+	return e.writer.Write([]string{csvCell(value)})
+}
+
+func (e *csvStreamEncoder) flush() error {
+	e.writer.Flush()
+	return e.writer.Error()
+}
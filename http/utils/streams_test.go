@@ -0,0 +1,152 @@
+package utils
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	dbTest "github.com/saharsh-samples/go-mux-sql-starter/db/test"
+	"github.com/saharsh-samples/go-mux-sql-starter/test"
+)
+
+type streamedItem struct {
+	ID   int64
+	Name string
+}
+
+func itemsOf(items ...interface{}) Iterator {
+	return func() (interface{}, bool, error) {
+		if len(items) == 0 {
+			return nil, false, nil
+		}
+		item := items[0]
+		items = items[1:]
+		return item, true, nil
+	}
+}
+
+func TestStreamRows(t *testing.T) {
+
+	// Arrange
+	database, mock, closer := dbTest.NewDatabaseWithMockConnection(t)
+	defer closer()
+	mock.ExpectQuery("SELECT id, name FROM items").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "gear").AddRow(2, "cog"))
+	r := httptest.NewRequest(http.MethodGet, "/items/export", nil)
+	w := httptest.NewRecorder()
+
+	// Act
+	rows, _ := database.GetConnection().QueryContext(r.Context(), "SELECT id, name FROM items")
+	err := Bootstrap(nil).Streamer.StreamRows(w, r, rows, func(rows *sql.Rows) (interface{}, error) {
+		item := &streamedItem{}
+		return item, rows.Scan(&item.ID, &item.Name)
+	})
+
+	// Assert
+	test.AssertTrue("Expected stream to succeed", err == nil, t)
+	test.AssertEquals("", NDJSONMediaType, w.Header().Get("Content-Type"), t)
+	test.AssertEquals("", "{\"ID\":1,\"Name\":\"gear\"}\n{\"ID\":2,\"Name\":\"cog\"}\n", w.Body.String(), t)
+	test.AssertTrue("Expected all db expectations to be met", mock.ExpectationsWereMet() == nil, t)
+}
+
+func TestStream_csv(t *testing.T) {
+
+	r := httptest.NewRequest(http.MethodGet, "/items/export", nil)
+	r.Header.Set("Accept", "text/csv")
+	w := httptest.NewRecorder()
+
+	err := Bootstrap(nil).Streamer.Stream(w, r, itemsOf(&streamedItem{1, "gear"}, streamedItem{2, "cog, small"}))
+
+	test.AssertTrue("Expected stream to succeed", err == nil, t)
+	test.AssertEquals("", CSVMediaType, w.Header().Get("Content-Type"), t)
+	test.AssertEquals("", "ID,Name\n1,gear\n2,\"cog, small\"\n", w.Body.String(), t)
+}
+
+func TestStream_csv_maps(t *testing.T) {
+
+	r := httptest.NewRequest(http.MethodGet, "/items/export", nil)
+	r.Header.Set("Accept", "text/csv")
+	w := httptest.NewRecorder()
+
+	err := Bootstrap(nil).Streamer.Stream(w, r, itemsOf(map[csvColumn]int{"b": 2, "a": 1}))
+
+	test.AssertTrue("Expected stream to succeed", err == nil, t)
+	test.AssertEquals("", "a,b\n1,2\n", w.Body.String(), t)
+
+	// maps of other keys are rejected before anything is written
+	w = httptest.NewRecorder()
+	err = Bootstrap(nil).Streamer.Stream(w, r, itemsOf(map[int]string{1: "a"}))
+	test.AssertTrue("Expected error", err != nil, t)
+	test.AssertEquals("", http.StatusInternalServerError, w.Code, t)
+}
+
+func TestStream_with_errors(t *testing.T) {
+
+	// not acceptable
+	r := httptest.NewRequest(http.MethodGet, "/items/export", nil)
+	r.Header.Set("Accept", "application/xml")
+	w := httptest.NewRecorder()
+	err := Bootstrap(nil).Streamer.Stream(w, r, itemsOf())
+	test.AssertTrue("Expected error", err != nil, t)
+	test.AssertEquals("", http.StatusNotAcceptable, w.Code, t)
+
+	// failure before first item
+	w = httptest.NewRecorder()
+	err = Bootstrap(nil).Streamer.Stream(w, httptest.NewRequest(http.MethodGet, "/items/export", nil), func() (interface{}, bool, error) {
+		return nil, false, errors.New("boom")
+	})
+	test.AssertTrue("Expected error", err != nil, t)
+	test.AssertEquals("", http.StatusInternalServerError, w.Code, t)
+
+	// client disconnected
+	ctx, cancel := context.WithCancel(context.Background())
+	r = httptest.NewRequest(http.MethodGet, "/items/export", nil).WithContext(ctx)
+	w = httptest.NewRecorder()
+	count := 0
+	err = Bootstrap(nil).Streamer.Stream(w, r, func() (interface{}, bool, error) {
+		count++
+		if count == 2 {
+			cancel()
+		}
+		return &streamedItem{ID: int64(count)}, true, nil
+	})
+	test.AssertEquals("", context.Canceled, err, t)
+	test.AssertEquals("Expected stream to stop", 2, count, t)
+}
+
+func TestStream_is_exempt_from_write_timeout(t *testing.T) {
+
+	// Arrange
+	streamer := Bootstrap(nil).Streamer
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count := 0
+		streamer.Stream(w, r, func() (interface{}, bool, error) {
+			count++
+			time.Sleep(20 * time.Millisecond)
+			return &streamedItem{ID: int64(count)}, count <= 10, nil
+		})
+	}))
+	server.Config.WriteTimeout = 50 * time.Millisecond
+	server.Start()
+	defer server.Close()
+
+	// Act
+	response, err := http.Get(server.URL)
+	test.AssertTrue("Expected request to succeed", err == nil, t)
+	if err != nil {
+		return
+	}
+	body, err := io.ReadAll(response.Body)
+	response.Body.Close()
+
+	// Assert
+	test.AssertTrue("Expected complete body", err == nil, t)
+	test.AssertEquals("", 10, strings.Count(string(body), "\n"), t)
+}
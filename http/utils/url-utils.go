@@ -7,8 +7,21 @@ import (
 	"strings"
 
 	"github.com/gorilla/mux"
+	"github.com/saharsh-samples/go-mux-sql-starter/db"
 )
 
+// FilterQueryParameter can be repeated to filter lists, e.g.
+// '?filter=status:eq:active&filter=created_at:gte:2024-01-01'
+const FilterQueryParameter = "filter"
+
+// SortQueryParameter sorts lists by comma separated fields, descending
+// if prefixed with '-', e.g. '?sort=-created_at,name'
+const SortQueryParameter = "sort"
+
+// IncludeDeletedQueryParameter includes soft deleted rows in lists and
+// lookups, e.g. '?includeDeleted=true'. Usually only allowed for admins
+const IncludeDeletedQueryParameter = "includeDeleted"
+
 // URLUtils can be used to extract information from URLs
 type URLUtils interface {
 	GetPathParams(r *http.Request) map[string]string
@@ -16,6 +29,8 @@ type URLUtils interface {
 	GetQueryParameterAsInteger(r *http.Request, queryParamName string, defaultValue *int) (int, error)
 	GetQueryParameterAsBool(r *http.Request, queryParamName string, defaultValue *bool) (bool, error)
 	GetQueryParameterAsArrayOfString(r *http.Request, queryParamName string, minimumRequired int) ([]string, error)
+	GetListQuery(r *http.Request, fields db.ListFields) (*db.ListQuery, error)
+	GetIncludeDeleted(r *http.Request, allowed bool) (bool, error)
 }
 
 type urlUtils struct{}
@@ -99,3 +114,37 @@ func (urlUtils *urlUtils) GetQueryParameterAsArrayOfString(r *http.Request, quer
 
 	return values, nil
 }
+
+// GetListQuery parses 'filter' and 'sort' query params. Fields must be in
+// the allowlist of the resource
+func (urlUtils *urlUtils) GetListQuery(r *http.Request, fields db.ListFields) (*db.ListQuery, error) {
+
+	defaultSort := ""
+	sortBy, err := urlUtils.GetQueryParameterAsString(r, SortQueryParameter, &defaultSort)
+	if err != nil {
+		return nil, err
+	}
+
+	query, parseErr := db.ParseListQuery(fields, r.URL.Query()[FilterQueryParameter], sortBy)
+	if parseErr != nil {
+		return nil, parseErr
+	}
+
+	return query, nil
+}
+
+// GetIncludeDeleted parses 'includeDeleted' query param. Fails with a
+// Forbidden db.Error if it's 'true' but not allowed (e.g. for non-admins)
+func (urlUtils *urlUtils) GetIncludeDeleted(r *http.Request, allowed bool) (bool, error) {
+
+	defaultValue := false
+	includeDeleted, err := urlUtils.GetQueryParameterAsBool(r, IncludeDeletedQueryParameter, &defaultValue)
+	if err != nil {
+		return false, err
+	}
+
+	if includeDeleted && !allowed {
+		return false, db.NewForbiddenError(fmt.Sprintf("Not allowed to use '%v' Query Parameter", IncludeDeletedQueryParameter))
+	}
+	return includeDeleted, nil
+}
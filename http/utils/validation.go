@@ -0,0 +1,388 @@
+package utils
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	commonUtils "github.com/saharsh-samples/go-mux-sql-starter/utils"
+)
+
+// ValidationRule checks value of a field tagged with rule. Param is the text
+// after '=' in the tag (e.g. "255" for 'max=255'). Returns message describing
+// why value is invalid, or empty string if value is valid
+type ValidationRule func(value reflect.Value, param string) string
+
+// Validator validates structs using 'validate' field tags, e.g.
+//
+//	Email string `validate:"required,email,max=255"`
+//
+// Nested structs and elements of slices, arrays and maps are validated too.
+// Built-in rules are required, omitempty, email, min, max, len and oneof.
+// Tags are parsed once per type. Unknown rules and non numeric parameters
+// of min, max and len are reported as error instead of field errors
+type Validator interface {
+	ValidateStruct(value interface{}) (FieldErrors, error)
+}
+
+// FieldErrors can be returned by JSONBody.Validate to report errors of
+// individual fields
+type FieldErrors []FieldError
+
+func (errs FieldErrors) Error() string {
+	described := make([]string, len(errs))
+	for i, err := range errs {
+		described[i] = strings.TrimPrefix(err.Field+" "+err.Message, " ")
+	}
+	return strings.Join(described, "; ")
+}
+
+// --------
+// Internal
+// --------
+
+type validator struct {
+	rules map[string]ValidationRule
+
+	// parsed tags by struct type
+	mutex sync.Mutex
+	types map[reflect.Type]*structRules
+}
+
+// structRules are the parsed 'validate' tags of a struct type
+type structRules struct {
+	fields []*fieldRules
+	err    error
+}
+
+type fieldRules struct {
+	index     int
+	embedded  bool
+	in        string
+	name      string
+	bound     bool
+	omitEmpty bool
+	checks    []*fieldCheck
+}
+
+type fieldCheck struct {
+	name  string
+	param string
+	rule  ValidationRule
+}
+
+// rules whose parameter must be a number
+var numericParamRules = map[string]bool{"min": true, "max": true, "len": true}
+
+func newValidator(customRules map[string]ValidationRule) *validator {
+	rules := map[string]ValidationRule{
+		"required": validateRequired,
+		"email":    validateEmail,
+		"min":      validateMin,
+		"max":      validateMax,
+		"len":      validateLen,
+		"oneof":    validateOneOf,
+	}
+	for name, rule := range customRules {
+		rules[name] = rule
+	}
+	return &validator{rules: rules, types: make(map[reflect.Type]*structRules)}
+}
+
+func (v *validator) ValidateStruct(value interface{}) (FieldErrors, error) {
+
+	if value == nil {
+		return nil, nil
+	}
+	v.mutex.Lock()
+	err := v.parseType(reflect.TypeOf(value))
+	v.mutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	var errs FieldErrors
+	if err := v.validateValue(reflect.ValueOf(value), "", &errs); err != nil {
+		return nil, err
+	}
+	return errs, nil
+}
+
+// parseType parses tags of type and of all types nested in it. Must be
+// called with mutex held
+func (v *validator) parseType(t reflect.Type) error {
+
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	if parsed, found := v.types[t]; found {
+		return parsed.err
+	}
+
+	// registered before nested types are parsed to guard against recursion
+	parsed := &structRules{}
+	v.types[t] = parsed
+
+	for i := 0; i < t.NumField(); i++ {
+		structField := t.Field(i)
+		field := &fieldRules{index: i}
+
+		if structField.Anonymous && structField.Type.Kind() == reflect.Struct {
+			field.embedded = true
+		} else if structField.PkgPath != "" {
+			continue
+		} else if paramIn, paramTag, found := parameterTag(structField); found {
+			// fields bound from parameters are reported by parameter name
+			field.in, field.name, field.bound = paramIn, strings.Split(paramTag, ",")[0], true
+		} else if field.name = jsonName(structField); field.name == "-" {
+			continue
+		} else {
+			field.in = "body"
+		}
+
+		if tag := structField.Tag.Get("validate"); !field.embedded && tag != "" && tag != "-" {
+			if err := v.parseTag(field, tag); err != nil {
+				parsed.err = fmt.Errorf("Invalid 'validate' tag of field '%v' of %v: %v", structField.Name, t, err)
+				return parsed.err
+			}
+		}
+		if err := v.parseType(structField.Type); err != nil {
+			parsed.err = err
+			return err
+		}
+		parsed.fields = append(parsed.fields, field)
+	}
+
+	return nil
+}
+
+func (v *validator) parseTag(field *fieldRules, tag string) error {
+	for _, rule := range strings.Split(tag, ",") {
+		name, param := rule, ""
+		if equals := strings.Index(rule, "="); equals >= 0 {
+			name, param = rule[:equals], rule[equals+1:]
+		}
+		if name == "omitempty" {
+			field.omitEmpty = true
+			continue
+		}
+		check, found := v.rules[name]
+		if !found {
+			return fmt.Errorf("unknown validation rule '%v'", name)
+		}
+		if _, err := strconv.ParseFloat(param, 64); err != nil && numericParamRules[name] {
+			return fmt.Errorf("parameter '%v' of rule '%v' is not a number", param, name)
+		}
+		field.checks = append(field.checks, &fieldCheck{name: name, param: param, rule: check})
+	}
+	return nil
+}
+
+// validateValue descends into structs and collections. Types of values
+// held by interfaces are only known now, so their tags may be parsed here
+func (v *validator) validateValue(value reflect.Value, field string, errs *FieldErrors) error {
+
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+
+	switch value.Kind() {
+
+	case reflect.Struct:
+		v.mutex.Lock()
+		parseError := v.parseType(value.Type())
+		parsed := v.types[value.Type()]
+		v.mutex.Unlock()
+		if parseError != nil {
+			return parseError
+		}
+		for _, rules := range parsed.fields {
+			fieldValue := value.Field(rules.index)
+			name := field
+			if !rules.embedded {
+				name = rules.name
+				if !rules.bound {
+					name = joinFieldName(field, rules.name)
+				}
+				if !validateField(fieldValue, rules, name, errs) {
+					continue
+				}
+			}
+			if err := v.validateValue(fieldValue, name, errs); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			if err := v.validateValue(value.Index(i), fmt.Sprintf("%v[%d]", field, i), errs); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Map:
+		for _, key := range value.MapKeys() {
+			if err := v.validateValue(value.MapIndex(key), joinFieldName(field, fmt.Sprint(key.Interface())), errs); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateField against its parsed rules. Returns false if field failed
+// validation or is empty and optional, so nested values needn't be checked
+func validateField(value reflect.Value, rules *fieldRules, field string, errs *FieldErrors) bool {
+
+	if rules.omitEmpty && isEmptyValue(value) {
+		return false
+	}
+
+	for _, check := range rules.checks {
+
+		// only 'required' applies to nil values
+		if check.name != "required" && isNilValue(value) {
+			continue
+		}
+		if message := check.rule(indirect(value), check.param); message != "" {
+			*errs = append(*errs, FieldError{Field: field, In: rules.in, Code: check.name, Message: message})
+			return false
+		}
+	}
+
+	return true
+}
+
+// -----
+// Rules
+// -----
+
+func validateRequired(value reflect.Value, _ string) string {
+	if isNilValue(value) {
+		return "is required"
+	}
+	value = indirect(value)
+	if value.Kind() == reflect.String && !commonUtils.IsNonEmptyString(value.String()) {
+		return "is required"
+	}
+	if value.Kind() != reflect.String && value.Kind() != reflect.Bool && value.IsZero() {
+		return "is required"
+	}
+	return ""
+}
+
+func validateEmail(value reflect.Value, _ string) string {
+	if value.Kind() != reflect.String || !commonUtils.IsStringValidEmail(value.String()) {
+		return "must be a valid email address"
+	}
+	return ""
+}
+
+func validateMin(value reflect.Value, param string) string {
+	return compareSize(value, param, func(size float64, limit float64) bool { return size >= limit }, "at least")
+}
+
+func validateMax(value reflect.Value, param string) string {
+	return compareSize(value, param, func(size float64, limit float64) bool { return size <= limit }, "at most")
+}
+
+func validateLen(value reflect.Value, param string) string {
+	return compareSize(value, param, func(size float64, limit float64) bool { return size == limit }, "exactly")
+}
+
+func validateOneOf(value reflect.Value, param string) string {
+	allowed := strings.Fields(param)
+	if commonUtils.IsStringMissingInSlice(fmt.Sprint(value.Interface()), allowed) {
+		return fmt.Sprintf("must be one of [%v]", strings.Join(allowed, ", "))
+	}
+	return ""
+}
+
+// compareSize compares length of strings and collections, or value of
+// numbers, with limit in param
+func compareSize(value reflect.Value, param string, ok func(float64, float64) bool, relation string) string {
+
+	// parameter was checked when tag was parsed
+	limit, _ := strconv.ParseFloat(param, 64)
+
+	switch value.Kind() {
+	case reflect.String:
+		if !ok(float64(utf8.RuneCountInString(value.String())), limit) {
+			return fmt.Sprintf("must be %v %v characters long", relation, param)
+		}
+	case reflect.Slice, reflect.Array, reflect.Map:
+		if !ok(float64(value.Len()), limit) {
+			return fmt.Sprintf("must have %v %v items", relation, param)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if !ok(float64(value.Int()), limit) {
+			return fmt.Sprintf("must be %v %v", relation, param)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if !ok(float64(value.Uint()), limit) {
+			return fmt.Sprintf("must be %v %v", relation, param)
+		}
+	case reflect.Float32, reflect.Float64:
+		if !ok(value.Float(), limit) {
+			return fmt.Sprintf("must be %v %v", relation, param)
+		}
+	}
+	return ""
+}
+
+// -------
+// Helpers
+// -------
+
+func isNilValue(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map:
+		return value.IsNil()
+	}
+	return !value.IsValid()
+}
+
+func isEmptyValue(value reflect.Value) bool {
+	if isNilValue(value) {
+		return true
+	}
+	value = indirect(value)
+	switch value.Kind() {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		return value.Len() == 0
+	}
+	return value.IsZero()
+}
+
+func indirect(value reflect.Value) reflect.Value {
+	for (value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface) && !value.IsNil() {
+		value = value.Elem()
+	}
+	return value
+}
+
+func jsonName(field reflect.StructField) string {
+	if tag, found := field.Tag.Lookup("json"); found {
+		if name := strings.Split(tag, ",")[0]; name != "" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+func joinFieldName(parent string, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
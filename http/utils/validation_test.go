@@ -0,0 +1,156 @@
+package utils
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/saharsh-samples/go-mux-sql-starter/test"
+)
+
+type validatedContact struct {
+	Kind  string `validate:"required,oneof=home work"`
+	Value string `validate:"required,max=8"`
+}
+
+type validatedUser struct {
+	Email    string             `validate:"required,email,max=255"`
+	Nickname string             `json:"nick" validate:"omitempty,min=3"`
+	Age      int                `validate:"min=18,max=150"`
+	Tags     []string           `validate:"max=2"`
+	Manager  *validatedContact  `validate:"omitempty"`
+	Contacts []validatedContact `validate:"required,min=1"`
+	Code     string             `validate:"even"`
+	internal string             `validate:"required"`
+}
+
+func (user *validatedUser) Validate() error {
+	if user.Age == 99 {
+		return FieldErrors{{Field: "Age", In: "body", Code: "custom", Message: "must not be 99"}}
+	}
+	if user.Age == 98 {
+		return fmt.Errorf("Age must not be 98")
+	}
+	return nil
+}
+
+func evenLength(value reflect.Value, _ string) string {
+	if value.Len()%2 != 0 {
+		return "must have even length"
+	}
+	return ""
+}
+
+func describe(errs []FieldError) string {
+	described := make([]string, len(errs))
+	for i, err := range errs {
+		described[i] = err.Field + ":" + err.Code
+	}
+	return strings.Join(described, ",")
+}
+
+func TestValidator_ValidateStruct(t *testing.T) {
+
+	// arrange
+	validator := Bootstrap(&ContextIn{ValidationRules: map[string]ValidationRule{"even": evenLength}}).Validator
+
+	// act and assert
+	valid := &validatedUser{
+		Email:    "a@example.com",
+		Age:      30,
+		Contacts: []validatedContact{{Kind: "home", Value: "555"}},
+		Code:     "ab",
+	}
+	errs, err := validator.ValidateStruct(valid)
+	test.AssertTrue("Expected no error", err == nil, t)
+	test.AssertEquals("", 0, len(errs), t)
+
+	invalid := &validatedUser{
+		Email:    "not-an-email",
+		Nickname: "ab",
+		Age:      12,
+		Tags:     []string{"a", "b", "c"},
+		Manager:  &validatedContact{Kind: "cell", Value: "123456789"},
+		Contacts: []validatedContact{{Kind: "home", Value: "555"}, {Kind: " "}},
+		Code:     "abc",
+	}
+	errs, _ = validator.ValidateStruct(invalid)
+	test.AssertEquals("",
+		"Email:email,nick:min,Age:min,Tags:max,Manager.Kind:oneof,Manager.Value:max,Contacts[1].Kind:required,Contacts[1].Value:required,Code:even",
+		describe(errs), t)
+	test.AssertEquals("", "must be one of [home, work]", errs[4].Message, t)
+	test.AssertEquals("", "body", errs[0].In, t)
+	test.AssertTrue("", strings.HasPrefix(errs.Error(), "Email must be a valid email address; nick must be at least 3 characters long"), t)
+
+	missing, _ := validator.ValidateStruct(&validatedUser{Age: 18, Code: ""})
+	test.AssertEquals("", "Email:required,Contacts:required", describe(missing), t)
+}
+
+func TestValidator_with_unknown_rule(t *testing.T) {
+
+	_, err := Bootstrap(&ContextIn{}).Validator.ValidateStruct(&validatedUser{})
+
+	test.AssertTrue("Expected error for unknown rule", err != nil, t)
+	test.AssertTrue("", strings.Contains(err.Error(), "unknown validation rule 'even'"), t)
+}
+
+type badlyTaggedThing struct {
+	Name string `validate:"max=ten"`
+}
+
+func (thing *badlyTaggedThing) Validate() error {
+	return nil
+}
+
+func TestValidator_with_non_numeric_parameter(t *testing.T) {
+
+	jsonUtils := Bootstrap(&ContextIn{}).JSONUtils
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/things", strings.NewReader(`{"Name": "thing"}`))
+
+	err := jsonUtils.ParseJSONRequest(r, &badlyTaggedThing{}, w)
+
+	test.AssertTrue("", strings.Contains(err.Error(), "parameter 'ten' of rule 'max' is not a number"), t)
+	test.AssertEquals("", 500, w.Code, t)
+	test.AssertTrue("Expected generic detail", strings.Contains(w.Body.String(), "Unable to validate request"), t)
+}
+
+func TestParseJSONRequest_with_field_errors(t *testing.T) {
+
+	jsonUtils := Bootstrap(&ContextIn{ValidationRules: map[string]ValidationRule{"even": evenLength}}).JSONUtils
+	parse := func(body string) (*httptest.ResponseRecorder, error) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(body))
+		return w, jsonUtils.ParseJSONRequest(r, &validatedUser{}, w)
+	}
+
+	// declarative validation
+	w, err := parse(`{"Email": "bad", "Age": 30, "Contacts": [{"Kind": "work", "Value": "1"}]}`)
+	test.AssertTrue("", err != nil, t)
+	test.AssertEquals("", 400, w.Code, t)
+	errorMsg := &ErrorMessage{}
+	Bootstrap(&ContextIn{}).JSONUtils.Unmarshal(httptest.NewRequest(http.MethodGet, "/", w.Body), errorMsg, w)
+	test.AssertEquals("", "Email:email", describe(errorMsg.Errors), t)
+	test.AssertEquals("", "must be a valid email address", errorMsg.Errors[0].Message, t)
+
+	// field errors returned by Validate()
+	w, _ = parse(`{"Email": "a@example.com", "Age": 99, "Contacts": [{"Kind": "work", "Value": "1"}]}`)
+	errorMsg = &ErrorMessage{}
+	Bootstrap(&ContextIn{}).JSONUtils.Unmarshal(httptest.NewRequest(http.MethodGet, "/", w.Body), errorMsg, w)
+	test.AssertEquals("", "Age:custom", describe(errorMsg.Errors), t)
+
+	// plain errors returned by Validate()
+	w, _ = parse(`{"Email": "a@example.com", "Age": 98, "Contacts": [{"Kind": "work", "Value": "1"}]}`)
+	errorMsg = &ErrorMessage{}
+	Bootstrap(&ContextIn{}).JSONUtils.Unmarshal(httptest.NewRequest(http.MethodGet, "/", w.Body), errorMsg, w)
+	test.AssertEquals("", "Age must not be 98", errorMsg.Detail, t)
+	test.AssertEquals("", 0, len(errorMsg.Errors), t)
+
+	// valid
+	w, err = parse(`{"Email": "a@example.com", "Age": 30, "Contacts": [{"Kind": "work", "Value": "1"}]}`)
+	test.AssertTrue("", err == nil, t)
+	test.AssertEquals("", 200, w.Code, t)
+}
@@ -0,0 +1,232 @@
+package http
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/saharsh-samples/go-mux-sql-starter/http/utils"
+)
+
+// ----------
+// Versioning
+// ----------
+
+const (
+
+	// URLPrefixVersioning selects version from first path segment (e.g. /v1/users)
+	URLPrefixVersioning = "URLPrefix"
+
+	// MediaTypeVersioning selects version from the 'version' parameter of
+	// the vendor media type in Accept header
+	// (e.g. Accept: application/vnd.acme+json;version=2)
+	MediaTypeVersioning = "MediaType"
+
+	// HeaderVersioning selects version from a request header
+	// (e.g. API-Version: 2)
+	HeaderVersioning = "Header"
+)
+
+// DefaultVersionHeader value
+const DefaultVersionHeader = "API-Version"
+
+// APIVersion describes a version of the API
+type APIVersion struct {
+	Name string
+
+	// Deprecated versions are served with a Deprecation header
+	Deprecated   bool
+	DeprecatedAt *time.Time
+
+	// Sunset is when version will stop being served (RFC 8594)
+	Sunset *time.Time
+
+	// Link to documentation about deprecation/migration
+	Link string
+}
+
+// VersioningConfiguration for server
+type VersioningConfiguration struct {
+
+	// Strategy is one of URLPrefixVersioning (default), MediaTypeVersioning
+	// or HeaderVersioning
+	Strategy string
+
+	// HeaderName read by HeaderVersioning (defaults to DefaultVersionHeader)
+	HeaderName string
+
+	// VendorMediaType read by MediaTypeVersioning (e.g. "application/vnd.acme+json")
+	VendorMediaType string
+
+	// DefaultVersion used by MediaTypeVersioning and HeaderVersioning when
+	// request doesn't specify a version
+	DefaultVersion string
+
+	// Versions with their lifecycle information. Versions registered via
+	// RoutesAgent.Version are also considered existing
+	Versions []APIVersion
+}
+
+// VersionedRoutes registers the same Routes under multiple API versions
+type VersionedRoutes struct {
+	Routes   Routes
+	Versions []string
+}
+
+// Register Routes once per version
+func (versioned *VersionedRoutes) Register(agent RoutesAgent) {
+	for _, version := range versioned.Versions {
+		versioned.Routes.Register(agent.Version(version))
+	}
+}
+
+// --------
+// Internal
+// --------
+
+type versioning struct {
+	config    VersioningConfiguration
+	known     map[string]*APIVersion
+	jsonUtils utils.JSONUtils
+}
+
+var versionLikeSegment = regexp.MustCompile(`^v[0-9]+(\.[0-9]+)?$`)
+
+func newVersioning(config *VersioningConfiguration, jsonUtils utils.JSONUtils) *versioning {
+
+	resolved := VersioningConfiguration{Strategy: URLPrefixVersioning}
+	if config != nil {
+		resolved = *config
+	}
+	if resolved.Strategy == "" {
+		resolved.Strategy = URLPrefixVersioning
+	}
+	if resolved.HeaderName == "" {
+		resolved.HeaderName = DefaultVersionHeader
+	}
+
+	v := &versioning{config: resolved, known: make(map[string]*APIVersion), jsonUtils: jsonUtils}
+	for i := range resolved.Versions {
+		v.known[resolved.Versions[i].Name] = &resolved.Versions[i]
+	}
+	return v
+}
+
+// register version (if not already known) and return its description
+func (v *versioning) register(name string) *APIVersion {
+	if _, found := v.known[name]; !found {
+		v.known[name] = &APIVersion{Name: name}
+	}
+	return v.known[name]
+}
+
+// group creates router for routes of specified version
+func (v *versioning) group(router *mux.Router, name string) (*mux.Router, string) {
+
+	version := v.register(name)
+
+	var subrouter *mux.Router
+	prefix := ""
+	switch v.config.Strategy {
+	case MediaTypeVersioning, HeaderVersioning:
+		subrouter = router.NewRoute().MatcherFunc(func(r *http.Request, _ *mux.RouteMatch) bool {
+			requested, _ := v.requestedVersion(r)
+			return requested == name
+		}).Subrouter()
+	default:
+		prefix = "/" + name
+		subrouter = router.PathPrefix(prefix).Subrouter()
+	}
+
+	subrouter.Use(v.lifecycleHeaders(version))
+	return subrouter, prefix
+}
+
+// requestedVersion returns requested version and whether request
+// explicitly specified it
+func (v *versioning) requestedVersion(r *http.Request) (string, bool) {
+
+	switch v.config.Strategy {
+
+	case HeaderVersioning:
+		if requested := strings.TrimSpace(r.Header.Get(v.config.HeaderName)); requested != "" {
+			return requested, true
+		}
+
+	case MediaTypeVersioning:
+		for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+			mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+			if err == nil && strings.EqualFold(mediaType, v.config.VendorMediaType) && params["version"] != "" {
+				return params["version"], true
+			}
+		}
+
+	default:
+		segments := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+		return segments[0], versionLikeSegment.MatchString(segments[0])
+	}
+
+	return v.config.DefaultVersion, false
+}
+
+// rejectUnknownVersions responds with 404 (406 for MediaTypeVersioning)
+// when request explicitly asks for a version that doesn't exist
+func (v *versioning) rejectUnknownVersions(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		// nothing to reject if app doesn't use versioning
+		if len(v.known) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		requested, explicit := v.requestedVersion(r)
+		if _, found := v.known[requested]; explicit && !found {
+			detail := fmt.Sprintf("API version '%v' does not exist", requested)
+			if v.config.Strategy == MediaTypeVersioning {
+				v.jsonUtils.NotAcceptable(w, detail)
+			} else {
+				v.jsonUtils.NotFound(w, detail)
+			}
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// lifecycleHeaders sets Deprecation, Sunset and Link headers for version
+func (v *versioning) lifecycleHeaders(version *APIVersion) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			if v.config.Strategy == HeaderVersioning {
+				w.Header().Set(v.config.HeaderName, version.Name)
+			}
+
+			if version.Deprecated {
+				if version.DeprecatedAt != nil {
+					w.Header().Set("Deprecation", fmt.Sprintf("@%d", version.DeprecatedAt.Unix()))
+				} else {
+					w.Header().Set("Deprecation", "true")
+				}
+				if version.Link != "" {
+					w.Header().Add("Link", fmt.Sprintf(`<%v>; rel="deprecation"`, version.Link))
+				}
+			}
+
+			if version.Sunset != nil {
+				w.Header().Set("Sunset", version.Sunset.UTC().Format(http.TimeFormat))
+				if version.Link != "" {
+					w.Header().Add("Link", fmt.Sprintf(`<%v>; rel="sunset"`, version.Link))
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	httpUtils "github.com/saharsh-samples/go-mux-sql-starter/http/utils"
+	"github.com/saharsh-samples/go-mux-sql-starter/test"
+	"github.com/saharsh-samples/go-mux-sql-starter/utils"
+)
+
+type versionedRoute struct{ name string }
+
+func (resource *versionedRoute) Register(agent RoutesAgent) {
+	agent.RegisterGet("/things", func(w http.ResponseWriter, r *http.Request) {
+		metadata, _ := GetRouteMetadata(r)
+		w.Header().Set("X-Served-By", resource.name+"@"+metadata.Version)
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+func runVersionedServer(t *testing.T, config *VersioningConfiguration) (Server, func(headers map[string]string, path string) *http.Response) {
+
+	server := Bootstrap(&ContextIn{
+		Port:       0,
+		Versioning: config,
+		RoutesToRegister: []Routes{
+			&VersionedRoutes{Routes: &versionedRoute{name: "things"}, Versions: []string{"v1", "v2"}},
+		},
+	}).Server
+
+	go server.Run()
+	utils.WaitTill(func() bool { return server.IsReady() }, 10)
+	port, _ := server.Port()
+
+	get := func(headers map[string]string, path string) *http.Response {
+		request, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("http://localhost:%d%s", port, path), nil)
+		for name, value := range headers {
+			request.Header.Set(name, value)
+		}
+		resp, err := http.DefaultClient.Do(request)
+		test.AssertTrue("Expected no errors doing GET "+path, err == nil, t)
+		return resp
+	}
+
+	return server, get
+}
+
+func TestRun_with_url_prefix_versioning(t *testing.T) {
+
+	// arrange
+	deprecatedAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	sunset := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	server, get := runVersionedServer(t, &VersioningConfiguration{
+		Versions: []APIVersion{
+			{Name: "v1", Deprecated: true, DeprecatedAt: &deprecatedAt, Sunset: &sunset, Link: "https://example.com/migrate"},
+		},
+	})
+	defer server.Shutdown()
+
+	// act and assert
+	resp := get(nil, "/v1/things")
+	resp.Body.Close()
+	test.AssertEquals("", 200, resp.StatusCode, t)
+	test.AssertEquals("", "things@v1", resp.Header.Get("X-Served-By"), t)
+	test.AssertEquals("", fmt.Sprintf("@%d", deprecatedAt.Unix()), resp.Header.Get("Deprecation"), t)
+	test.AssertEquals("", "Wed, 01 Jan 2025 00:00:00 GMT", resp.Header.Get("Sunset"), t)
+	test.AssertEquals("", 2, len(resp.Header.Values("Link")), t)
+
+	resp = get(nil, "/v2/things")
+	resp.Body.Close()
+	test.AssertEquals("", 200, resp.StatusCode, t)
+	test.AssertEquals("", "things@v2", resp.Header.Get("X-Served-By"), t)
+	test.AssertEquals("", "", resp.Header.Get("Deprecation"), t)
+	test.AssertEquals("", "", resp.Header.Get("Sunset"), t)
+
+	resp = get(nil, "/v3/things")
+	defer resp.Body.Close()
+	errorMsg := &httpUtils.ErrorMessage{}
+	json.NewDecoder(resp.Body).Decode(errorMsg)
+	test.AssertEquals("", 404, resp.StatusCode, t)
+	test.AssertEquals("", "API version 'v3' does not exist", errorMsg.Detail, t)
+}
+
+func TestRun_with_header_versioning(t *testing.T) {
+
+	// arrange
+	server, get := runVersionedServer(t, &VersioningConfiguration{
+		Strategy:       HeaderVersioning,
+		DefaultVersion: "v2",
+		Versions:       []APIVersion{{Name: "v1", Deprecated: true}},
+	})
+	defer server.Shutdown()
+
+	// act and assert
+	resp := get(map[string]string{DefaultVersionHeader: "v1"}, "/things")
+	resp.Body.Close()
+	test.AssertEquals("", 200, resp.StatusCode, t)
+	test.AssertEquals("", "things@v1", resp.Header.Get("X-Served-By"), t)
+	test.AssertEquals("", "v1", resp.Header.Get(DefaultVersionHeader), t)
+	test.AssertEquals("", "true", resp.Header.Get("Deprecation"), t)
+
+	resp = get(nil, "/things")
+	resp.Body.Close()
+	test.AssertEquals("", 200, resp.StatusCode, t)
+	test.AssertEquals("", "things@v2", resp.Header.Get("X-Served-By"), t)
+
+	resp = get(map[string]string{DefaultVersionHeader: "v3"}, "/things")
+	resp.Body.Close()
+	test.AssertEquals("", 404, resp.StatusCode, t)
+}
+
+func TestRun_with_media_type_versioning(t *testing.T) {
+
+	// arrange
+	server, get := runVersionedServer(t, &VersioningConfiguration{
+		Strategy:        MediaTypeVersioning,
+		VendorMediaType: "application/vnd.acme+json",
+		DefaultVersion:  "v1",
+	})
+	defer server.Shutdown()
+
+	// act and assert
+	resp := get(map[string]string{"Accept": "text/html, application/vnd.acme+json; version=v2"}, "/things")
+	resp.Body.Close()
+	test.AssertEquals("", 200, resp.StatusCode, t)
+	test.AssertEquals("", "things@v2", resp.Header.Get("X-Served-By"), t)
+
+	resp = get(map[string]string{"Accept": "application/json"}, "/things")
+	resp.Body.Close()
+	test.AssertEquals("", 200, resp.StatusCode, t)
+	test.AssertEquals("", "things@v1", resp.Header.Get("X-Served-By"), t)
+
+	resp = get(map[string]string{"Accept": "application/vnd.acme+json;version=v3"}, "/things")
+	defer resp.Body.Close()
+	errorMsg := &httpUtils.ErrorMessage{}
+	json.NewDecoder(resp.Body).Decode(errorMsg)
+	test.AssertEquals("", 406, resp.StatusCode, t)
+	test.AssertEquals("", "Not Acceptable", errorMsg.Message, t)
+}
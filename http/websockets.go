@@ -0,0 +1,443 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/saharsh-samples/go-mux-sql-starter/http/utils"
+)
+
+// ---
+// WebSockets
+//
+// A WebSocketHub upgrades requests of WebSocket routes, keeps connections
+// alive with pings, and broadcasts messages to rooms of connections:
+//
+//	agent.RegisterWebSocket("/chat/{room}", hub, func(conn WebSocketConnection) {
+//		room := mux.Vars(conn.Request())["room"]
+//		conn.Join(room)
+//		for {
+//			_, message, err := conn.Receive()
+//			if err != nil {
+//				return
+//			}
+//			hub.Broadcast(room, TextMessage, message)
+//		}
+//	}, RequiresAuth())
+//
+// Upgrade requests pass through middlewares like any other request, so
+// they're authenticated with the same bearer token. Browsers can't set
+// the Authorization header on WebSockets, so the token can also be sent
+// as subprotocols 'bearer, <token>'. Hubs passed in ContextIn.WebSocketHubs
+// send close frames to all connections when Server.Shutdown runs
+// ---
+
+// Message types
+const (
+	TextMessage   = websocket.TextMessage
+	BinaryMessage = websocket.BinaryMessage
+)
+
+// BearerSubprotocol announces the bearer token sent as next subprotocol
+const BearerSubprotocol = "bearer"
+
+// DefaultPongWait value
+const DefaultPongWait = 60 * time.Second
+
+// DefaultWebSocketWriteWait value
+const DefaultWebSocketWriteWait = 10 * time.Second
+
+// DefaultMaxMessageBytes value
+const DefaultMaxMessageBytes = 64 * 1024
+
+// DefaultSendBufferSize value
+const DefaultSendBufferSize = 16
+
+// WebSocketConfiguration of a WebSocketHub. Zero values are replaced with
+// defaults
+type WebSocketConfiguration struct {
+	// PongWait is how long connections may go without a pong. Pings are
+	// sent at 90% of it
+	PongWait time.Duration
+	// WriteWait is the deadline for writing a message
+	WriteWait time.Duration
+	// MaxMessageBytes of received messages. Larger messages close the
+	// connection
+	MaxMessageBytes int64
+	// SendBufferSize is the number of messages a connection can lag behind
+	// before it's closed
+	SendBufferSize int
+	// CheckOrigin of upgrade requests (defaults to same origin only)
+	CheckOrigin func(r *http.Request) bool
+	// Subprotocols supported, in order of preference
+	Subprotocols []string
+	// JSONUtils used to write error responses (optional)
+	JSONUtils utils.JSONUtils
+}
+
+// WebSocketHandler handles a connection. Handlers usually Receive till an
+// error is returned. Connections stay open after handlers return, e.g.
+// for connections only receiving broadcasts, till the client closes them
+type WebSocketHandler func(conn WebSocketConnection)
+
+// WebSocketConnection is a connection of a WebSocket route
+type WebSocketConnection interface {
+
+	// Request that was upgraded
+	Request() *http.Request
+
+	// Receive next message. Must not be called concurrently
+	Receive() (messageType int, data []byte, err error)
+
+	// Send message. Messages are queued, so Send can be called concurrently
+	Send(messageType int, data []byte) error
+	SendJSON(value interface{}) error
+
+	// Join and Leave rooms of hub
+	Join(room string)
+	Leave(room string)
+
+	// Close with close frame
+	Close(code int, reason string)
+}
+
+// WebSocketHub tracks connections and their rooms
+type WebSocketHub interface {
+
+	// Handler upgrading requests and passing connections to handler
+	Handler(handler WebSocketHandler) func(w http.ResponseWriter, r *http.Request)
+
+	// Broadcast message to all connections in room
+	Broadcast(room string, messageType int, data []byte)
+	BroadcastJSON(room string, value interface{}) error
+
+	// Members of room
+	Members(room string) int
+
+	// Close all connections with 'going away' close frames. Requests
+	// upgrading afterwards are rejected
+	Close()
+}
+
+// ErrConnectionClosed is returned when sending to closed connections
+var ErrConnectionClosed = errors.New("WebSocket connection is closed")
+
+// NewWebSocketHub with config (optional)
+func NewWebSocketHub(config *WebSocketConfiguration) WebSocketHub {
+
+	if config == nil {
+		config = &WebSocketConfiguration{}
+	}
+	hub := &webSocketHub{
+		pongWait:        config.PongWait,
+		writeWait:       config.WriteWait,
+		maxMessageBytes: config.MaxMessageBytes,
+		sendBufferSize:  config.SendBufferSize,
+		jsonUtils:       config.JSONUtils,
+		connections:     map[*webSocketConnection]bool{},
+		rooms:           map[string]map[*webSocketConnection]bool{},
+	}
+	if hub.pongWait == 0 {
+		hub.pongWait = DefaultPongWait
+	}
+	if hub.writeWait == 0 {
+		hub.writeWait = DefaultWebSocketWriteWait
+	}
+	if hub.maxMessageBytes == 0 {
+		hub.maxMessageBytes = DefaultMaxMessageBytes
+	}
+	if hub.sendBufferSize == 0 {
+		hub.sendBufferSize = DefaultSendBufferSize
+	}
+	if hub.jsonUtils == nil {
+		hub.jsonUtils = utils.Bootstrap(&utils.ContextIn{}).JSONUtils
+	}
+	hub.upgrader = &websocket.Upgrader{
+		CheckOrigin:  config.CheckOrigin,
+		Subprotocols: append(append([]string{}, config.Subprotocols...), BearerSubprotocol),
+	}
+	return hub
+}
+
+// --------
+// Internal
+// --------
+
+type webSocketHub struct {
+	pongWait        time.Duration
+	writeWait       time.Duration
+	maxMessageBytes int64
+	sendBufferSize  int
+	jsonUtils       utils.JSONUtils
+	upgrader        *websocket.Upgrader
+
+	lock        sync.Mutex
+	closed      bool
+	connections map[*webSocketConnection]bool
+	rooms       map[string]map[*webSocketConnection]bool
+}
+
+type webSocketMessage struct {
+	messageType int
+	data        []byte
+}
+
+type webSocketConnection struct {
+	hub     *webSocketHub
+	conn    *websocket.Conn
+	request *http.Request
+	send    chan *webSocketMessage
+
+	lock      sync.Mutex
+	rooms     map[string]bool
+	closing   chan struct{}
+	closeOnce sync.Once
+}
+
+func (hub *webSocketHub) Handler(handler WebSocketHandler) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		hub.lock.Lock()
+		closed := hub.closed
+		hub.lock.Unlock()
+		if closed {
+			hub.jsonUtils.ServiceUnavailable(w, "Server is shutting down")
+			return
+		}
+
+		// upgrader writes error responses itself
+		conn, err := hub.upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+
+		c := &webSocketConnection{
+			hub:     hub,
+			conn:    conn,
+			request: r,
+			send:    make(chan *webSocketMessage, hub.sendBufferSize),
+			rooms:   map[string]bool{},
+			closing: make(chan struct{}),
+		}
+		if !hub.add(c) {
+			c.Close(websocket.CloseGoingAway, "Server is shutting down")
+		}
+
+		conn.SetReadLimit(hub.maxMessageBytes)
+		conn.SetReadDeadline(time.Now().Add(hub.pongWait))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(hub.pongWait))
+		})
+
+		done := make(chan struct{})
+		go c.writeMessages(done)
+
+		handler(c)
+
+		// keep reading, so control frames are handled, till closed
+		for {
+			if _, _, err := c.Receive(); err != nil {
+				break
+			}
+		}
+
+		hub.remove(c)
+		c.closeOnce.Do(func() { close(c.closing) })
+		<-done
+		conn.Close()
+	}
+}
+
+func (hub *webSocketHub) Broadcast(room string, messageType int, data []byte) {
+	hub.lock.Lock()
+	members := make([]*webSocketConnection, 0, len(hub.rooms[room]))
+	for c := range hub.rooms[room] {
+		members = append(members, c)
+	}
+	hub.lock.Unlock()
+
+	for _, c := range members {
+		c.Send(messageType, data)
+	}
+}
+
+func (hub *webSocketHub) BroadcastJSON(room string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	hub.Broadcast(room, TextMessage, data)
+	return nil
+}
+
+func (hub *webSocketHub) Members(room string) int {
+	hub.lock.Lock()
+	defer hub.lock.Unlock()
+	return len(hub.rooms[room])
+}
+
+func (hub *webSocketHub) Close() {
+	hub.lock.Lock()
+	hub.closed = true
+	connections := make([]*webSocketConnection, 0, len(hub.connections))
+	for c := range hub.connections {
+		connections = append(connections, c)
+	}
+	hub.lock.Unlock()
+
+	for _, c := range connections {
+		c.Close(websocket.CloseGoingAway, "Server is shutting down")
+	}
+}
+
+func (hub *webSocketHub) add(c *webSocketConnection) bool {
+	hub.lock.Lock()
+	defer hub.lock.Unlock()
+	if hub.closed {
+		return false
+	}
+	hub.connections[c] = true
+	return true
+}
+
+func (hub *webSocketHub) remove(c *webSocketConnection) {
+	hub.lock.Lock()
+	defer hub.lock.Unlock()
+	delete(hub.connections, c)
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for room := range c.rooms {
+		hub.leave(room, c)
+	}
+}
+
+// leave room. Hub must be locked
+func (hub *webSocketHub) leave(room string, c *webSocketConnection) {
+	delete(hub.rooms[room], c)
+	if len(hub.rooms[room]) == 0 {
+		delete(hub.rooms, room)
+	}
+}
+
+func (c *webSocketConnection) Request() *http.Request {
+	return c.request
+}
+
+func (c *webSocketConnection) Receive() (int, []byte, error) {
+	return c.conn.ReadMessage()
+}
+
+func (c *webSocketConnection) Send(messageType int, data []byte) error {
+	select {
+	case <-c.closing:
+		return ErrConnectionClosed
+	default:
+	}
+	select {
+	case c.send <- &webSocketMessage{messageType: messageType, data: data}:
+		return nil
+	default:
+		c.Close(websocket.ClosePolicyViolation, "Connection can't keep up")
+		return ErrConnectionClosed
+	}
+}
+
+func (c *webSocketConnection) SendJSON(value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return c.Send(TextMessage, data)
+}
+
+func (c *webSocketConnection) Join(room string) {
+	c.hub.lock.Lock()
+	defer c.hub.lock.Unlock()
+	if !c.hub.connections[c] {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.hub.rooms[room] == nil {
+		c.hub.rooms[room] = map[*webSocketConnection]bool{}
+	}
+	c.hub.rooms[room][c] = true
+	c.rooms[room] = true
+}
+
+func (c *webSocketConnection) Leave(room string) {
+	c.hub.lock.Lock()
+	defer c.hub.lock.Unlock()
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.hub.leave(room, c)
+	delete(c.rooms, room)
+}
+
+// Close sends close frame. Connection is closed once client responds with
+// its close frame, or the write wait passes
+func (c *webSocketConnection) Close(code int, reason string) {
+	c.closeOnce.Do(func() {
+		close(c.closing)
+		deadline := time.Now().Add(c.hub.writeWait)
+		c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), deadline)
+		c.conn.SetReadDeadline(deadline)
+	})
+}
+
+// abort broken connection without close frame
+func (c *webSocketConnection) abort() {
+	c.closeOnce.Do(func() {
+		close(c.closing)
+		c.conn.SetReadDeadline(time.Now())
+	})
+}
+
+// writeMessages queued by Send and pings till connection is closing
+func (c *webSocketConnection) writeMessages(done chan<- struct{}) {
+
+	defer close(done)
+	ping := time.NewTicker(c.hub.pongWait * 9 / 10)
+	defer ping.Stop()
+
+	for {
+		select {
+		case message := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(c.hub.writeWait))
+			if err := c.conn.WriteMessage(message.messageType, message.data); err != nil {
+				c.abort()
+				return
+			}
+		case <-ping.C:
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(c.hub.writeWait)); err != nil {
+				c.abort()
+				return
+			}
+		case <-c.closing:
+			return
+		}
+	}
+}
+
+// bearerFromSubprotocol sets Authorization header of WebSocket upgrade
+// requests offering subprotocols 'bearer, <token>', so middlewares
+// authenticate them like any other request
+func bearerFromSubprotocol(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" && websocket.IsWebSocketUpgrade(r) {
+			protocols := websocket.Subprotocols(r)
+			for i, protocol := range protocols {
+				if strings.EqualFold(protocol, BearerSubprotocol) && i+1 < len(protocols) {
+					r.Header.Set("Authorization", "Bearer "+protocols[i+1])
+					break
+				}
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
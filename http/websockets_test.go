@@ -0,0 +1,173 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	httpUtils "github.com/saharsh-samples/go-mux-sql-starter/http/utils"
+	"github.com/saharsh-samples/go-mux-sql-starter/test"
+	"github.com/saharsh-samples/go-mux-sql-starter/utils"
+)
+
+type chatRoutes struct {
+	hub WebSocketHub
+}
+
+func (resource *chatRoutes) Register(agent RoutesAgent) {
+	agent.RegisterWebSocket("/chat/{room}", resource.hub, func(conn WebSocketConnection) {
+		room := strings.TrimPrefix(conn.Request().URL.Path, "/chat/")
+		conn.Join(room)
+		for {
+			_, message, err := conn.Receive()
+			if err != nil {
+				return
+			}
+			resource.hub.Broadcast(room, TextMessage, message)
+		}
+	})
+}
+
+// requireBearer rejects requests without bearer token 'secret'
+func requireBearer(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func startChatServer(config *WebSocketConfiguration) (Server, WebSocketHub, string) {
+	hub := NewWebSocketHub(config)
+	server := Bootstrap(&ContextIn{
+		Port:                  0,
+		RoutesToRegister:      []Routes{&chatRoutes{hub: hub}},
+		MiddlewaresToRegister: Middlewares{requireBearer},
+		WebSocketHubs:         []WebSocketHub{hub},
+	}).Server
+	go server.Run()
+	utils.WaitTill(func() bool { return server.IsReady() }, 10)
+	port, _ := server.Port()
+	return server, hub, fmt.Sprintf("ws://localhost:%v/chat/", port)
+}
+
+func TestWebSocketHub(t *testing.T) {
+
+	// arrange
+	server, hub, url := startChatServer(nil)
+	defer server.Shutdown()
+
+	// act
+	_, response, unauthorizedErr := websocket.DefaultDialer.Dial(url+"lobby", nil)
+	alice, _, aliceErr := websocket.DefaultDialer.Dial(url+"lobby", http.Header{"Authorization": {"Bearer secret"}})
+	browser := &websocket.Dialer{Subprotocols: []string{BearerSubprotocol, "secret"}}
+	bob, bobResponse, bobErr := browser.Dial(url+"lobby", nil)
+	carol, _, carolErr := browser.Dial(url+"kitchen", nil)
+	test.AssertTrue("Expected authenticated dials to succeed", aliceErr == nil && bobErr == nil && carolErr == nil, t)
+	if aliceErr != nil || bobErr != nil || carolErr != nil {
+		return
+	}
+	defer alice.Close()
+	defer bob.Close()
+	defer carol.Close()
+	utils.WaitTill(func() bool { return hub.Members("lobby") == 2 && hub.Members("kitchen") == 1 }, 10)
+	alice.WriteMessage(websocket.TextMessage, []byte("hello"))
+
+	// assert
+	test.AssertTrue("Expected unauthenticated dial to fail", unauthorizedErr != nil, t)
+	test.AssertEquals("", http.StatusUnauthorized, response.StatusCode, t)
+	test.AssertEquals("", BearerSubprotocol, bobResponse.Header.Get("Sec-WebSocket-Protocol"), t)
+	for _, member := range []*websocket.Conn{alice, bob} {
+		_, message, err := member.ReadMessage()
+		test.AssertTrue("Expected broadcast", err == nil, t)
+		test.AssertEquals("", "hello", string(message), t)
+	}
+	carol.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	_, _, err := carol.ReadMessage()
+	test.AssertTrue("Expected no broadcast to other rooms", err != nil, t)
+}
+
+func TestWebSocketHub_keepalive_and_limits(t *testing.T) {
+
+	// arrange
+	server, hub, url := startChatServer(&WebSocketConfiguration{PongWait: 100 * time.Millisecond, MaxMessageBytes: 8})
+	defer server.Shutdown()
+	conn, _, err := websocket.DefaultDialer.Dial(url+"lobby", http.Header{"Authorization": {"Bearer secret"}})
+	test.AssertTrue("Expected dial to succeed", err == nil, t)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	pings := 0
+	conn.SetPingHandler(func(data string) error {
+		pings++
+		return conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+	})
+
+	closed := make(chan error)
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				closed <- err
+				return
+			}
+		}
+	}()
+
+	// act: idle longer than pong wait, answering pings while reading
+	time.Sleep(300 * time.Millisecond)
+	members := hub.Members("lobby")
+	conn.WriteMessage(websocket.TextMessage, []byte("far too long"))
+	closeErr := <-closed
+
+	// assert
+	test.AssertTrue("Expected pings", pings >= 2, t)
+	test.AssertEquals("Expected connection to survive idling", 1, members, t)
+	test.AssertTrue("Expected message too big close", websocket.IsCloseError(closeErr, websocket.CloseMessageTooBig), t)
+}
+
+func TestWebSocketHub_closes_connections_on_shutdown(t *testing.T) {
+
+	// arrange
+	server, hub, url := startChatServer(nil)
+	conn, _, err := websocket.DefaultDialer.Dial(url+"lobby", http.Header{"Authorization": {"Bearer secret"}})
+	test.AssertTrue("Expected dial to succeed", err == nil, t)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	utils.WaitTill(func() bool { return hub.Members("lobby") == 1 }, 10)
+
+	// act
+	test.AssertTrue("Expected no errors shutting down", server.Shutdown() == nil, t)
+	_, _, closeErr := conn.ReadMessage()
+
+	// assert
+	test.AssertTrue("Expected going away close", websocket.IsCloseError(closeErr, websocket.CloseGoingAway), t)
+	utils.WaitTill(func() bool { return hub.Members("lobby") == 0 }, 10)
+	test.AssertEquals("", 0, hub.Members("lobby"), t)
+}
+
+func TestWebSocketHub_rejects_upgrades_once_closed(t *testing.T) {
+
+	// arrange
+	hub := NewWebSocketHub(nil)
+	hub.Close()
+	w := httptest.NewRecorder()
+
+	// act
+	hub.Handler(func(conn WebSocketConnection) {})(w, httptest.NewRequest(http.MethodGet, "/chat/lobby", nil))
+
+	// assert
+	test.AssertEquals("", http.StatusServiceUnavailable, w.Code, t)
+	errorMsg := &httpUtils.ErrorMessage{}
+	json.Unmarshal(w.Body.Bytes(), errorMsg)
+	test.AssertEquals("", "Server is shutting down", errorMsg.Detail, t)
+}
@@ -0,0 +1,127 @@
+package passwords
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ---
+// Breached Password Corpus
+//
+// Corpus is expected in the format published by Have I Been Pwned, i.e.
+// upper case SHA-1 hashes of breached passwords, optionally followed by
+// ':<count>'. It can be provided either as
+//
+//  - a single file with one full 40 character hash per line, which is
+//    loaded into memory and indexed by 5 character hash prefix, or
+//  - a directory of files named after 5 character hash prefixes, each
+//    containing the remaining 35 character hash suffixes. Files are only
+//    read when a password with matching prefix is checked.
+// ---
+
+// BreachedPasswords checks passwords against a corpus of breached passwords
+type BreachedPasswords interface {
+	Contains(password string) (bool, error)
+}
+
+// sha1PrefixLength is the number of hex characters used to index the corpus
+const sha1PrefixLength = 5
+
+// LoadBreachedPasswords from specified file or directory
+func LoadBreachedPasswords(path string) (BreachedPasswords, error) {
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.IsDir() {
+		return &breachedPasswordsDirectory{dir: path}, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	corpus := &breachedPasswordsIndex{index: make(map[string]map[string]struct{})}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		hash := parseCorpusLine(scanner.Text())
+		if len(hash) != sha1.Size*2 {
+			continue
+		}
+		prefix, suffix := hash[:sha1PrefixLength], hash[sha1PrefixLength:]
+		if corpus.index[prefix] == nil {
+			corpus.index[prefix] = make(map[string]struct{})
+		}
+		corpus.index[prefix][suffix] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return corpus, nil
+}
+
+// in memory corpus
+type breachedPasswordsIndex struct {
+	index map[string]map[string]struct{}
+}
+
+// Contains returns 'true' if password is found in the corpus
+func (corpus *breachedPasswordsIndex) Contains(password string) (bool, error) {
+	prefix, suffix := hashPassword(password)
+	_, found := corpus.index[prefix][suffix]
+	return found, nil
+}
+
+// on disk corpus split into prefix files
+type breachedPasswordsDirectory struct {
+	dir string
+}
+
+// Contains returns 'true' if password is found in the corpus
+func (corpus *breachedPasswordsDirectory) Contains(password string) (bool, error) {
+
+	prefix, suffix := hashPassword(password)
+
+	file, err := os.Open(filepath.Join(corpus.dir, prefix))
+	if errors.Is(err, os.ErrNotExist) {
+		file, err = os.Open(filepath.Join(corpus.dir, prefix+".txt"))
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if parseCorpusLine(scanner.Text()) == suffix {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
+
+func hashPassword(password string) (prefix string, suffix string) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	return hash[:sha1PrefixLength], hash[sha1PrefixLength:]
+}
+
+func parseCorpusLine(line string) string {
+	if i := strings.IndexByte(line, ':'); i >= 0 {
+		line = line[:i]
+	}
+	return strings.ToUpper(strings.TrimSpace(line))
+}
@@ -0,0 +1,66 @@
+package passwords
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/saharsh-samples/go-mux-sql-starter/test"
+)
+
+// SHA-1("password") = 5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8
+
+func TestLoadBreachedPasswords_from_file(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "pwned.txt")
+	os.WriteFile(path, []byte(
+		"5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8:3861493\n"+
+			"not-a-hash\n"+
+			"7c4a8d09ca3762af61e59520943dc26494f8941b\n",
+	), 0600)
+
+	corpus, err := LoadBreachedPasswords(path)
+	test.AssertTrue("Expected corpus to load", err == nil, t)
+
+	found, err := corpus.Contains("password")
+	test.AssertTrue("Expected 'password' to be breached", found && err == nil, t)
+
+	found, _ = corpus.Contains("123456")
+	test.AssertTrue("Expected lower case hashes to be supported", found, t)
+
+	found, _ = corpus.Contains("vX9#qL2!mZ7@")
+	test.AssertFalse("Expected random password to not be breached", found, t)
+}
+
+func TestLoadBreachedPasswords_from_directory(t *testing.T) {
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "5BAA6"), []byte("1E4C9B93F3F0682250B6CF8331B7EE68FD8:3861493\n"), 0600)
+
+	corpus, err := LoadBreachedPasswords(dir)
+	test.AssertTrue("Expected corpus to load", err == nil, t)
+
+	found, err := corpus.Contains("password")
+	test.AssertTrue("Expected 'password' to be breached", found && err == nil, t)
+
+	found, err = corpus.Contains("vX9#qL2!mZ7@")
+	test.AssertFalse("Expected random password to not be breached", found, t)
+	test.AssertTrue("Expected missing prefix file to not be an error", err == nil, t)
+}
+
+func TestLoadBreachedPasswords_with_missing_path(t *testing.T) {
+	_, err := LoadBreachedPasswords(filepath.Join(t.TempDir(), "missing"))
+	test.AssertFalse("Expected error loading missing corpus", err == nil, t)
+}
+
+func TestCheckPassword_breached(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "pwned.txt")
+	os.WriteFile(path, []byte("5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8\n"), 0600)
+
+	checker := Bootstrap(&ContextIn{BreachedPasswordsPath: path}).PasswordPolicyChecker
+
+	violations, _ := checker.CheckPassword("password").(Violations)
+	test.AssertTrue("", violations.HasCode(ViolationFoundInBreachedPasswords), t)
+	test.AssertTrue("Expected password to pass", checker.CheckPassword("vX9#qL2!mZ7@") == nil, t)
+}
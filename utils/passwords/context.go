@@ -1,21 +1,62 @@
 package passwords
 
+import (
+	"time"
+
+	"github.com/saharsh-samples/go-mux-sql-starter/db"
+)
+
 // ContextIn describes dependecies needed by this package
 type ContextIn struct {
 	Argon2Config Argon2Config
+
+	// PasswordPolicy enforced by exported PasswordPolicyChecker
+	PasswordPolicy PasswordPolicy
+
+	// BreachedPasswordsPath is an optional path to a file or directory
+	// containing the breached password corpus (see LoadBreachedPasswords)
+	BreachedPasswordsPath string
+
+	// TOTPConfig for exported TOTPManager
+	TOTPConfig TOTPConfig
+
+	// Database used by TOTPManager for replay prevention and recovery codes.
+	// TOTPManager is only exported if Database is set
+	Database db.Database
 }
 
 // ContextOut describes dependencies exported by this package
 type ContextOut struct {
-	PasswordHasher PasswordHasher
+	PasswordHasher        PasswordHasher
+	PasswordPolicyChecker PasswordPolicyChecker
+	TOTPManager           TOTPManager
 }
 
 // Bootstrap initializes this module with ContextIn and exports
 // resulting ContextOut
 func Bootstrap(in *ContextIn) *ContextOut {
 
+	// load breached passwords corpus (if configured)
+	checker := &policyChecker{policy: in.PasswordPolicy.withDefaults()}
+	if in.BreachedPasswordsPath != "" {
+		breached, err := LoadBreachedPasswords(in.BreachedPasswordsPath)
+		if err != nil {
+			panic(err)
+		}
+		checker.breached = breached
+	}
+
 	out := &ContextOut{}
 	out.PasswordHasher = &hasher{config: in.Argon2Config}
+	out.PasswordPolicyChecker = checker
+	if in.Database != nil {
+		out.TOTPManager = &totpManager{
+			config:   in.TOTPConfig.withDefaults(),
+			database: in.Database,
+			hasher:   out.PasswordHasher,
+			now:      time.Now,
+		}
+	}
 
 	return out
 }
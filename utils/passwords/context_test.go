@@ -3,6 +3,7 @@ package passwords
 import (
 	"testing"
 
+	dbTest "github.com/saharsh-samples/go-mux-sql-starter/db/test"
 	"github.com/saharsh-samples/go-mux-sql-starter/test"
 )
 
@@ -15,4 +16,26 @@ func TestBootstrap(t *testing.T) {
 	// verify hasher
 	hasher := out.PasswordHasher
 	test.AssertFalse("", hasher == nil, t)
+
+	// verify TOTP manager is not exported without a database
+	test.AssertTrue("", out.TOTPManager == nil, t)
+
+	// verify policy checker
+	checker := out.PasswordPolicyChecker
+	test.AssertFalse("", checker == nil, t)
+}
+
+func TestBootstrap_with_database(t *testing.T) {
+
+	out := Bootstrap(&ContextIn{Database: &dbTest.MockDatabase{}})
+
+	test.AssertFalse("", out.TOTPManager == nil, t)
+}
+
+func TestBootstrap_with_bad_breached_passwords_path(t *testing.T) {
+
+	// assert via defer
+	defer test.AssertPanic("Expected panic during Bootstrap", t)
+
+	Bootstrap(&ContextIn{BreachedPasswordsPath: "/does/not/exist"})
 }
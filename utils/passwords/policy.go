@@ -0,0 +1,401 @@
+package passwords
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ---
+// Password Policy
+// ---
+
+// PasswordPolicyChecker validates cleartext passwords before they are hashed
+type PasswordPolicyChecker interface {
+
+	// CheckPassword validates password against configured policy. Any
+	// additional userInputs (e.g. email, username) are used to reject
+	// passwords that are too similar to them. If password fails the policy,
+	// returned error is of type Violations
+	CheckPassword(password string, userInputs ...string) error
+}
+
+// DefaultMinPasswordLength value
+const DefaultMinPasswordLength = 8
+
+// DefaultMaxPasswordLength value
+const DefaultMaxPasswordLength = 128
+
+// DefaultMaxSimilarity value
+const DefaultMaxSimilarity = 0.7
+
+// PasswordPolicy values for configuring password validation. MinLength and
+// MaxLength default to DefaultMinPasswordLength and DefaultMaxPasswordLength
+// when zero, and are disabled when negative. Other zero values disable the
+// corresponding check
+type PasswordPolicy struct {
+	MinLength           int
+	MaxLength           int
+	RequireUppercase    bool
+	RequireLowercase    bool
+	RequireDigit        bool
+	RequireSymbol       bool
+	MinCharacterClasses int
+	BannedWords         []string
+	MaxSimilarity       float64
+	MinEntropyBits      float64
+}
+
+// Possible violation codes
+const (
+	ViolationTooShort                 = "TooShort"
+	ViolationTooLong                  = "TooLong"
+	ViolationMissingUppercase         = "MissingUppercase"
+	ViolationMissingLowercase         = "MissingLowercase"
+	ViolationMissingDigit             = "MissingDigit"
+	ViolationMissingSymbol            = "MissingSymbol"
+	ViolationTooFewCharacterClasses   = "TooFewCharacterClasses"
+	ViolationContainsBannedWord       = "ContainsBannedWord"
+	ViolationTooSimilarToUserInput    = "TooSimilarToUserInput"
+	ViolationTooPredictable           = "TooPredictable"
+	ViolationFoundInBreachedPasswords = "FoundInBreachedPasswords"
+)
+
+// Violation describes one way in which a password fails the policy
+type Violation struct {
+	Code    string
+	Message string
+}
+
+// Violations is the error returned when a password fails the policy
+type Violations []Violation
+
+// Error satisfies Go's built in error interface
+func (violations Violations) Error() string {
+	messages := make([]string, len(violations))
+	for i, violation := range violations {
+		messages[i] = violation.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// HasCode returns 'true' if any violation has the specified code
+func (violations Violations) HasCode(code string) bool {
+	for _, violation := range violations {
+		if violation.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+func (policy PasswordPolicy) withDefaults() PasswordPolicy {
+	if policy.MinLength == 0 {
+		policy.MinLength = DefaultMinPasswordLength
+	}
+	if policy.MaxLength == 0 {
+		policy.MaxLength = DefaultMaxPasswordLength
+	}
+	return policy
+}
+
+type policyChecker struct {
+	policy   PasswordPolicy
+	breached BreachedPasswords
+}
+
+// CheckPassword validates password against configured policy
+func (checker *policyChecker) CheckPassword(password string, userInputs ...string) error {
+
+	p := checker.policy
+	var violations Violations
+	add := func(code string, message string) {
+		violations = append(violations, Violation{Code: code, Message: message})
+	}
+
+	// length
+	length := utf8.RuneCountInString(password)
+	if p.MinLength > 0 && length < p.MinLength {
+		add(ViolationTooShort, fmt.Sprintf("Password must be at least %d characters long", p.MinLength))
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		add(ViolationTooLong, fmt.Sprintf("Password must be at most %d characters long", p.MaxLength))
+	}
+
+	// character classes
+	upper, lower, digit, symbol := characterClasses(password)
+	if p.RequireUppercase && !upper {
+		add(ViolationMissingUppercase, "Password must contain an uppercase letter")
+	}
+	if p.RequireLowercase && !lower {
+		add(ViolationMissingLowercase, "Password must contain a lowercase letter")
+	}
+	if p.RequireDigit && !digit {
+		add(ViolationMissingDigit, "Password must contain a digit")
+	}
+	if p.RequireSymbol && !symbol {
+		add(ViolationMissingSymbol, "Password must contain a symbol")
+	}
+	if p.MinCharacterClasses > 0 && countTrue(upper, lower, digit, symbol) < p.MinCharacterClasses {
+		add(ViolationTooFewCharacterClasses,
+			fmt.Sprintf("Password must contain at least %d of: uppercase letters, lowercase letters, digits, symbols", p.MinCharacterClasses))
+	}
+
+	// banned words
+	lowered := strings.ToLower(password)
+	for _, word := range p.BannedWords {
+		if word != "" && strings.Contains(lowered, strings.ToLower(word)) {
+			add(ViolationContainsBannedWord, fmt.Sprintf("Password must not contain '%v'", word))
+			break
+		}
+	}
+
+	// similarity to user inputs
+	if p.MaxSimilarity > 0 {
+		for _, input := range userInputs {
+			if isTooSimilar(lowered, strings.ToLower(input), p.MaxSimilarity) {
+				add(ViolationTooSimilarToUserInput, "Password is too similar to account details")
+				break
+			}
+		}
+	}
+
+	// entropy
+	if p.MinEntropyBits > 0 && EstimateEntropyBits(password, userInputs...) < p.MinEntropyBits {
+		add(ViolationTooPredictable, "Password is too easy to guess")
+	}
+
+	// breached corpus
+	if checker.breached != nil {
+		breached, err := checker.breached.Contains(password)
+		if err != nil {
+			return err
+		}
+		if breached {
+			add(ViolationFoundInBreachedPasswords, "Password has appeared in a known data breach")
+		}
+	}
+
+	if len(violations) > 0 {
+		return violations
+	}
+	return nil
+}
+
+// ---
+// Entropy Estimation
+//
+// Loosely modelled after zxcvbn. Password is scanned for predictable
+// patterns (common words, user inputs, repeats, sequences, keyboard walks)
+// and each matched pattern contributes only as many bits as needed to guess
+// it, while unmatched characters contribute bits based on the size of the
+// character pool in use.
+// ---
+
+var commonWords = []string{
+	"password", "passw0rd", "qwerty", "letmein", "welcome", "admin", "login",
+	"iloveyou", "monkey", "dragon", "master", "sunshine", "princess",
+	"football", "baseball", "shadow", "superman", "trustno1", "secret",
+	"abc123", "starwars", "whatever", "freedom", "hello", "changeme",
+}
+
+var keyboardRows = []string{
+	"`1234567890-=", "qwertyuiop[]\\", "asdfghjkl;'", "zxcvbnm,./",
+}
+
+// EstimateEntropyBits returns a rough estimate of the number of bits of
+// entropy in password, treating any userInputs as known to an attacker
+func EstimateEntropyBits(password string, userInputs ...string) float64 {
+
+	runes := []rune(strings.ToLower(password))
+	if len(runes) == 0 {
+		return 0
+	}
+
+	// known tokens an attacker would try first
+	dictionary := make([]string, 0, len(commonWords)+len(userInputs))
+	dictionary = append(dictionary, commonWords...)
+	for _, input := range userInputs {
+		for _, token := range tokenize(strings.ToLower(input)) {
+			if len(token) >= 3 {
+				dictionary = append(dictionary, token)
+			}
+		}
+	}
+	dictionaryBits := math.Log2(float64(len(dictionary)))
+
+	upper, lower, digit, symbol := characterClasses(password)
+	poolBits := math.Log2(float64(poolSize(upper, lower, digit, symbol)))
+
+	bits := 0.0
+	for i := 0; i < len(runes); {
+
+		// dictionary match
+		if n := longestDictionaryMatch(runes[i:], dictionary); n > 0 {
+			bits += dictionaryBits + 1
+			i += n
+			continue
+		}
+
+		// repeats, sequences and keyboard walks after first character
+		if i > 0 && (runes[i] == runes[i-1] || isSequential(runes[i-1], runes[i]) || isKeyboardAdjacent(runes[i-1], runes[i])) {
+			bits++
+			i++
+			continue
+		}
+
+		bits += poolBits
+		i++
+	}
+
+	return bits
+}
+
+// ---
+// Helpers
+// ---
+
+func characterClasses(password string) (upper, lower, digit, symbol bool) {
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+	return
+}
+
+func poolSize(upper, lower, digit, symbol bool) int {
+	size := 0
+	if upper {
+		size += 26
+	}
+	if lower {
+		size += 26
+	}
+	if digit {
+		size += 10
+	}
+	if symbol {
+		size += 33
+	}
+	if size == 0 {
+		size = 1
+	}
+	return size
+}
+
+func countTrue(values ...bool) int {
+	count := 0
+	for _, value := range values {
+		if value {
+			count++
+		}
+	}
+	return count
+}
+
+func tokenize(str string) []string {
+	return strings.FieldsFunc(str, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func longestDictionaryMatch(runes []rune, dictionary []string) int {
+	longest := 0
+	for _, word := range dictionary {
+		wordRunes := []rune(word)
+		if len(wordRunes) > longest && len(wordRunes) <= len(runes) && string(runes[:len(wordRunes)]) == word {
+			longest = len(wordRunes)
+		}
+	}
+	return longest
+}
+
+func isSequential(previous rune, current rune) bool {
+	diff := current - previous
+	return (diff == 1 || diff == -1) && (unicode.IsLetter(current) || unicode.IsDigit(current))
+}
+
+func isKeyboardAdjacent(previous rune, current rune) bool {
+	for _, row := range keyboardRows {
+		i := strings.IndexRune(row, previous)
+		if i < 0 {
+			continue
+		}
+		j := strings.IndexRune(row, current)
+		return j >= 0 && (j-i == 1 || i-j == 1)
+	}
+	return false
+}
+
+func isTooSimilar(password string, input string, maxSimilarity float64) bool {
+
+	if len(input) < 3 || len(password) == 0 {
+		return false
+	}
+
+	// compare against the input and each of its tokens
+	// (e.g. local part of an email address)
+	candidates := append([]string{input}, tokenize(input)...)
+	for _, candidate := range candidates {
+		if len(candidate) < 3 {
+			continue
+		}
+		if strings.Contains(password, candidate) || similarity(password, candidate) > maxSimilarity {
+			return true
+		}
+	}
+	return false
+}
+
+// similarity returns a value in [0,1] based on normalized Levenshtein distance
+func similarity(a string, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(levenshtein(ra, rb))/float64(longest)
+}
+
+func levenshtein(a []rune, b []rune) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = minInt(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+func minInt(values ...int) int {
+	min := values[0]
+	for _, value := range values[1:] {
+		if value < min {
+			min = value
+		}
+	}
+	return min
+}
@@ -0,0 +1,112 @@
+package passwords
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/saharsh-samples/go-mux-sql-starter/test"
+)
+
+func TestCheckPassword_with_zero_policy(t *testing.T) {
+
+	checker := Bootstrap(&ContextIn{}).PasswordPolicyChecker
+
+	violations, _ := checker.CheckPassword("short").(Violations)
+	test.AssertTrue("Expected default min length", violations.HasCode(ViolationTooShort), t)
+	violations, _ = checker.CheckPassword(strings.Repeat("a", DefaultMaxPasswordLength+1)).(Violations)
+	test.AssertTrue("Expected default max length", violations.HasCode(ViolationTooLong), t)
+	test.AssertTrue("Expected password to pass", checker.CheckPassword("long enough") == nil, t)
+}
+
+func TestCheckPassword_with_disabled_lengths(t *testing.T) {
+	checker := Bootstrap(&ContextIn{PasswordPolicy: PasswordPolicy{MinLength: -1, MaxLength: -1}}).PasswordPolicyChecker
+	test.AssertTrue("Expected negative lengths to accept anything", checker.CheckPassword("a") == nil, t)
+}
+
+func TestCheckPassword_length(t *testing.T) {
+
+	checker := Bootstrap(&ContextIn{PasswordPolicy: PasswordPolicy{MinLength: 8, MaxLength: 10}}).PasswordPolicyChecker
+
+	violations, _ := checker.CheckPassword("short").(Violations)
+	test.AssertEquals("", 1, len(violations), t)
+	test.AssertEquals("", ViolationTooShort, violations[0].Code, t)
+	test.AssertEquals("", "Password must be at least 8 characters long", violations[0].Message, t)
+
+	violations, _ = checker.CheckPassword("much-too-long").(Violations)
+	test.AssertEquals("", 1, len(violations), t)
+	test.AssertEquals("", ViolationTooLong, violations[0].Code, t)
+
+	test.AssertTrue("Expected password to pass", checker.CheckPassword("just-right") == nil, t)
+}
+
+func TestCheckPassword_character_classes(t *testing.T) {
+
+	checker := Bootstrap(&ContextIn{PasswordPolicy: PasswordPolicy{
+		MinLength:        -1,
+		RequireUppercase: true,
+		RequireLowercase: true,
+		RequireDigit:     true,
+		RequireSymbol:    true,
+	}}).PasswordPolicyChecker
+
+	violations, _ := checker.CheckPassword("abc").(Violations)
+	test.AssertEquals("", 3, len(violations), t)
+	test.AssertTrue("", violations.HasCode(ViolationMissingUppercase), t)
+	test.AssertTrue("", violations.HasCode(ViolationMissingDigit), t)
+	test.AssertTrue("", violations.HasCode(ViolationMissingSymbol), t)
+	test.AssertEquals("",
+		"Password must contain an uppercase letter; Password must contain a digit; Password must contain a symbol",
+		violations.Error(), t)
+
+	test.AssertTrue("Expected password to pass", checker.CheckPassword("aB3$") == nil, t)
+
+	checker = Bootstrap(&ContextIn{PasswordPolicy: PasswordPolicy{MinLength: -1, MinCharacterClasses: 3}}).PasswordPolicyChecker
+	violations, _ = checker.CheckPassword("abcDEF").(Violations)
+	test.AssertTrue("", violations.HasCode(ViolationTooFewCharacterClasses), t)
+	test.AssertTrue("Expected password to pass", checker.CheckPassword("abcDEF1") == nil, t)
+}
+
+func TestCheckPassword_banned_words(t *testing.T) {
+	checker := Bootstrap(&ContextIn{PasswordPolicy: PasswordPolicy{BannedWords: []string{"acme"}}}).PasswordPolicyChecker
+	violations, _ := checker.CheckPassword("I<3ACME!").(Violations)
+	test.AssertTrue("", violations.HasCode(ViolationContainsBannedWord), t)
+	test.AssertTrue("Expected password to pass", checker.CheckPassword("I<3Widgets!") == nil, t)
+}
+
+func TestCheckPassword_similarity_to_user_inputs(t *testing.T) {
+
+	checker := Bootstrap(&ContextIn{PasswordPolicy: PasswordPolicy{MaxSimilarity: DefaultMaxSimilarity}}).PasswordPolicyChecker
+
+	violations, _ := checker.CheckPassword("JohnSmith99", "john.smith@example.com").(Violations)
+	test.AssertTrue("", violations.HasCode(ViolationTooSimilarToUserInput), t)
+
+	violations, _ = checker.CheckPassword("jsmithh", "jsmith").(Violations)
+	test.AssertTrue("", violations.HasCode(ViolationTooSimilarToUserInput), t)
+
+	test.AssertTrue("Expected password to pass", checker.CheckPassword("correct horse battery", "jsmith") == nil, t)
+}
+
+func TestCheckPassword_entropy(t *testing.T) {
+	checker := Bootstrap(&ContextIn{PasswordPolicy: PasswordPolicy{MinEntropyBits: 40}}).PasswordPolicyChecker
+	violations, _ := checker.CheckPassword("Password123").(Violations)
+	test.AssertTrue("", violations.HasCode(ViolationTooPredictable), t)
+	test.AssertTrue("Expected password to pass", checker.CheckPassword("vX9#qL2!mZ7@") == nil, t)
+}
+
+func TestEstimateEntropyBits(t *testing.T) {
+
+	test.AssertEquals("", 0.0, EstimateEntropyBits(""), t)
+
+	random := EstimateEntropyBits("vX9#qL2!mZ7@")
+	dictionary := EstimateEntropyBits("password")
+	repeated := EstimateEntropyBits("aaaaaaaaaaaa")
+	sequence := EstimateEntropyBits("abcdefghijkl")
+	keyboard := EstimateEntropyBits("qwertyuiop")
+	personal := EstimateEntropyBits("jsmith2020", "jsmith")
+
+	test.AssertTrue("Expected random password to beat dictionary word", random > dictionary, t)
+	test.AssertTrue("Expected random password to beat repeats", random > repeated, t)
+	test.AssertTrue("Expected random password to beat sequences", random > sequence, t)
+	test.AssertTrue("Expected random password to beat keyboard walks", random > keyboard, t)
+	test.AssertTrue("Expected user inputs to reduce entropy", personal < EstimateEntropyBits("jsmith2020"), t)
+}
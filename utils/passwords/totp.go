@@ -0,0 +1,340 @@
+package passwords
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/saharsh-samples/go-mux-sql-starter/db"
+)
+
+// ---
+// Time-based One-Time Passwords (RFC 6238)
+//
+// Replay prevention and recovery codes are persisted via db.Database and
+// expect the following tables (names are configurable via TOTPConfig):
+//
+//  CREATE TABLE totp_used_steps (
+//    user_id   VARCHAR(64) PRIMARY KEY,
+//    time_step BIGINT NOT NULL
+//  );
+//
+//  CREATE TABLE totp_recovery_codes (
+//    id        BIGINT AUTO_INCREMENT PRIMARY KEY,
+//    user_id   VARCHAR(64) NOT NULL,
+//    code_id   CHAR(8) NOT NULL,
+//    code_hash VARCHAR(255) NOT NULL,
+//    used_at   DATETIME NULL,
+//    UNIQUE INDEX totp_recovery_codes_lookup (user_id, code_id)
+//  );
+//
+// Recovery codes look like "xxxxxxxx-yyyyyyyy". The first half is stored
+// in cleartext as code_id, so redeeming a code only hashes a single row
+// ---
+
+// TOTPManager handles two-factor enrolment and verification
+type TOTPManager interface {
+
+	// Enroll generates a new secret for accountName
+	Enroll(accountName string) (*TOTPEnrollment, error)
+
+	// Verify code against secret. Each time step can only be used once per
+	// userID, so replayed codes fail verification
+	Verify(userID interface{}, secret string, code string) (bool, error)
+
+	// GenerateRecoveryCodes replaces any existing recovery codes of userID
+	// with new ones. Only hashes are persisted, so the returned cleartext
+	// codes must be shown to the user right away
+	GenerateRecoveryCodes(userID interface{}) ([]string, error)
+
+	// RedeemRecoveryCode verifies and consumes a recovery code of userID.
+	// Each code can only be redeemed once, even by concurrent requests
+	RedeemRecoveryCode(userID interface{}, code string) (bool, error)
+}
+
+// TOTPEnrollment is the result of enrolling an account
+type TOTPEnrollment struct {
+
+	// Secret is base32 encoded (without padding) and must be stored by the
+	// caller to verify codes later
+	Secret string
+
+	// ProvisioningURI is the otpauth:// URI understood by authenticator
+	// apps. This is the payload to encode into a QR code
+	ProvisioningURI string
+}
+
+// DefaultTOTPDigits value
+const DefaultTOTPDigits = 6
+
+// DefaultTOTPPeriodInSeconds value
+const DefaultTOTPPeriodInSeconds = 30
+
+// DefaultTOTPSkewSteps value
+const DefaultTOTPSkewSteps = 1
+
+// DefaultTOTPSecretLength value
+const DefaultTOTPSecretLength = 20
+
+// DefaultRecoveryCodeCount value
+const DefaultRecoveryCodeCount = 10
+
+// DefaultTOTPUsedStepsTable value
+const DefaultTOTPUsedStepsTable = "totp_used_steps"
+
+// DefaultTOTPRecoveryCodesTable value
+const DefaultTOTPRecoveryCodesTable = "totp_recovery_codes"
+
+// TOTPConfig values for configuring TOTP. Zero values are replaced
+// with defaults
+type TOTPConfig struct {
+	Issuer             string
+	Digits             int
+	PeriodInSeconds    int
+	SkewSteps          int
+	SecretLength       int
+	RecoveryCodeCount  int
+	UsedStepsTable     string
+	RecoveryCodesTable string
+}
+
+func (config TOTPConfig) withDefaults() TOTPConfig {
+	if config.Digits == 0 {
+		config.Digits = DefaultTOTPDigits
+	}
+	if config.PeriodInSeconds == 0 {
+		config.PeriodInSeconds = DefaultTOTPPeriodInSeconds
+	}
+	if config.SkewSteps == 0 {
+		config.SkewSteps = DefaultTOTPSkewSteps
+	}
+	if config.SecretLength == 0 {
+		config.SecretLength = DefaultTOTPSecretLength
+	}
+	if config.RecoveryCodeCount == 0 {
+		config.RecoveryCodeCount = DefaultRecoveryCodeCount
+	}
+	if config.UsedStepsTable == "" {
+		config.UsedStepsTable = DefaultTOTPUsedStepsTable
+	}
+	if config.RecoveryCodesTable == "" {
+		config.RecoveryCodesTable = DefaultTOTPRecoveryCodesTable
+	}
+	return config
+}
+
+var base32NoPadding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+type totpManager struct {
+	config   TOTPConfig
+	database db.Database
+	hasher   PasswordHasher
+	now      func() time.Time
+}
+
+// Enroll generates a new secret for accountName
+func (manager *totpManager) Enroll(accountName string) (*TOTPEnrollment, error) {
+
+	key, err := generateRandomBytes(uint32(manager.config.SecretLength))
+	if err != nil {
+		return nil, err
+	}
+	secret := base32NoPadding.EncodeToString(key)
+
+	// otpauth://totp/Issuer:account?secret=...&issuer=...
+	label := url.PathEscape(accountName)
+	if manager.config.Issuer != "" {
+		label = url.PathEscape(manager.config.Issuer) + ":" + label
+	}
+	params := url.Values{}
+	params.Set("secret", secret)
+	if manager.config.Issuer != "" {
+		params.Set("issuer", manager.config.Issuer)
+	}
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprintf("%d", manager.config.Digits))
+	params.Set("period", fmt.Sprintf("%d", manager.config.PeriodInSeconds))
+
+	return &TOTPEnrollment{
+		Secret:          secret,
+		ProvisioningURI: "otpauth://totp/" + label + "?" + params.Encode(),
+	}, nil
+}
+
+// Verify code against secret, rejecting replayed time steps
+func (manager *totpManager) Verify(userID interface{}, secret string, code string) (bool, error) {
+
+	key, err := base32NoPadding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if err != nil {
+		return false, err
+	}
+
+	// find matching time step within allowed skew
+	current := manager.now().Unix() / int64(manager.config.PeriodInSeconds)
+	matched := int64(-1)
+	for skew := -manager.config.SkewSteps; skew <= manager.config.SkewSteps; skew++ {
+		step := current + int64(skew)
+		expected := generateTOTPCode(key, step, manager.config.Digits)
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			matched = step
+		}
+	}
+	if matched < 0 {
+		return false, nil
+	}
+
+	// make sure time step (or a later one) has not already been used
+	replayed := false
+	txErr := manager.database.WithTransaction(func(conn db.Connection) db.Error {
+
+		var lastUsed int64
+		lookupErr := db.LookupOne(conn,
+			fmt.Sprintf("SELECT time_step FROM %s WHERE user_id = ? FOR UPDATE", manager.config.UsedStepsTable),
+			[]interface{}{userID}, []interface{}{&lastUsed})
+
+		if lookupErr != nil && lookupErr.Type() == db.NotFound {
+			_, insertErr := conn.Exec(
+				fmt.Sprintf("INSERT INTO %s (user_id, time_step) VALUES (?, ?)", manager.config.UsedStepsTable),
+				userID, matched)
+			return db.WrapError(insertErr)
+		}
+		if lookupErr != nil {
+			return lookupErr
+		}
+
+		if matched <= lastUsed {
+			replayed = true
+			return nil
+		}
+
+		_, updateErr := conn.Exec(
+			fmt.Sprintf("UPDATE %s SET time_step = ? WHERE user_id = ?", manager.config.UsedStepsTable),
+			matched, userID)
+		return db.WrapError(updateErr)
+	})
+	if txErr != nil {
+		return false, txErr
+	}
+
+	return !replayed, nil
+}
+
+// GenerateRecoveryCodes replaces existing recovery codes of userID
+func (manager *totpManager) GenerateRecoveryCodes(userID interface{}) ([]string, error) {
+
+	codes := make([]string, manager.config.RecoveryCodeCount)
+	hashes := make([]string, manager.config.RecoveryCodeCount)
+	for i := range codes {
+		random, err := generateRandomBytes(10)
+		if err != nil {
+			return nil, err
+		}
+		encoded := strings.ToLower(base32NoPadding.EncodeToString(random))
+		codes[i] = encoded[:8] + "-" + encoded[8:16]
+		hashes[i], err = manager.hasher.GeneratePasswordHash(codes[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	txErr := manager.database.WithTransaction(func(conn db.Connection) db.Error {
+		_, deleteErr := conn.Exec(
+			fmt.Sprintf("DELETE FROM %s WHERE user_id = ?", manager.config.RecoveryCodesTable),
+			userID)
+		if deleteErr != nil {
+			return db.WrapError(deleteErr)
+		}
+		for i, hash := range hashes {
+			_, insertErr := conn.Exec(
+				fmt.Sprintf("INSERT INTO %s (user_id, code_id, code_hash) VALUES (?, ?, ?)", manager.config.RecoveryCodesTable),
+				userID, recoveryCodeID(codes[i]), hash)
+			if insertErr != nil {
+				return db.WrapError(insertErr)
+			}
+		}
+		return nil
+	})
+	if txErr != nil {
+		return nil, txErr
+	}
+
+	return codes, nil
+}
+
+// RedeemRecoveryCode verifies and consumes a recovery code of userID
+func (manager *totpManager) RedeemRecoveryCode(userID interface{}, code string) (bool, error) {
+
+	code = strings.ToLower(strings.TrimSpace(code))
+	codeID := recoveryCodeID(code)
+	if codeID == "" {
+		return false, nil
+	}
+
+	// look up candidate by its cleartext identifier. No locks are held
+	// while hashing, as consuming the code below is conditional
+	var id int64
+	var hash string
+	lookupErr := manager.database.LookupOne(
+		fmt.Sprintf("SELECT id, code_hash FROM %s WHERE user_id = ? AND code_id = ? AND used_at IS NULL", manager.config.RecoveryCodesTable),
+		[]interface{}{userID, codeID}, []interface{}{&id, &hash})
+	if lookupErr != nil && lookupErr.Type() == db.NotFound {
+		return false, nil
+	}
+	if lookupErr != nil {
+		return false, lookupErr
+	}
+	if match, _ := ComparePasswordAndHash(code, hash); !match {
+		return false, nil
+	}
+
+	// consume code, unless a concurrent request got to it first
+	result, updateErr := manager.database.GetConnection().Exec(
+		fmt.Sprintf("UPDATE %s SET used_at = CURRENT_TIMESTAMP WHERE id = ? AND used_at IS NULL", manager.config.RecoveryCodesTable),
+		id)
+	if updateErr != nil {
+		return false, db.WrapError(updateErr)
+	}
+	updated, rowsErr := result.RowsAffected()
+	if rowsErr != nil {
+		return false, db.WrapError(rowsErr)
+	}
+
+	return updated == 1, nil
+}
+
+// recoveryCodeID is the cleartext first half of a recovery code, or empty
+// if code is malformed
+func recoveryCodeID(code string) string {
+	parts := strings.Split(code, "-")
+	if len(parts) != 2 || len(parts[0]) != 8 {
+		return ""
+	}
+	return parts[0]
+}
+
+// generateTOTPCode implements HOTP (RFC 4226) for specified time step
+func generateTOTPCode(key []byte, step int64, digits int) string {
+
+	counter := make([]byte, 8)
+	binary.BigEndian.PutUint64(counter, uint64(step))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter)
+	sum := mac.Sum(nil)
+
+	// dynamic truncation
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	modulo := uint32(1)
+	for i := 0; i < digits; i++ {
+		modulo *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, value%modulo)
+}
@@ -0,0 +1,237 @@
+package passwords
+
+import (
+	"database/sql/driver"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	dbTest "github.com/saharsh-samples/go-mux-sql-starter/db/test"
+	"github.com/saharsh-samples/go-mux-sql-starter/test"
+)
+
+// RFC 6238 Appendix B test secret
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// cheap hashing keeps recovery code tests fast
+var testArgon2Config = Argon2Config{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestGenerateTOTPCode_RFC6238_vectors(t *testing.T) {
+	key, _ := base32NoPadding.DecodeString(rfc6238Secret)
+	test.AssertEquals("", "94287082", generateTOTPCode(key, 59/30, 8), t)
+	test.AssertEquals("", "07081804", generateTOTPCode(key, 1111111109/30, 8), t)
+	test.AssertEquals("", "89005924", generateTOTPCode(key, 1234567890/30, 8), t)
+	test.AssertEquals("", "005924", generateTOTPCode(key, 1234567890/30, 6), t)
+}
+
+func TestEnroll(t *testing.T) {
+
+	manager := Bootstrap(&ContextIn{Argon2Config: testArgon2Config, TOTPConfig: TOTPConfig{Issuer: "Acme Co", Digits: 8}, Database: &dbTest.MockDatabase{}}).TOTPManager.(*totpManager)
+	manager.now = func() time.Time { return time.Now() }
+
+	enrollment, err := manager.Enroll("jane@example.com")
+	test.AssertTrue("Expected enrolment to succeed", err == nil, t)
+
+	key, decodeErr := base32NoPadding.DecodeString(enrollment.Secret)
+	test.AssertTrue("Expected secret to be base32 encoded", decodeErr == nil, t)
+	test.AssertEquals("", DefaultTOTPSecretLength, len(key), t)
+
+	uri, parseErr := url.Parse(enrollment.ProvisioningURI)
+	test.AssertTrue("Expected valid provisioning URI", parseErr == nil, t)
+	test.AssertEquals("", "otpauth", uri.Scheme, t)
+	test.AssertEquals("", "totp", uri.Host, t)
+	test.AssertEquals("", "/Acme Co:jane@example.com", uri.Path, t)
+	test.AssertEquals("", enrollment.Secret, uri.Query().Get("secret"), t)
+	test.AssertEquals("", "Acme Co", uri.Query().Get("issuer"), t)
+	test.AssertEquals("", "8", uri.Query().Get("digits"), t)
+	test.AssertEquals("", "30", uri.Query().Get("period"), t)
+}
+
+func TestVerify_first_use(t *testing.T) {
+
+	database, mock, closer := dbTest.NewDatabaseWithMockConnection(t)
+	defer closer()
+	manager := Bootstrap(&ContextIn{Argon2Config: testArgon2Config, TOTPConfig: TOTPConfig{Issuer: "Acme Co", Digits: 8}, Database: database}).TOTPManager.(*totpManager)
+	manager.now = func() time.Time { return time.Unix(59, 0) }
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT time_step FROM totp_used_steps WHERE user_id = ? FOR UPDATE").
+		WithArgs(42).
+		WillReturnRows(sqlmock.NewRows([]string{"time_step"}))
+	mock.ExpectExec("INSERT INTO totp_used_steps (user_id, time_step) VALUES (?, ?)").
+		WithArgs(42, 1).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	valid, err := manager.Verify(42, rfc6238Secret, "94287082")
+	test.AssertTrue("Expected verification to succeed", valid && err == nil, t)
+	test.AssertTrue("Expected all db expectations to be met", mock.ExpectationsWereMet() == nil, t)
+}
+
+func TestVerify_with_clock_skew(t *testing.T) {
+
+	database, mock, closer := dbTest.NewDatabaseWithMockConnection(t)
+	defer closer()
+
+	// code for step 1 is still accepted one step later
+	manager := Bootstrap(&ContextIn{Argon2Config: testArgon2Config, TOTPConfig: TOTPConfig{Issuer: "Acme Co", Digits: 8}, Database: database}).TOTPManager.(*totpManager)
+	manager.now = func() time.Time { return time.Unix(89, 0) }
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT time_step FROM totp_used_steps WHERE user_id = ? FOR UPDATE").
+		WithArgs(42).
+		WillReturnRows(sqlmock.NewRows([]string{"time_step"}).AddRow(0))
+	mock.ExpectExec("UPDATE totp_used_steps SET time_step = ? WHERE user_id = ?").
+		WithArgs(1, 42).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	valid, err := manager.Verify(42, rfc6238Secret, "94287082")
+	test.AssertTrue("Expected verification to succeed", valid && err == nil, t)
+
+	// but not two steps later
+	manager.now = func() time.Time { return time.Unix(119, 0) }
+	valid, err = manager.Verify(42, rfc6238Secret, "94287082")
+	test.AssertFalse("Expected verification to fail", valid, t)
+	test.AssertTrue("Expected no error", err == nil, t)
+
+	test.AssertTrue("Expected all db expectations to be met", mock.ExpectationsWereMet() == nil, t)
+}
+
+func TestVerify_replay(t *testing.T) {
+
+	database, mock, closer := dbTest.NewDatabaseWithMockConnection(t)
+	defer closer()
+	manager := Bootstrap(&ContextIn{Argon2Config: testArgon2Config, TOTPConfig: TOTPConfig{Issuer: "Acme Co", Digits: 8}, Database: database}).TOTPManager.(*totpManager)
+	manager.now = func() time.Time { return time.Unix(59, 0) }
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT time_step FROM totp_used_steps WHERE user_id = ? FOR UPDATE").
+		WithArgs(42).
+		WillReturnRows(sqlmock.NewRows([]string{"time_step"}).AddRow(1))
+	mock.ExpectCommit()
+
+	valid, err := manager.Verify(42, rfc6238Secret, "94287082")
+	test.AssertFalse("Expected replayed code to fail verification", valid, t)
+	test.AssertTrue("Expected no error", err == nil, t)
+	test.AssertTrue("Expected all db expectations to be met", mock.ExpectationsWereMet() == nil, t)
+}
+
+func TestVerify_db_error(t *testing.T) {
+
+	database, mock, closer := dbTest.NewDatabaseWithMockConnection(t)
+	defer closer()
+	manager := Bootstrap(&ContextIn{Argon2Config: testArgon2Config, TOTPConfig: TOTPConfig{Issuer: "Acme Co", Digits: 8}, Database: database}).TOTPManager.(*totpManager)
+	manager.now = func() time.Time { return time.Unix(59, 0) }
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT time_step FROM totp_used_steps WHERE user_id = ? FOR UPDATE").
+		WithArgs(42).
+		WillReturnError(errors.New("Simulated error"))
+	mock.ExpectRollback()
+
+	valid, err := manager.Verify(42, rfc6238Secret, "94287082")
+	test.AssertFalse("Expected verification to fail", valid, t)
+	test.AssertEquals("", "Simulated error", err.Error(), t)
+}
+
+func TestVerify_bad_secret(t *testing.T) {
+	manager := Bootstrap(&ContextIn{Argon2Config: testArgon2Config, TOTPConfig: TOTPConfig{Issuer: "Acme Co", Digits: 8}, Database: &dbTest.MockDatabase{}}).TOTPManager.(*totpManager)
+	manager.now = func() time.Time { return time.Unix(59, 0) }
+	_, err := manager.Verify(42, "not base32!", "94287082")
+	test.AssertFalse("Expected error decoding secret", err == nil, t)
+}
+
+func TestRecoveryCodes(t *testing.T) {
+
+	database, mock, closer := dbTest.NewDatabaseWithMockConnection(t)
+	defer closer()
+	manager := Bootstrap(&ContextIn{Argon2Config: testArgon2Config, TOTPConfig: TOTPConfig{Issuer: "Acme Co", Digits: 8}, Database: database}).TOTPManager.(*totpManager)
+	manager.now = func() time.Time { return time.Now() }
+	manager.config.RecoveryCodeCount = 2
+
+	// generate
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM totp_recovery_codes WHERE user_id = ?").
+		WithArgs(42).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	var codeIDs, hashes []string
+	for i := 0; i < 2; i++ {
+		mock.ExpectExec("INSERT INTO totp_recovery_codes (user_id, code_id, code_hash) VALUES (?, ?, ?)").
+			WithArgs(42, valueCapture{&codeIDs}, valueCapture{&hashes}).
+			WillReturnResult(sqlmock.NewResult(int64(i+1), 1))
+	}
+	mock.ExpectCommit()
+
+	codes, err := manager.GenerateRecoveryCodes(42)
+	test.AssertTrue("Expected generation to succeed", err == nil, t)
+	test.AssertEquals("", 2, len(codes), t)
+	test.AssertEquals("", 17, len(codes[0]), t)
+	test.AssertEquals("", 2, len(hashes), t)
+	test.AssertEquals("Expected first half to be stored as code ID", codes[1][:8], codeIDs[1], t)
+	test.AssertTrue("Expected argon2 hashes to be stored", strings.HasPrefix(hashes[0], "$argon2id$"), t)
+
+	// redeem
+	selectQuery := "SELECT id, code_hash FROM totp_recovery_codes WHERE user_id = ? AND code_id = ? AND used_at IS NULL"
+	updateCommand := "UPDATE totp_recovery_codes SET used_at = CURRENT_TIMESTAMP WHERE id = ? AND used_at IS NULL"
+	mock.ExpectQuery(selectQuery).
+		WithArgs(42, codeIDs[1]).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "code_hash"}).AddRow(2, hashes[1]))
+	mock.ExpectExec(updateCommand).
+		WithArgs(2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	redeemed, err := manager.RedeemRecoveryCode(42, " "+strings.ToUpper(codes[1])+" ")
+	test.AssertTrue("Expected redemption to succeed", redeemed && err == nil, t)
+
+	// concurrently redeemed code
+	mock.ExpectQuery(selectQuery).
+		WithArgs(42, codeIDs[0]).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "code_hash"}).AddRow(1, hashes[0]))
+	mock.ExpectExec(updateCommand).
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	redeemed, err = manager.RedeemRecoveryCode(42, codes[0])
+	test.AssertFalse("Expected redemption to fail", redeemed, t)
+	test.AssertTrue("Expected no error", err == nil, t)
+
+	// unknown code
+	mock.ExpectQuery(selectQuery).
+		WithArgs(42, "aaaaaaaa").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "code_hash"}))
+
+	redeemed, err = manager.RedeemRecoveryCode(42, "aaaaaaaa-aaaaaaaa")
+	test.AssertFalse("Expected redemption to fail", redeemed, t)
+	test.AssertTrue("Expected no error", err == nil, t)
+
+	// wrong second half
+	mock.ExpectQuery(selectQuery).
+		WithArgs(42, codeIDs[0]).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "code_hash"}).AddRow(1, hashes[0]))
+
+	redeemed, err = manager.RedeemRecoveryCode(42, codeIDs[0]+"-aaaaaaaa")
+	test.AssertFalse("Expected redemption to fail", redeemed, t)
+	test.AssertTrue("Expected no error", err == nil, t)
+
+	// malformed code never hits the database
+	redeemed, err = manager.RedeemRecoveryCode(42, "not-a-recovery-code")
+	test.AssertFalse("Expected redemption to fail", redeemed, t)
+	test.AssertTrue("Expected no error", err == nil, t)
+
+	test.AssertTrue("Expected all db expectations to be met", mock.ExpectationsWereMet() == nil, t)
+}
+
+// valueCapture is a sqlmock.Argument that records the stored values
+type valueCapture struct{ values *[]string }
+
+func (capture valueCapture) Match(value driver.Value) bool {
+	stored, ok := value.(string)
+	if ok {
+		*capture.values = append(*capture.values, stored)
+	}
+	return ok
+}